@@ -0,0 +1,52 @@
+// Command fakegemini serves a fake Gemini web backend. Point the proxy at it
+// with GEMINI_BASE_URL to run it end-to-end without a Google account.
+package main
+
+import (
+	"flag"
+	"net/http"
+	"time"
+
+	"gemini-web-to-api/pkg/fakegemini"
+	"gemini-web-to-api/pkg/logger"
+
+	"go.uber.org/zap"
+)
+
+func main() {
+	addr := flag.String("addr", ":4982", "address to listen on")
+	token := flag.String("token", "", "SNlM0e session token to serve (random by default)")
+	psid := flag.String("psid", "", "only accept this __Secure-1PSID cookie (any by default)")
+	delay := flag.Duration("delay", 20*time.Millisecond, "delay before each streamed frame")
+	flag.Parse()
+
+	log, err := logger.New("info")
+	if err != nil {
+		panic(err)
+	}
+
+	server := fakegemini.NewServer()
+	if *token != "" {
+		server.Token = *token
+	}
+	server.PSID = *psid
+	server.FrameDelay = *delay
+
+	log.Info("Fake Gemini backend listening", zap.String("address", *addr))
+	if err := http.ListenAndServe(*addr, logRequests(log, server)); err != nil {
+		log.Fatal("Fake Gemini backend stopped", zap.Error(err))
+	}
+}
+
+// logRequests logs every request served by the fake
+func logRequests(log *zap.Logger, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		next.ServeHTTP(w, r)
+		log.Info("Request",
+			zap.String("method", r.Method),
+			zap.String("path", r.URL.Path),
+			zap.Duration("duration", time.Since(start)),
+		)
+	})
+}
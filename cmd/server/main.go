@@ -1,6 +1,9 @@
 package main
 
 import (
+	"flag"
+	"os"
+
 	"gemini-web-to-api/internal/commons/configs"
 	"gemini-web-to-api/internal/modules"
 	"gemini-web-to-api/internal/server"
@@ -18,6 +21,12 @@ import (
 // @host localhost:4981
 // @BasePath /
 func main() {
+	mock := flag.Bool("mock", false, "answer from a built-in fake Gemini backend instead of Google (no account needed)")
+	flag.Parse()
+	if *mock {
+		os.Setenv("GEMINI_MOCK", "true")
+	}
+
 	fx.New(
 		fx.Provide(
 			configs.New,
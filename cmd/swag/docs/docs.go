@@ -17,12 +17,13 @@ const docTemplate = `{
     "paths": {
         "/claude/v1/messages": {
             "post": {
-                "description": "Sends a message to the Claude model",
+                "description": "Sends a message to the Claude model. Set \"stream\": true to receive Anthropic streaming events.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/event-stream"
                 ],
                 "tags": [
                     "Claude"
@@ -53,6 +54,13 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -115,8 +123,7 @@ const docTemplate = `{
                     "200": {
                         "description": "OK",
                         "schema": {
-                            "type": "object",
-                            "additionalProperties": true
+                            "$ref": "#/definitions/models.ModelListResponse"
                         }
                     }
                 }
@@ -147,6 +154,12 @@ const docTemplate = `{
                 "responses": {
                     "200": {
                         "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/models.ModelData"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
                         "schema": {
                             "type": "object",
                             "additionalProperties": true
@@ -221,12 +234,13 @@ const docTemplate = `{
         },
         "/gemini/v1beta/models/{model}:streamGenerateContent": {
             "post": {
-                "description": "Streams generated content using the Gemini model",
+                "description": "Streams generated content using the Gemini model. By default the chunks are elements of a single JSON array; pass alt=sse to receive Server-Sent Events instead.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/event-stream"
                 ],
                 "tags": [
                     "Gemini"
@@ -240,6 +254,12 @@ const docTemplate = `{
                         "in": "path",
                         "required": true
                     },
+                    {
+                        "type": "string",
+                        "description": "Response format: sse for Server-Sent Events, omit for a streamed JSON array",
+                        "name": "alt",
+                        "in": "query"
+                    },
                     {
                         "description": "Generate Request",
                         "name": "request",
@@ -252,9 +272,12 @@ const docTemplate = `{
                 ],
                 "responses": {
                     "200": {
-                        "description": "Chunked response",
+                        "description": "Streamed chunks",
                         "schema": {
-                            "type": "string"
+                            "type": "array",
+                            "items": {
+                                "$ref": "#/definitions/dto.GeminiGenerateResponse"
+                            }
                         }
                     }
                 }
@@ -262,7 +285,7 @@ const docTemplate = `{
         },
         "/health": {
             "get": {
-                "description": "Returns the health status of the service. Used by Docker/K8s/cloud platforms to monitor the service.",
+                "description": "Returns the health status of the service. Used by Docker/K8s/cloud platforms to monitor the service. With deep=1 it reports every account like /ready and returns 503 when none can serve requests.",
                 "produces": [
                     "application/json"
                 ],
@@ -270,19 +293,25 @@ const docTemplate = `{
                     "System"
                 ],
                 "summary": "Health check",
+                "parameters": [
+                    {
+                        "type": "boolean",
+                        "description": "Check the Gemini accounts",
+                        "name": "deep",
+                        "in": "query"
+                    }
+                ],
                 "responses": {
                     "200": {
                         "description": "Service is healthy",
                         "schema": {
-                            "type": "object",
-                            "properties": {
-                                "service": {
-                                    "type": "string"
-                                },
-                                "status": {
-                                    "type": "string"
-                                }
-                            }
+                            "$ref": "#/definitions/dto.Health"
+                        }
+                    },
+                    "503": {
+                        "description": "No account can serve requests (deep check)",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Health"
                         }
                     }
                 }
@@ -290,12 +319,13 @@ const docTemplate = `{
         },
         "/openai/v1/chat/completions": {
             "post": {
-                "description": "Generates a completion for the chat message",
+                "description": "Generates a completion for the chat message. Set \"stream\": true to receive chat.completion.chunk Server-Sent Events.",
                 "consumes": [
                     "application/json"
                 ],
                 "produces": [
-                    "application/json"
+                    "application/json",
+                    "text/event-stream"
                 ],
                 "tags": [
                     "OpenAI"
@@ -326,6 +356,68 @@ const docTemplate = `{
                             "additionalProperties": true
                         }
                     },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/openai/v1/images/generations": {
+            "post": {
+                "description": "Generates images from a prompt. The size is passed to Gemini as an aspect ratio hint; b64_json images are downloaded with the account that generated them.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OpenAI"
+                ],
+                "summary": "Create Image (OpenAI)",
+                "parameters": [
+                    {
+                        "description": "Image Generation Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.ImageGenerationRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.ImagesResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
                     "500": {
                         "description": "Internal Server Error",
                         "schema": {
@@ -358,301 +450,1620 @@ const docTemplate = `{
                     }
                 }
             }
-        }
-    },
-    "definitions": {
-        "dto.Candidate": {
-            "type": "object",
-            "properties": {
-                "content": {
-                    "$ref": "#/definitions/dto.Content"
-                },
-                "finishMessage": {
-                    "type": "string"
-                },
-                "finishReason": {
-                    "type": "string"
-                },
-                "index": {
-                    "type": "integer"
+        },
+        "/openai/v1/responses": {
+            "post": {
+                "description": "Generates a model response. Pass previous_response_id to continue the Gemini conversation of a stored response with only the new input. Set \"stream\": true to receive Responses API Server-Sent Events.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "OpenAI"
+                ],
+                "summary": "Create Response (OpenAI)",
+                "parameters": [
+                    {
+                        "description": "Create Response Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateResponseRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Response"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
                 }
             }
         },
-        "dto.ChatCompletionRequest": {
-            "type": "object",
-            "properties": {
-                "max_tokens": {
-                    "type": "integer"
-                },
-                "messages": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Message"
+        "/openai/v1/responses/{response_id}": {
+            "get": {
+                "description": "Retrieves a stored response by ID",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OpenAI"
+                ],
+                "summary": "Get Response (OpenAI)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Response ID",
+                        "name": "response_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Response"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes a stored response; it can no longer be used as previous_response_id",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "OpenAI"
+                ],
+                "summary": "Delete Response (OpenAI)",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Response ID",
+                        "name": "response_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DeletedResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
                     }
-                },
-                "model": {
-                    "type": "string"
-                },
-                "stream": {
-                    "type": "boolean"
-                },
-                "temperature": {
-                    "type": "number"
                 }
             }
         },
-        "dto.ChatCompletionResponse": {
-            "type": "object",
-            "properties": {
-                "choices": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/dto.Choice"
+        "/ready": {
+            "get": {
+                "description": "Reports the health of every Gemini account: session state, last cookie and SNlM0e refresh, last upstream error and circuit state. Returns 503 when no account can serve requests, so load balancers stop routing to the instance.",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "System"
+                ],
+                "summary": "Readiness check",
+                "responses": {
+                    "200": {
+                        "description": "At least one account can serve requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Health"
+                        }
+                    },
+                    "503": {
+                        "description": "No account can serve requests",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Health"
+                        }
                     }
-                },
-                "created": {
-                    "type": "integer"
-                },
-                "id": {
-                    "type": "string"
-                },
-                "model": {
-                    "type": "string"
-                },
-                "object": {
-                    "type": "string"
-                },
-                "usage": {
-                    "$ref": "#/definitions/models.Usage"
                 }
             }
         },
-        "dto.Choice": {
-            "type": "object",
-            "properties": {
-                "finish_reason": {
-                    "type": "string"
-                },
-                "index": {
-                    "type": "integer"
-                },
-                "message": {
-                    "$ref": "#/definitions/models.Message"
+        "/sessions": {
+            "post": {
+                "description": "Creates a chat session for a model. Messages posted to it continue one Gemini conversation, so the history is never resent.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Create Session",
+                "parameters": [
+                    {
+                        "description": "Create Session Request",
+                        "name": "request",
+                        "in": "body",
+                        "schema": {
+                            "$ref": "#/definitions/dto.CreateSessionRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Session"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{session_id}": {
+            "get": {
+                "description": "Returns the model, Gemini conversation IDs and account of a session",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Get Session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Session"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            },
+            "delete": {
+                "description": "Deletes a session and removes its conversation from the Gemini history",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Delete Session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.DeletedSession"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{session_id}/clear": {
+            "post": {
+                "description": "Forgets the session's history; the next message starts a new Gemini conversation",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Clear Session",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.Session"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{session_id}/history": {
+            "get": {
+                "description": "Returns the messages exchanged in a session",
+                "produces": [
+                    "application/json"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Get Session History",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.HistoryResponse"
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        },
+        "/sessions/{session_id}/messages": {
+            "post": {
+                "description": "Sends a message to the session's conversation. Set \"stream\": true to receive \"delta\" Server-Sent Events followed by a \"message\" event with the complete reply.",
+                "consumes": [
+                    "application/json"
+                ],
+                "produces": [
+                    "application/json",
+                    "text/event-stream"
+                ],
+                "tags": [
+                    "Sessions"
+                ],
+                "summary": "Send Session Message",
+                "parameters": [
+                    {
+                        "type": "string",
+                        "description": "Session ID",
+                        "name": "session_id",
+                        "in": "path",
+                        "required": true
+                    },
+                    {
+                        "description": "Send Message Request",
+                        "name": "request",
+                        "in": "body",
+                        "required": true,
+                        "schema": {
+                            "$ref": "#/definitions/dto.SendMessageRequest"
+                        }
+                    }
+                ],
+                "responses": {
+                    "200": {
+                        "description": "OK",
+                        "schema": {
+                            "$ref": "#/definitions/dto.SendMessageResponse"
+                        }
+                    },
+                    "400": {
+                        "description": "Bad Request",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "404": {
+                        "description": "Not Found",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "409": {
+                        "description": "Conflict",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    },
+                    "500": {
+                        "description": "Internal Server Error",
+                        "schema": {
+                            "type": "object",
+                            "additionalProperties": true
+                        }
+                    }
+                }
+            }
+        }
+    },
+    "definitions": {
+        "dto.Account": {
+            "type": "object",
+            "properties": {
+                "available": {
+                    "description": "healthy and circuit closed",
+                    "type": "boolean"
+                },
+                "circuit": {
+                    "description": "\"closed\" or \"open\"",
+                    "type": "string"
+                },
+                "cookies_refreshed_at": {
+                    "type": "string"
+                },
+                "cooldown_until": {
+                    "type": "string"
+                },
+                "healthy": {
+                    "description": "holds a valid session token",
+                    "type": "boolean"
+                },
+                "in_flight_requests": {
+                    "type": "integer"
+                },
+                "last_error": {
+                    "$ref": "#/definitions/dto.UpstreamError"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "session_token_refreshed_at": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Annotation": {
+            "type": "object",
+            "properties": {
+                "end_index": {
+                    "type": "integer"
+                },
+                "start_index": {
+                    "type": "integer"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "\"url_citation\"",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Candidate": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "$ref": "#/definitions/dto.Content"
+                },
+                "finishMessage": {
+                    "type": "string"
+                },
+                "finishReason": {
+                    "type": "string"
+                },
+                "groundingMetadata": {
+                    "$ref": "#/definitions/dto.GroundingMetadata"
+                },
+                "index": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.ChatCompletionRequest": {
+            "type": "object",
+            "properties": {
+                "max_tokens": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Message"
+                    }
+                },
+                "model": {
+                    "type": "string"
+                },
+                "n": {
+                    "description": "choices to return (non-streaming), up to the drafts Gemini produced",
+                    "type": "integer"
+                },
+                "parallel_tool_calls": {
+                    "type": "boolean"
+                },
+                "stream": {
+                    "type": "boolean"
+                },
+                "stream_options": {
+                    "$ref": "#/definitions/dto.StreamOptions"
+                },
+                "temperature": {
+                    "type": "number"
+                },
+                "tool_choice": {
+                    "description": "\"auto\", \"none\", \"required\" or {\"type\":\"function\",\"function\":{\"name\":...}}"
+                },
+                "tools": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_modules_openai_dto.Tool"
+                    }
+                }
+            }
+        },
+        "dto.ChatCompletionResponse": {
+            "type": "object",
+            "properties": {
+                "choices": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Choice"
+                    }
+                },
+                "created": {
+                    "type": "integer"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "object": {
+                    "type": "string"
+                },
+                "usage": {
+                    "$ref": "#/definitions/models.Usage"
+                }
+            }
+        },
+        "dto.Choice": {
+            "type": "object",
+            "properties": {
+                "finish_reason": {
+                    "type": "string"
+                },
+                "index": {
+                    "type": "integer"
+                },
+                "message": {
+                    "$ref": "#/definitions/models.Message"
+                }
+            }
+        },
+        "dto.ConfigContent": {
+            "type": "object",
+            "properties": {
+                "citations": {
+                    "description": "text blocks",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Citation"
+                    }
+                },
+                "id": {
+                    "description": "tool_use blocks",
+                    "type": "string"
+                },
+                "input": {
+                    "type": "object"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "source": {
+                    "description": "image blocks",
+                    "allOf": [
+                        {
+                            "$ref": "#/definitions/dto.ImageSource"
+                        }
+                    ]
+                },
+                "text": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "\"text\", \"tool_use\" or \"image\"",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Content": {
+            "type": "object",
+            "properties": {
+                "parts": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Part"
+                    }
+                },
+                "role": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.CreateResponseRequest": {
+            "type": "object",
+            "properties": {
+                "input": {
+                    "description": "string or array of input items",
+                    "type": "object"
+                },
+                "instructions": {
+                    "type": "string"
+                },
+                "max_output_tokens": {
+                    "type": "integer"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "model": {
+                    "type": "string"
+                },
+                "parallel_tool_calls": {
+                    "type": "boolean"
+                },
+                "previous_response_id": {
+                    "type": "string"
+                },
+                "store": {
+                    "description": "defaults to true; unstored responses cannot be chained",
+                    "type": "boolean"
+                },
+                "stream": {
+                    "type": "boolean"
+                },
+                "temperature": {
+                    "type": "number"
+                },
+                "tool_choice": {
+                    "description": "\"auto\", \"none\", \"required\" or {\"type\":\"function\",\"name\":...}"
+                },
+                "tools": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_modules_responses_dto.Tool"
+                    }
+                }
+            }
+        },
+        "dto.CreateSessionRequest": {
+            "type": "object",
+            "properties": {
+                "model": {
+                    "description": "defaults to the account's default model",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.DeletedResponse": {
+            "type": "object",
+            "properties": {
+                "deleted": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "object": {
+                    "description": "\"response.deleted\"",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.DeletedSession": {
+            "type": "object",
+            "properties": {
+                "conversation_deleted": {
+                    "description": "ConversationDeleted reports whether the conversation was also removed from the Gemini history",
+                    "type": "boolean"
+                },
+                "deleted": {
+                    "type": "boolean"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "object": {
+                    "description": "\"session.deleted\"",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.File": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "mime_type": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.FileData": {
+            "type": "object",
+            "properties": {
+                "fileUri": {
+                    "type": "string"
+                },
+                "mimeType": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.FunctionCall": {
+            "type": "object",
+            "properties": {
+                "args": {
+                    "type": "object"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.FunctionCallingConfig": {
+            "type": "object",
+            "properties": {
+                "allowedFunctionNames": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "mode": {
+                    "description": "AUTO, ANY or NONE",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.FunctionDeclaration": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "description": "OpenAPI schema subset"
+                },
+                "parametersJsonSchema": {
+                    "description": "JSON schema, alternative to parameters"
+                }
+            }
+        },
+        "dto.FunctionDefinition": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "parameters": {
+                    "description": "JSON schema"
+                }
+            }
+        },
+        "dto.FunctionResponse": {
+            "type": "object",
+            "properties": {
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "response": {
+                    "type": "object"
+                }
+            }
+        },
+        "dto.GeminiGenerateRequest": {
+            "type": "object",
+            "properties": {
+                "contents": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Content"
+                    }
+                },
+                "generationConfig": {
+                    "$ref": "#/definitions/dto.GenerationConfig"
+                },
+                "safety_settings": {
+                    "type": "array",
+                    "items": {
+                        "type": "object",
+                        "additionalProperties": {
+                            "type": "string"
+                        }
+                    }
+                },
+                "systemInstruction": {
+                    "$ref": "#/definitions/dto.Content"
+                },
+                "toolConfig": {
+                    "$ref": "#/definitions/dto.ToolConfig"
+                },
+                "tools": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_modules_gemini_dto.Tool"
+                    }
+                }
+            }
+        },
+        "dto.GeminiGenerateResponse": {
+            "type": "object",
+            "properties": {
+                "candidates": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Candidate"
+                    }
+                },
+                "usageMetadata": {
+                    "$ref": "#/definitions/dto.UsageMetadata"
+                }
+            }
+        },
+        "dto.GeminiModel": {
+            "type": "object",
+            "properties": {
+                "description": {
+                    "type": "string"
+                },
+                "displayName": {
+                    "type": "string"
+                },
+                "inputTokenLimit": {
+                    "type": "integer"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "supportedGenerationMethods": {
+                    "type": "array",
+                    "items": {
+                        "type": "string"
+                    }
+                },
+                "version": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.GeminiModelsResponse": {
+            "type": "object",
+            "properties": {
+                "models": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.GeminiModel"
+                    }
+                }
+            }
+        },
+        "dto.GenerationConfig": {
+            "type": "object",
+            "properties": {
+                "candidateCount": {
+                    "type": "integer"
+                },
+                "maxOutputTokens": {
+                    "type": "integer"
+                },
+                "temperature": {
+                    "type": "number"
+                },
+                "topK": {
+                    "type": "integer"
+                },
+                "topP": {
+                    "type": "number"
+                }
+            }
+        },
+        "dto.GroundingChunk": {
+            "type": "object",
+            "properties": {
+                "web": {
+                    "$ref": "#/definitions/dto.WebChunk"
+                }
+            }
+        },
+        "dto.GroundingMetadata": {
+            "type": "object",
+            "properties": {
+                "groundingChunks": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.GroundingChunk"
+                    }
+                }
+            }
+        },
+        "dto.Health": {
+            "type": "object",
+            "properties": {
+                "accounts": {
+                    "description": "only in deep checks",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Account"
+                    }
+                },
+                "service": {
+                    "type": "string"
+                },
+                "status": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.HistoryResponse": {
+            "type": "object",
+            "properties": {
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Message"
+                    }
+                },
+                "session_id": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Image": {
+            "type": "object",
+            "properties": {
+                "alt_text": {
+                    "type": "string"
+                },
+                "generated": {
+                    "type": "boolean"
+                },
+                "title": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ImageData": {
+            "type": "object",
+            "properties": {
+                "b64_json": {
+                    "type": "string"
+                },
+                "revised_prompt": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ImageGenerationRequest": {
+            "type": "object",
+            "properties": {
+                "model": {
+                    "type": "string"
+                },
+                "n": {
+                    "description": "1-10, defaults to 1",
+                    "type": "integer"
+                },
+                "prompt": {
+                    "type": "string"
+                },
+                "response_format": {
+                    "description": "\"url\" (default) or \"b64_json\"",
+                    "type": "string"
+                },
+                "size": {
+                    "description": "\"WIDTHxHEIGHT\" or \"auto\", used as an aspect ratio hint",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ImageSource": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "description": "\"url\"",
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.ImagesResponse": {
+            "type": "object",
+            "properties": {
+                "created": {
+                    "type": "integer"
+                },
+                "data": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ImageData"
+                    }
+                }
+            }
+        },
+        "dto.InlineData": {
+            "type": "object",
+            "properties": {
+                "data": {
+                    "type": "string"
+                },
+                "mimeType": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Message": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "string"
+                },
+                "images": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Image"
+                    }
+                },
+                "role": {
+                    "description": "\"user\" or \"model\"",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.MessageRequest": {
+            "type": "object",
+            "properties": {
+                "max_tokens": {
+                    "type": "integer"
+                },
+                "messages": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Message"
+                    }
+                },
+                "model": {
+                    "type": "string"
+                },
+                "stream": {
+                    "type": "boolean"
+                },
+                "system": {
+                    "description": "Can be string or []interface{}"
+                },
+                "tool_choice": {
+                    "$ref": "#/definitions/dto.ToolChoice"
+                },
+                "tools": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_modules_claude_dto.Tool"
+                    }
+                }
+            }
+        },
+        "dto.MessageResponse": {
+            "type": "object",
+            "properties": {
+                "content": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.ConfigContent"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "role": {
+                    "description": "\"assistant\"",
+                    "type": "string"
+                },
+                "stop_reason": {
+                    "description": "absent in message_start",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "\"message\"",
+                    "type": "string"
+                },
+                "usage": {
+                    "$ref": "#/definitions/models.Usage"
+                }
+            }
+        },
+        "dto.OutputContent": {
+            "type": "object",
+            "properties": {
+                "annotations": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.Annotation"
+                    }
+                },
+                "text": {
+                    "type": "string"
+                },
+                "type": {
+                    "description": "\"output_text\"",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.OutputItem": {
+            "type": "object",
+            "properties": {
+                "arguments": {
+                    "type": "string"
+                },
+                "call_id": {
+                    "description": "function_call items",
+                    "type": "string"
+                },
+                "content": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.OutputContent"
+                    }
+                },
+                "id": {
+                    "type": "string"
+                },
+                "name": {
+                    "type": "string"
+                },
+                "role": {
+                    "description": "message items",
+                    "type": "string"
+                },
+                "status": {
+                    "description": "in_progress or completed",
+                    "type": "string"
+                },
+                "type": {
+                    "description": "\"message\" or \"function_call\"",
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Part": {
+            "type": "object",
+            "properties": {
+                "fileData": {
+                    "$ref": "#/definitions/dto.FileData"
+                },
+                "functionCall": {
+                    "$ref": "#/definitions/dto.FunctionCall"
+                },
+                "functionResponse": {
+                    "$ref": "#/definitions/dto.FunctionResponse"
+                },
+                "inlineData": {
+                    "$ref": "#/definitions/dto.InlineData"
+                },
+                "text": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Response": {
+            "type": "object",
+            "properties": {
+                "created_at": {
+                    "type": "integer"
+                },
+                "error": {
+                    "$ref": "#/definitions/dto.ResponseError"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "instructions": {
+                    "type": "string"
+                },
+                "metadata": {
+                    "type": "object",
+                    "additionalProperties": {
+                        "type": "string"
+                    }
+                },
+                "model": {
+                    "type": "string"
+                },
+                "object": {
+                    "description": "\"response\"",
+                    "type": "string"
+                },
+                "output": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/dto.OutputItem"
+                    }
+                },
+                "parallel_tool_calls": {
+                    "type": "boolean"
+                },
+                "previous_response_id": {
+                    "type": "string"
+                },
+                "status": {
+                    "description": "in_progress, completed or failed",
+                    "type": "string"
+                },
+                "store": {
+                    "type": "boolean"
+                },
+                "tool_choice": {},
+                "tools": {
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/internal_modules_responses_dto.Tool"
+                    }
+                },
+                "usage": {
+                    "$ref": "#/definitions/dto.Usage"
                 }
             }
         },
-        "dto.ConfigContent": {
+        "dto.ResponseError": {
             "type": "object",
             "properties": {
-                "text": {
+                "code": {
                     "type": "string"
                 },
-                "type": {
-                    "description": "\"text\"",
+                "message": {
                     "type": "string"
                 }
             }
         },
-        "dto.Content": {
+        "dto.SendMessageRequest": {
             "type": "object",
             "properties": {
-                "parts": {
+                "files": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/dto.Part"
+                        "$ref": "#/definitions/dto.File"
                     }
                 },
-                "role": {
+                "message": {
                     "type": "string"
+                },
+                "stream": {
+                    "type": "boolean"
                 }
             }
         },
-        "dto.GeminiGenerateRequest": {
+        "dto.SendMessageResponse": {
             "type": "object",
             "properties": {
-                "contents": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/dto.Content"
-                    }
+                "message": {
+                    "$ref": "#/definitions/dto.Message"
                 },
-                "generationConfig": {
-                    "$ref": "#/definitions/dto.GenerationConfig"
+                "session_id": {
+                    "type": "string"
                 },
-                "safety_settings": {
+                "sources": {
                     "type": "array",
                     "items": {
-                        "type": "object",
-                        "additionalProperties": {
-                            "type": "string"
-                        }
+                        "$ref": "#/definitions/dto.Source"
                     }
                 }
             }
         },
-        "dto.GeminiGenerateResponse": {
+        "dto.Session": {
             "type": "object",
             "properties": {
-                "candidates": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/dto.Candidate"
-                    }
+                "account": {
+                    "type": "string"
                 },
-                "usageMetadata": {
-                    "$ref": "#/definitions/dto.UsageMetadata"
+                "choice_id": {
+                    "type": "string"
+                },
+                "conversation_id": {
+                    "type": "string"
+                },
+                "created_at": {
+                    "type": "string"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "message_count": {
+                    "type": "integer"
+                },
+                "model": {
+                    "type": "string"
+                },
+                "object": {
+                    "description": "\"session\"",
+                    "type": "string"
+                },
+                "response_id": {
+                    "type": "string"
+                },
+                "updated_at": {
+                    "type": "string"
                 }
             }
         },
-        "dto.GeminiModel": {
+        "dto.Source": {
             "type": "object",
             "properties": {
-                "description": {
+                "title": {
                     "type": "string"
                 },
-                "displayName": {
+                "url": {
                     "type": "string"
+                }
+            }
+        },
+        "dto.StreamOptions": {
+            "type": "object",
+            "properties": {
+                "include_usage": {
+                    "type": "boolean"
+                }
+            }
+        },
+        "dto.ToolChoice": {
+            "type": "object",
+            "properties": {
+                "disable_parallel_tool_use": {
+                    "type": "boolean"
                 },
                 "name": {
                     "type": "string"
                 },
-                "supportedGenerationMethods": {
-                    "type": "array",
-                    "items": {
-                        "type": "string"
-                    }
-                },
-                "version": {
+                "type": {
+                    "description": "\"auto\", \"any\", \"tool\" or \"none\"",
                     "type": "string"
                 }
             }
         },
-        "dto.GeminiModelsResponse": {
+        "dto.ToolConfig": {
             "type": "object",
             "properties": {
-                "models": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/dto.GeminiModel"
-                    }
+                "functionCallingConfig": {
+                    "$ref": "#/definitions/dto.FunctionCallingConfig"
                 }
             }
         },
-        "dto.GenerationConfig": {
+        "dto.UpstreamError": {
             "type": "object",
             "properties": {
-                "maxOutputTokens": {
+                "at": {
+                    "type": "string"
+                },
+                "message": {
+                    "type": "string"
+                }
+            }
+        },
+        "dto.Usage": {
+            "type": "object",
+            "properties": {
+                "input_tokens": {
                     "type": "integer"
                 },
-                "temperature": {
-                    "type": "number"
+                "output_tokens": {
+                    "type": "integer"
                 },
-                "topK": {
+                "total_tokens": {
+                    "type": "integer"
+                }
+            }
+        },
+        "dto.UsageMetadata": {
+            "type": "object",
+            "properties": {
+                "candidatesTokenCount": {
                     "type": "integer"
                 },
-                "topP": {
-                    "type": "number"
+                "promptTokenCount": {
+                    "type": "integer"
+                },
+                "totalTokenCount": {
+                    "type": "integer"
                 }
             }
         },
-        "dto.InlineData": {
+        "dto.WebChunk": {
             "type": "object",
             "properties": {
-                "data": {
+                "title": {
                     "type": "string"
                 },
-                "mimeType": {
+                "uri": {
                     "type": "string"
                 }
             }
         },
-        "dto.MessageRequest": {
+        "internal_modules_claude_dto.Tool": {
             "type": "object",
             "properties": {
-                "max_tokens": {
-                    "type": "integer"
+                "description": {
+                    "type": "string"
                 },
-                "messages": {
-                    "type": "array",
-                    "items": {
-                        "$ref": "#/definitions/models.Message"
-                    }
+                "input_schema": {
+                    "description": "JSON schema"
                 },
-                "model": {
+                "name": {
                     "type": "string"
                 },
-                "stream": {
-                    "type": "boolean"
-                },
-                "system": {
+                "type": {
+                    "description": "\"custom\" or empty for client tools",
                     "type": "string"
                 }
             }
         },
-        "dto.MessageResponse": {
+        "internal_modules_gemini_dto.Tool": {
             "type": "object",
             "properties": {
-                "content": {
+                "functionDeclarations": {
                     "type": "array",
                     "items": {
-                        "$ref": "#/definitions/dto.ConfigContent"
+                        "$ref": "#/definitions/dto.FunctionDeclaration"
                     }
+                }
+            }
+        },
+        "internal_modules_openai_dto.Tool": {
+            "type": "object",
+            "properties": {
+                "function": {
+                    "$ref": "#/definitions/dto.FunctionDefinition"
                 },
-                "id": {
+                "type": {
+                    "description": "\"function\"",
+                    "type": "string"
+                }
+            }
+        },
+        "internal_modules_responses_dto.Tool": {
+            "type": "object",
+            "properties": {
+                "description": {
                     "type": "string"
                 },
-                "model": {
+                "name": {
                     "type": "string"
                 },
-                "role": {
-                    "description": "\"assistant\"",
+                "parameters": {
+                    "description": "JSON schema"
+                },
+                "strict": {
+                    "type": "boolean"
+                },
+                "type": {
+                    "description": "\"function\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.Annotation": {
+            "type": "object",
+            "properties": {
+                "type": {
+                    "description": "\"url_citation\"",
                     "type": "string"
                 },
-                "stop_reason": {
+                "url_citation": {
+                    "$ref": "#/definitions/models.URLCitation"
+                }
+            }
+        },
+        "models.Citation": {
+            "type": "object",
+            "properties": {
+                "cited_text": {
+                    "type": "string"
+                },
+                "title": {
                     "type": "string"
                 },
                 "type": {
-                    "description": "\"message\"",
+                    "description": "\"web_search_result_location\"",
                     "type": "string"
                 },
-                "usage": {
-                    "$ref": "#/definitions/models.Usage"
+                "url": {
+                    "type": "string"
                 }
             }
         },
-        "dto.Part": {
+        "models.FunctionCall": {
             "type": "object",
             "properties": {
-                "inlineData": {
-                    "$ref": "#/definitions/dto.InlineData"
+                "arguments": {
+                    "description": "JSON-encoded arguments",
+                    "type": "string"
                 },
-                "text": {
+                "name": {
                     "type": "string"
                 }
             }
         },
-        "dto.UsageMetadata": {
+        "models.ImagePart": {
             "type": "object",
             "properties": {
-                "candidatesTokenCount": {
-                    "type": "integer"
-                },
-                "promptTokenCount": {
-                    "type": "integer"
+                "image_url": {
+                    "$ref": "#/definitions/models.ImageURL"
                 },
-                "totalTokenCount": {
-                    "type": "integer"
+                "type": {
+                    "description": "\"image_url\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.ImageURL": {
+            "type": "object",
+            "properties": {
+                "url": {
+                    "type": "string"
                 }
             }
         },
         "models.Message": {
             "type": "object",
             "properties": {
+                "annotations": {
+                    "description": "web sources cited by an assistant reply",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.Annotation"
+                    }
+                },
                 "content": {
+                    "description": "Can be string or []interface{}"
+                },
+                "images": {
+                    "description": "images shown with an assistant reply",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ImagePart"
+                    }
+                },
+                "name": {
                     "type": "string"
                 },
                 "role": {
                     "type": "string"
+                },
+                "tool_call_id": {
+                    "description": "OpenAI role \"tool\" results",
+                    "type": "string"
+                },
+                "tool_calls": {
+                    "description": "OpenAI assistant tool calls",
+                    "type": "array",
+                    "items": {
+                        "$ref": "#/definitions/models.ToolCall"
+                    }
                 }
             }
         },
@@ -696,6 +2107,36 @@ const docTemplate = `{
                 }
             }
         },
+        "models.ToolCall": {
+            "type": "object",
+            "properties": {
+                "function": {
+                    "$ref": "#/definitions/models.FunctionCall"
+                },
+                "id": {
+                    "type": "string"
+                },
+                "index": {
+                    "description": "only in streaming deltas",
+                    "type": "integer"
+                },
+                "type": {
+                    "description": "\"function\"",
+                    "type": "string"
+                }
+            }
+        },
+        "models.URLCitation": {
+            "type": "object",
+            "properties": {
+                "title": {
+                    "type": "string"
+                },
+                "url": {
+                    "type": "string"
+                }
+            }
+        },
         "models.Usage": {
             "type": "object",
             "properties": {
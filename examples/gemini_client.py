@@ -10,7 +10,7 @@ client = genai.Client(
 )
 
 response = client.models.generate_content(
-    model="gemini-1.5-flash",
+    model="gemini-3-flash",
     contents="Hello?"
 )
 
@@ -2,18 +2,26 @@ package configs
 
 import (
 	"fmt"
+	"net/url"
 	"os"
 	"strconv"
+	"strings"
 
 	"github.com/joho/godotenv"
 )
 
 type Config struct {
-	Gemini GeminiConfig
-	Claude ClaudeConfig
-	OpenAI OpenAIConfig
-	Server ServerConfig	
-	LogLevel string
+	Gemini       GeminiConfig
+	Claude       ClaudeConfig
+	OpenAI       OpenAIConfig
+	Server       ServerConfig
+	Auth         AuthConfig
+	Conversation ConversationConfig
+	Session      SessionConfig
+	Metrics      MetricsConfig
+	Tracing      TracingConfig
+	LogLevel     string
+	ModelsFile   string
 }
 
 type GeminiConfig struct {
@@ -22,6 +30,19 @@ type GeminiConfig struct {
 	RefreshInterval int
 	MaxRetries      int
 	Cookies         string
+	Accounts        []GeminiAccount
+	PoolStrategy    string
+	AccountCooldown int    // seconds
+	CircuitFailures int    // consecutive network or parse failures that open an account's circuit
+	BaseURL         string // serves every upstream endpoint instead of Google, e.g. a fake Gemini server
+	Mock            bool   // answer from the built-in fake Gemini backend instead of Google
+}
+
+// GeminiAccount holds the cookies of one Google account in the pool
+type GeminiAccount struct {
+	Name          string
+	Secure1PSID   string
+	Secure1PSIDTS string
 }
 
 type ClaudeConfig struct {
@@ -37,14 +58,77 @@ type OpenAIConfig struct {
 }
 
 type ServerConfig struct {
-	Port     string
+	Port string
+}
+
+// ConversationConfig configures the cache that maps message histories to
+// Gemini conversations, so follow-up requests only send the new turn
+type ConversationConfig struct {
+	Enabled    bool
+	TTL        int // seconds since last use
+	MaxEntries int
+}
+
+// SessionConfig configures the store behind chat sessions. The conversation
+// cache and stored responses are kept in the same backend.
+type SessionConfig struct {
+	Store      string // SessionStoreMemory or SessionStoreFile
+	Dir        string // root directory of the file store
+	TTL        int    // seconds since last use
+	MaxEntries int
+}
+
+// MetricsConfig configures the Prometheus /metrics endpoint
+type MetricsConfig struct {
+	Enabled bool
+}
+
+// TracingConfig configures OpenTelemetry tracing. The OTLP exporter reads its
+// endpoint and headers from the standard OTEL_EXPORTER_OTLP_* variables.
+type TracingConfig struct {
+	Exporter string // TracingExporterNone, TracingExporterOTLP or TracingExporterStdout
+}
+
+// AuthConfig configures API keys for the proxy itself. Authentication is
+// disabled when neither a keys file nor inline keys are configured.
+type AuthConfig struct {
+	KeysFile string
+	Keys     []string
 }
 
 const (
 	defaultServerPort            = "4981"
 	defaultGeminiRefreshInterval = 5
 	defaultGeminiMaxRetries      = 3
+	defaultGeminiPoolStrategy    = PoolStrategyRoundRobin
+	defaultGeminiAccountCooldown = 300
+	defaultGeminiCircuitFailures = 5
 	defaultLogLevel              = "info"
+	defaultConversationTTL       = 3600
+	defaultConversationEntries   = 1000
+	defaultSessionStore          = SessionStoreMemory
+	defaultSessionDir            = "data/sessions"
+	defaultSessionTTL            = 86400
+	defaultSessionEntries        = 1000
+)
+
+// Account dispatch strategies for GEMINI_POOL_STRATEGY
+const (
+	PoolStrategyRoundRobin    = "round_robin"
+	PoolStrategyLeastInFlight = "least_in_flight"
+)
+
+// Trace exporters for OTEL_TRACES_EXPORTER
+const (
+	TracingExporterNone   = "none"
+	TracingExporterOTLP   = "otlp"
+	TracingExporterStdout = "stdout"
+)
+
+// Session store backends for SESSION_STORE
+const (
+	SessionStoreMemory = "memory"
+	SessionStoreFile   = "file"
 )
 
 func New() (*Config, error) {
@@ -55,9 +139,35 @@ func New() (*Config, error) {
 
 	// Server
 	cfg.Server.Port = getEnv("PORT", defaultServerPort)
-	
+
 	// General
 	cfg.LogLevel = getEnv("LOG_LEVEL", defaultLogLevel)
+	cfg.ModelsFile = os.Getenv("MODELS_FILE")
+
+	// Auth
+	cfg.Auth.KeysFile = os.Getenv("API_KEYS_FILE")
+	for _, key := range strings.Split(os.Getenv("API_KEYS"), ",") {
+		if key = strings.TrimSpace(key); key != "" {
+			cfg.Auth.Keys = append(cfg.Auth.Keys, key)
+		}
+	}
+
+	// Conversations
+	cfg.Conversation.Enabled = getEnvBool("CONVERSATION_CACHE", true)
+	cfg.Conversation.TTL = getEnvInt("CONVERSATION_TTL", defaultConversationTTL)
+	cfg.Conversation.MaxEntries = getEnvInt("CONVERSATION_CACHE_SIZE", defaultConversationEntries)
+
+	// Sessions
+	cfg.Session.Store = getEnv("SESSION_STORE", defaultSessionStore)
+	cfg.Session.Dir = getEnv("SESSION_DIR", defaultSessionDir)
+	cfg.Session.TTL = getEnvInt("SESSION_TTL", defaultSessionTTL)
+	cfg.Session.MaxEntries = getEnvInt("SESSION_MAX_ENTRIES", defaultSessionEntries)
+
+	// Metrics
+	cfg.Metrics.Enabled = getEnvBool("METRICS_ENABLED", true)
+
+	// Tracing
+	cfg.Tracing.Exporter = getEnv("OTEL_TRACES_EXPORTER", TracingExporterNone)
 
 	// Gemini
 	cfg.Gemini.Secure1PSID = os.Getenv("GEMINI_1PSID")
@@ -65,6 +175,28 @@ func New() (*Config, error) {
 	cfg.Gemini.Cookies = os.Getenv("GEMINI_COOKIES")
 	cfg.Gemini.RefreshInterval = getEnvInt("GEMINI_REFRESH_INTERVAL", defaultGeminiRefreshInterval)
 	cfg.Gemini.MaxRetries = getEnvInt("GEMINI_MAX_RETRIES", defaultGeminiMaxRetries)
+	cfg.Gemini.PoolStrategy = getEnv("GEMINI_POOL_STRATEGY", defaultGeminiPoolStrategy)
+	cfg.Gemini.AccountCooldown = getEnvInt("GEMINI_ACCOUNT_COOLDOWN", defaultGeminiAccountCooldown)
+	cfg.Gemini.CircuitFailures = getEnvInt("GEMINI_CIRCUIT_FAILURES", defaultGeminiCircuitFailures)
+	cfg.Gemini.BaseURL = os.Getenv("GEMINI_BASE_URL")
+	cfg.Gemini.Mock = getEnvBool("GEMINI_MOCK", false)
+
+	accounts, err := parseAccounts(os.Getenv("GEMINI_ACCOUNTS"))
+	if err != nil {
+		return nil, err
+	}
+	if cfg.Gemini.Secure1PSID != "" {
+		accounts = append([]GeminiAccount{{
+			Name:          "default",
+			Secure1PSID:   cfg.Gemini.Secure1PSID,
+			Secure1PSIDTS: cfg.Gemini.Secure1PSIDTS,
+		}}, accounts...)
+	}
+	if cfg.Gemini.Mock && len(accounts) == 0 {
+		// The fake backend accepts any cookies
+		accounts = []GeminiAccount{{Name: "mock", Secure1PSID: "mock-psid", Secure1PSIDTS: "mock-psidts"}}
+	}
+	cfg.Gemini.Accounts = accounts
 
 	// Validate configuration
 	if err := cfg.Validate(); err != nil {
@@ -78,9 +210,9 @@ func New() (*Config, error) {
 func (c *Config) Validate() error {
 	var missingVars []string
 
-	// Check Gemini configuration - at least one of these should be present
-	if c.Gemini.Secure1PSID == "" {
-		missingVars = append(missingVars, "GEMINI_1PSID")
+	// Check Gemini configuration - at least one account should be present
+	if c.Gemini.Secure1PSID == "" && len(c.Gemini.Accounts) == 0 {
+		missingVars = append(missingVars, "GEMINI_1PSID (or GEMINI_ACCOUNTS)")
 	}
 
 	if c.Gemini.Secure1PSID != "" {
@@ -90,6 +222,33 @@ func (c *Config) Validate() error {
 		}
 	}
 
+	switch c.Gemini.PoolStrategy {
+	case PoolStrategyRoundRobin, PoolStrategyLeastInFlight:
+	default:
+		return fmt.Errorf("invalid GEMINI_POOL_STRATEGY value: %q (must be %s or %s)",
+			c.Gemini.PoolStrategy, PoolStrategyRoundRobin, PoolStrategyLeastInFlight)
+	}
+
+	switch c.Session.Store {
+	case SessionStoreMemory, SessionStoreFile:
+	default:
+		return fmt.Errorf("invalid SESSION_STORE value: %q (must be %s or %s)",
+			c.Session.Store, SessionStoreMemory, SessionStoreFile)
+	}
+
+	switch c.Tracing.Exporter {
+	case TracingExporterNone, TracingExporterOTLP, TracingExporterStdout:
+	default:
+		return fmt.Errorf("invalid OTEL_TRACES_EXPORTER value: %q (must be %s, %s or %s)",
+			c.Tracing.Exporter, TracingExporterNone, TracingExporterOTLP, TracingExporterStdout)
+	}
+
+	if c.Gemini.BaseURL != "" {
+		if u, err := url.Parse(c.Gemini.BaseURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid GEMINI_BASE_URL value: %q (must be an http(s) URL)", c.Gemini.BaseURL)
+		}
+	}
+
 	// Check Server port is valid
 	if c.Server.Port == "" {
 		c.Server.Port = defaultServerPort
@@ -106,6 +265,36 @@ func (c *Config) Validate() error {
 	return nil
 }
 
+// parseAccounts parses GEMINI_ACCOUNTS: a comma-separated list of
+// "PSID:PSIDTS" pairs, each optionally prefixed with "name=".
+func parseAccounts(value string) ([]GeminiAccount, error) {
+	var accounts []GeminiAccount
+	for i, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+
+		name := fmt.Sprintf("account-%d", i+1)
+		if n, rest, ok := strings.Cut(entry, "="); ok && !strings.Contains(n, ":") {
+			name, entry = strings.TrimSpace(n), rest
+		}
+
+		psid, psidts, ok := strings.Cut(entry, ":")
+		psid, psidts = strings.TrimSpace(psid), strings.TrimSpace(psidts)
+		if !ok || psid == "" || psidts == "" {
+			return nil, fmt.Errorf("invalid GEMINI_ACCOUNTS entry #%d: expected [name=]PSID:PSIDTS", i+1)
+		}
+
+		accounts = append(accounts, GeminiAccount{
+			Name:          name,
+			Secure1PSID:   psid,
+			Secure1PSIDTS: psidts,
+		})
+	}
+	return accounts, nil
+}
+
 func getEnv(key, defaultValue string) string {
 	if value, exists := os.LookupEnv(key); exists {
 		return value
@@ -125,4 +314,10 @@ func getEnvInt(key string, defaultValue int) int {
 	return value
 }
 
-
+func getEnvBool(key string, defaultValue bool) bool {
+	value, err := strconv.ParseBool(os.Getenv(key))
+	if err != nil {
+		return defaultValue
+	}
+	return value
+}
@@ -0,0 +1,142 @@
+// Package metrics holds the Prometheus collectors of the proxy. They live on a
+// dedicated registry served at /metrics.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// Namespace prefixes every metric of the proxy
+const Namespace = "gemini_web_to_api"
+
+// Upstream operations, used as the "operation" label
+const (
+	OperationGenerate       = "generate"
+	OperationGenerateStream = "generate_stream"
+	OperationUpload         = "upload"
+	OperationDownloadImage  = "download_image"
+	OperationBatchExecute   = "batchexecute"
+)
+
+// Results of cookie rotations and session token refreshes
+const (
+	ResultSuccess = "success"
+	ResultFailure = "failure"
+)
+
+// Registry holds every collector of the proxy plus the Go runtime and process collectors
+var Registry = prometheus.NewRegistry()
+
+var (
+	// Requests counts API requests by surface, route, model and response status
+	Requests = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "requests_total",
+		Help:      "API requests by API surface, route, model and HTTP status.",
+	}, []string{"surface", "route", "model", "status"})
+
+	// RequestDuration measures API request handling time. Streaming responses
+	// are measured until the stream starts.
+	RequestDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "request_duration_seconds",
+		Help:      "API request handling time by API surface and route (until the stream starts for streaming responses).",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"surface", "route"})
+
+	// UpstreamDuration measures Gemini round trips, labelled with the HTTP status or "error"
+	UpstreamDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "upstream_request_duration_seconds",
+		Help:      "Gemini web request latency by operation, account and HTTP status (\"error\" when no response was received).",
+		Buckets:   []float64{.25, .5, 1, 2, 4, 8, 15, 30, 60, 120},
+	}, []string{"operation", "account", "status"})
+
+	// ParseDuration measures how long a buffered StreamGenerate body takes to parse
+	ParseDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "upstream_parse_duration_seconds",
+		Help:      "Time spent parsing buffered StreamGenerate responses.",
+		Buckets:   prometheus.ExponentialBuckets(.0001, 4, 8),
+	})
+
+	// Retries counts upstream attempts after the first
+	Retries = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "upstream_retries_total",
+		Help:      "Upstream attempts made after the first one, by operation and account.",
+	}, []string{"operation", "account"})
+
+	// ParseFailures counts StreamGenerate bodies without a usable response
+	ParseFailures = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "upstream_parse_failures_total",
+		Help:      "StreamGenerate responses that could not be parsed, by account.",
+	}, []string{"account"})
+
+	// CookieRotations counts __Secure-1PSIDTS rotations by result
+	CookieRotations = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "cookie_rotations_total",
+		Help:      "Cookie rotations by account and result.",
+	}, []string{"account", "result"})
+
+	// SessionTokenRefreshes counts SNlM0e session token fetches by result
+	SessionTokenRefreshes = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: Namespace,
+		Name:      "session_token_refreshes_total",
+		Help:      "SNlM0e session token refreshes by account and result.",
+	}, []string{"account", "result"})
+
+	// TimeToFirstToken measures how long streamed replies take to produce text
+	TimeToFirstToken = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: Namespace,
+		Name:      "stream_time_to_first_token_seconds",
+		Help:      "Time from a streaming request to its first text delta, by model.",
+		Buckets:   []float64{.25, .5, 1, 2, 3, 5, 8, 13, 20, 30},
+	}, []string{"model"})
+)
+
+func init() {
+	Registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		Requests,
+		RequestDuration,
+		UpstreamDuration,
+		ParseDuration,
+		Retries,
+		ParseFailures,
+		CookieRotations,
+		SessionTokenRefreshes,
+		TimeToFirstToken,
+	)
+}
+
+// Handler serves the registry in the Prometheus text format
+func Handler() http.Handler {
+	return promhttp.HandlerFor(Registry, promhttp.HandlerOpts{Registry: Registry})
+}
+
+// ObserveUpstream records an upstream round trip; status is 0 when no response was received
+func ObserveUpstream(operation, account string, status int, duration time.Duration) {
+	label := "error"
+	if status > 0 {
+		label = strconv.Itoa(status)
+	}
+	UpstreamDuration.WithLabelValues(operation, account, label).Observe(duration.Seconds())
+}
+
+// Result returns the result label of an operation that failed with err
+func Result(err error) string {
+	if err != nil {
+		return ResultFailure
+	}
+	return ResultSuccess
+}
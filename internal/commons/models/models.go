@@ -4,6 +4,50 @@ package models
 type Message struct {
 	Role    string      `json:"role"`
 	Content interface{} `json:"content"` // Can be string or []interface{}
+
+	Name       string     `json:"name,omitempty"`
+	ToolCalls  []ToolCall `json:"tool_calls,omitempty"`   // OpenAI assistant tool calls
+	ToolCallID string     `json:"tool_call_id,omitempty"` // OpenAI role "tool" results
+
+	Images      []ImagePart  `json:"images,omitempty"`      // images shown with an assistant reply
+	Annotations []Annotation `json:"annotations,omitempty"` // web sources cited by an assistant reply
+}
+
+// ImagePart represents an image attached to an assistant reply
+type ImagePart struct {
+	Type     string   `json:"type"` // "image_url"
+	ImageURL ImageURL `json:"image_url"`
+}
+
+// ImageURL holds the location of an image
+type ImageURL struct {
+	URL string `json:"url"`
+}
+
+// Annotation represents an OpenAI message annotation
+type Annotation struct {
+	Type        string       `json:"type"` // "url_citation"
+	URLCitation *URLCitation `json:"url_citation,omitempty"`
+}
+
+// URLCitation represents a web page cited by the reply
+type URLCitation struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// ToolCall represents an OpenAI tool call made by the assistant
+type ToolCall struct {
+	Index    *int         `json:"index,omitempty"` // only in streaming deltas
+	ID       string       `json:"id,omitempty"`
+	Type     string       `json:"type,omitempty"` // "function"
+	Function FunctionCall `json:"function"`
+}
+
+// FunctionCall represents the function invoked by a tool call
+type FunctionCall struct {
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments"` // JSON-encoded arguments
 }
 
 // ModelListResponse represents the list of models
@@ -29,6 +73,21 @@ type Delta struct {
 	Content string `json:"content,omitempty"` // for OpenAI
 	Text    string `json:"text,omitempty"`    // for Claude
 	Role    string `json:"role,omitempty"`
+
+	ToolCalls   []ToolCall   `json:"tool_calls,omitempty"`   // for OpenAI
+	Images      []ImagePart  `json:"images,omitempty"`       // for OpenAI
+	Annotations []Annotation `json:"annotations,omitempty"`  // for OpenAI
+	PartialJSON string       `json:"partial_json,omitempty"` // for Claude input_json_delta
+	Citation    *Citation    `json:"citation,omitempty"`     // for Claude citations_delta
+	StopReason  string       `json:"stop_reason,omitempty"`  // for Claude message_delta
+}
+
+// Citation represents an Anthropic web citation attached to a text block
+type Citation struct {
+	Type      string `json:"type"` // "web_search_result_location"
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	CitedText string `json:"cited_text"`
 }
 
 // Usage represents token usage (compatible format)
@@ -0,0 +1,95 @@
+// Package tracing wraps the OpenTelemetry tracer of the proxy. Spans are no-ops
+// until a tracer provider is installed with NewProvider.
+package tracing
+
+import (
+	"context"
+	"fmt"
+	"os"
+
+	"gemini-web-to-api/internal/commons/configs"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/propagation"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.37.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// ServiceName is the service.name reported when OTEL_SERVICE_NAME is not set
+const ServiceName = "gemini-web-to-api"
+
+// Span attributes shared across layers
+const (
+	AttrSurface      = attribute.Key("api.surface")
+	AttrModel        = attribute.Key("gen_ai.request.model")
+	AttrAccount      = attribute.Key("gemini.account")
+	AttrAttempt      = attribute.Key("gemini.attempt")
+	AttrPromptSize   = attribute.Key("gemini.prompt.size")   // bytes
+	AttrResponseSize = attribute.Key("gemini.response.size") // bytes
+	AttrFiles        = attribute.Key("gemini.files")
+)
+
+const tracerName = "gemini-web-to-api"
+
+// Start starts a span named name as a child of the span in ctx
+func Start(ctx context.Context, name string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(tracerName).Start(ctx, name, trace.WithAttributes(attrs...))
+}
+
+// End marks span as failed when err is set and ends it
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
+
+// NewProvider creates the tracer provider for the configured exporter, or
+// returns nil when tracing is disabled. It also installs W3C trace context
+// propagation, so incoming traceparent headers are honoured either way.
+// Samplers and OTLP endpoints follow the standard OTEL_* environment variables.
+func NewProvider(ctx context.Context, cfg configs.TracingConfig) (*sdktrace.TracerProvider, error) {
+	otel.SetTextMapPropagator(propagation.NewCompositeTextMapPropagator(
+		propagation.TraceContext{},
+		propagation.Baggage{},
+	))
+
+	var exporter sdktrace.SpanExporter
+	var err error
+	switch cfg.Exporter {
+	case configs.TracingExporterNone:
+		return nil, nil
+	case configs.TracingExporterOTLP:
+		exporter, err = otlptracehttp.New(ctx)
+	case configs.TracingExporterStdout:
+		exporter, err = stdouttrace.New(stdouttrace.WithWriter(os.Stdout))
+	default:
+		return nil, fmt.Errorf("unknown trace exporter %q", cfg.Exporter)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s trace exporter: %w", cfg.Exporter, err)
+	}
+
+	// Environment attributes (OTEL_SERVICE_NAME, OTEL_RESOURCE_ATTRIBUTES) win over the default name
+	res, err := resource.Merge(
+		resource.NewSchemaless(semconv.ServiceName(ServiceName)),
+		resource.Environment(),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+	return provider, nil
+}
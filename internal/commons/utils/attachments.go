@@ -0,0 +1,149 @@
+package utils
+
+import (
+	"encoding/base64"
+	"fmt"
+	"mime"
+	"strings"
+
+	"gemini-web-to-api/internal/commons/models"
+)
+
+// Attachment is an image or document sent along with a prompt
+type Attachment struct {
+	Name     string
+	MimeType string
+	Data     []byte
+}
+
+// fileExtensions covers the common attachment types; mime.ExtensionsByType is
+// used for the rest but its answer depends on the host's mime tables
+var fileExtensions = map[string]string{
+	"image/png":       ".png",
+	"image/jpeg":      ".jpg",
+	"image/gif":       ".gif",
+	"image/webp":      ".webp",
+	"image/heic":      ".heic",
+	"application/pdf": ".pdf",
+	"text/plain":      ".txt",
+	"text/csv":        ".csv",
+	"text/html":       ".html",
+	"text/markdown":   ".md",
+}
+
+// NewAttachment decodes base64 data into an attachment. The file name is
+// derived from index and the MIME type, since Gemini infers the type from it.
+func NewAttachment(mimeType, data string, index int) (Attachment, error) {
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	if err != nil {
+		return Attachment{}, InvalidRequestf("attachment %d: invalid base64 data: %w", index, err)
+	}
+
+	mimeType = strings.ToLower(strings.TrimSpace(mimeType))
+	ext, ok := fileExtensions[mimeType]
+	if !ok {
+		if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 {
+			ext = exts[0]
+		} else {
+			ext = ".bin"
+		}
+	}
+
+	kind := "file"
+	if strings.HasPrefix(mimeType, "image/") {
+		kind = "image"
+	}
+	return Attachment{
+		Name:     fmt.Sprintf("%s_%d%s", kind, index, ext),
+		MimeType: mimeType,
+		Data:     decoded,
+	}, nil
+}
+
+// DecodeDataURL decodes a base64 "data:<mime>;base64,<data>" URL
+func DecodeDataURL(url string, index int) (Attachment, error) {
+	rest, ok := strings.CutPrefix(url, "data:")
+	if !ok {
+		return Attachment{}, InvalidRequestf("attachment %d: only base64 data URLs are supported, remote URLs are not fetched", index)
+	}
+	meta, data, ok := strings.Cut(rest, ",")
+	if !ok || !strings.HasSuffix(meta, ";base64") {
+		return Attachment{}, InvalidRequestf("attachment %d: data URL must be base64 encoded", index)
+	}
+	return NewAttachment(strings.TrimSuffix(meta, ";base64"), data, index)
+}
+
+// hasAttachments reports whether a message carries an image or document block
+func hasAttachments(msg models.Message) bool {
+	blocks, _ := msg.Content.([]interface{})
+	for _, block := range blocks {
+		if m, ok := block.(map[string]interface{}); ok {
+			switch m["type"] {
+			case "image_url", "file", "image", "document":
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ExtractAttachments collects the images and documents of all messages, from
+// OpenAI (image_url, file) and Claude (image, document) content blocks
+func ExtractAttachments(messages []models.Message) ([]Attachment, error) {
+	var attachments []Attachment
+	for _, msg := range messages {
+		blocks, ok := msg.Content.([]interface{})
+		if !ok {
+			continue
+		}
+		for _, block := range blocks {
+			m, ok := block.(map[string]interface{})
+			if !ok {
+				continue
+			}
+
+			index := len(attachments) + 1
+			var attachment Attachment
+			var err error
+			switch m["type"] {
+			case "image_url":
+				// OpenAI: {"image_url": {"url": "data:..."}} or {"image_url": "data:..."}
+				url, _ := m["image_url"].(string)
+				if obj, ok := m["image_url"].(map[string]interface{}); ok {
+					url, _ = obj["url"].(string)
+				}
+				attachment, err = DecodeDataURL(url, index)
+			case "file":
+				// OpenAI: {"file": {"file_data": "data:...", "filename": "..."}}
+				obj, _ := m["file"].(map[string]interface{})
+				data, _ := obj["file_data"].(string)
+				if data == "" {
+					return nil, InvalidRequestf("attachment %d: file_id references are not supported, send file_data", index)
+				}
+				attachment, err = DecodeDataURL(data, index)
+			case "image", "document":
+				// Claude: {"source": {"type": "base64", "media_type": "...", "data": "..."}}
+				source, _ := m["source"].(map[string]interface{})
+				sourceType, _ := source["type"].(string)
+				mediaType, _ := source["media_type"].(string)
+				data, _ := source["data"].(string)
+				switch sourceType {
+				case "base64":
+					attachment, err = NewAttachment(mediaType, data, index)
+				case "text":
+					// Plain text documents are sent as .txt files
+					attachment = Attachment{Name: fmt.Sprintf("file_%d.txt", index), MimeType: "text/plain", Data: []byte(data)}
+				default:
+					return nil, InvalidRequestf("attachment %d: unsupported %s source type %q, send base64 data", index, m["type"], sourceType)
+				}
+			default:
+				continue
+			}
+			if err != nil {
+				return nil, err
+			}
+			attachments = append(attachments, attachment)
+		}
+	}
+	return attachments, nil
+}
@@ -0,0 +1,107 @@
+package utils
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// LRU is a size-bounded in-memory cache whose entries expire after ttl without
+// use. It is safe for concurrent use.
+type LRU[V any] struct {
+	maxEntries int           // zero or less disables the size limit
+	ttl        time.Duration // zero disables expiry
+
+	mu      sync.Mutex
+	entries map[string]*list.Element
+	order   *list.List // most recently used first
+}
+
+type lruEntry[V any] struct {
+	key      string
+	value    V
+	lastUsed time.Time
+}
+
+// NewLRU creates a cache holding at most maxEntries values
+func NewLRU[V any](maxEntries int, ttl time.Duration) *LRU[V] {
+	return &LRU[V]{
+		maxEntries: maxEntries,
+		ttl:        ttl,
+		entries:    make(map[string]*list.Element),
+		order:      list.New(),
+	}
+}
+
+// Get returns the value stored under key and marks it as used
+func (c *LRU[V]) Get(key string) (V, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	var zero V
+	elem, ok := c.entries[key]
+	if !ok {
+		return zero, false
+	}
+	e := elem.Value.(*lruEntry[V])
+	if c.ttl > 0 && time.Since(e.lastUsed) > c.ttl {
+		c.order.Remove(elem)
+		delete(c.entries, key)
+		return zero, false
+	}
+	e.lastUsed = time.Now()
+	c.order.MoveToFront(elem)
+	return e.value, true
+}
+
+// Add stores value under key, evicting expired entries and the least recently
+// used ones beyond the size limit
+func (c *LRU[V]) Add(key string, value V) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.removeExpired(time.Now())
+	if elem, ok := c.entries[key]; ok {
+		e := elem.Value.(*lruEntry[V])
+		e.value, e.lastUsed = value, time.Now()
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.entries[key] = c.order.PushFront(&lruEntry[V]{key: key, value: value, lastUsed: time.Now()})
+	for c.maxEntries > 0 && c.order.Len() > c.maxEntries {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.entries, oldest.Value.(*lruEntry[V]).key)
+	}
+}
+
+// removeExpired drops the entries unused for longer than ttl. They are at the
+// back of the list, so entries nobody reads again do not pile up.
+func (c *LRU[V]) removeExpired(now time.Time) {
+	if c.ttl <= 0 {
+		return
+	}
+	for oldest := c.order.Back(); oldest != nil; oldest = c.order.Back() {
+		e := oldest.Value.(*lruEntry[V])
+		if now.Sub(e.lastUsed) <= c.ttl {
+			return
+		}
+		c.order.Remove(oldest)
+		delete(c.entries, e.key)
+	}
+}
+
+// Remove deletes key and reports whether it was present
+func (c *LRU[V]) Remove(key string) bool {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.entries[key]
+	if !ok {
+		return false
+	}
+	c.order.Remove(elem)
+	delete(c.entries, key)
+	return true
+}
@@ -0,0 +1,39 @@
+package utils
+
+import (
+	"testing"
+	"time"
+)
+
+func TestLRUEvictsBeyondLimit(t *testing.T) {
+	c := NewLRU[int](2, 0)
+	c.Add("a", 1)
+	c.Add("b", 2)
+	c.Get("a")
+	c.Add("c", 3)
+
+	if _, ok := c.Get("b"); ok {
+		t.Error("least recently used entry kept beyond the limit")
+	}
+	for _, key := range []string{"a", "c"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("entry %q evicted", key)
+		}
+	}
+}
+
+func TestLRUAddRemovesExpired(t *testing.T) {
+	c := NewLRU[int](0, time.Minute)
+	c.Add("old", 1)
+	c.Add("recent", 2)
+	c.mu.Lock()
+	c.entries["old"].Value.(*lruEntry[int]).lastUsed = time.Now().Add(-2 * time.Minute)
+	c.mu.Unlock()
+
+	c.Add("new", 3)
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.entries["old"]; ok || c.order.Len() != 2 {
+		t.Errorf("expired entry not removed on Add: %d entries", c.order.Len())
+	}
+}
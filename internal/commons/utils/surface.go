@@ -0,0 +1,105 @@
+package utils
+
+import (
+	"encoding/json"
+	"net/http"
+	"strings"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+// API surfaces exposed by the proxy
+const (
+	SurfaceOpenAI = "openai"
+	SurfaceClaude = "claude"
+	SurfaceGemini = "gemini"
+)
+
+// StatusOverloaded is Anthropic's non-standard status for an overloaded API
+const StatusOverloaded = 529
+
+// DetectSurface returns the API surface a request path belongs to. Root /v1
+// routes are shared by OpenAI and Claude, so Claude-only paths are matched
+// explicitly and everything else defaults to OpenAI.
+func DetectSurface(path string) string {
+	switch {
+	case strings.HasPrefix(path, "/gemini/"):
+		return SurfaceGemini
+	case strings.HasPrefix(path, "/claude/"), strings.HasPrefix(path, "/v1/messages"):
+		return SurfaceClaude
+	default:
+		return SurfaceOpenAI
+	}
+}
+
+// RequestModel returns the model named by a request: the path segment on Gemini
+// routes (/models/{model}:action), otherwise the "model" field of a JSON body
+func RequestModel(c fiber.Ctx) string {
+	path := c.Path()
+	if idx := strings.Index(path, "/models/"); idx >= 0 {
+		model := path[idx+len("/models/"):]
+		if colon := strings.IndexByte(model, ':'); colon >= 0 {
+			return model[:colon]
+		}
+		return model
+	}
+
+	body := c.Body()
+	if len(body) == 0 || body[0] != '{' {
+		return ""
+	}
+	var req struct {
+		Model string `json:"model"`
+	}
+	_ = json.Unmarshal(body, &req)
+	return req.Model
+}
+
+// SurfaceErrorBody builds an error body in the native format of a surface.
+// errType is the OpenAI/Anthropic error type (e.g. "rate_limit_error"); Gemini
+// derives its google.rpc status from the HTTP status instead.
+func SurfaceErrorBody(surface string, status int, errType, code, message string) interface{} {
+	switch surface {
+	case SurfaceClaude:
+		return map[string]interface{}{
+			"type":  "error",
+			"error": map[string]interface{}{"type": errType, "message": message},
+		}
+	case SurfaceGemini:
+		return map[string]interface{}{
+			"error": map[string]interface{}{
+				"code":    status,
+				"message": message,
+				"status":  GoogleRPCStatus(status),
+			},
+		}
+	default:
+		body := map[string]interface{}{"type": errType, "message": message}
+		if code != "" {
+			body["code"] = code
+		}
+		return map[string]interface{}{"error": body}
+	}
+}
+
+// GoogleRPCStatus maps an HTTP status to the canonical google.rpc status name
+func GoogleRPCStatus(status int) string {
+	switch status {
+	case http.StatusBadRequest:
+		return "INVALID_ARGUMENT"
+	case http.StatusUnauthorized:
+		return "UNAUTHENTICATED"
+	case http.StatusForbidden:
+		return "PERMISSION_DENIED"
+	case http.StatusNotFound:
+		return "NOT_FOUND"
+	case http.StatusTooManyRequests:
+		return "RESOURCE_EXHAUSTED"
+	case http.StatusServiceUnavailable:
+		return "UNAVAILABLE"
+	case http.StatusGatewayTimeout:
+		return "DEADLINE_EXCEEDED"
+	default:
+		return "INTERNAL"
+	}
+}
@@ -0,0 +1,275 @@
+package utils
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/google/uuid"
+)
+
+// Gemini web has no native function calling, so tools are emulated: their
+// schemas are described in the prompt and the model answers with tagged JSON
+// blocks that are parsed back into the tool calls of each API surface.
+const (
+	toolCallOpenTag  = "<tool_call>"
+	toolCallCloseTag = "</tool_call>"
+)
+
+// Tool choice modes shared by the API surfaces
+const (
+	ToolChoiceAuto     = "auto"
+	ToolChoiceNone     = "none"
+	ToolChoiceRequired = "required"
+)
+
+// ToolDefinition is a function the model may call, independent of the API surface
+type ToolDefinition struct {
+	Name        string
+	Description string
+	Parameters  interface{} // JSON schema
+}
+
+// ToolChoice controls whether and which tools the model must call. A non-empty
+// Name forces that function.
+type ToolChoice struct {
+	Mode string
+	Name string
+}
+
+// ToolCall is a tool invocation parsed from the model's reply
+type ToolCall struct {
+	Name      string
+	Arguments json.RawMessage // JSON object
+}
+
+// PromptOption configures BuildPromptFromMessages
+type PromptOption func(*promptConfig)
+
+type promptConfig struct {
+	tools  []ToolDefinition
+	choice ToolChoice
+}
+
+// WithTools describes the tools in the prompt and instructs the model how to call them
+func WithTools(tools []ToolDefinition, choice ToolChoice) PromptOption {
+	return func(c *promptConfig) {
+		c.tools = tools
+		c.choice = choice
+	}
+}
+
+// ToolsActive reports whether tool instructions are injected and replies must be parsed for tool calls
+func ToolsActive(tools []ToolDefinition, choice ToolChoice) bool {
+	return len(tools) > 0 && choice.Mode != ToolChoiceNone
+}
+
+// NewToolCallID generates a tool call ID with the given prefix (e.g. "call_", "toolu_")
+func NewToolCallID(prefix string) string {
+	return prefix + strings.ReplaceAll(uuid.New().String(), "-", "")[:24]
+}
+
+// buildToolInstructions renders the tool section of the prompt
+func buildToolInstructions(tools []ToolDefinition, choice ToolChoice) string {
+	var sb strings.Builder
+	sb.WriteString("Tools: You can call the following tools. To call a tool, reply with one block per call in exactly this format:\n")
+	sb.WriteString(toolCallOpenTag + `{"name": "<tool name>", "arguments": {<arguments matching the tool's parameters>}}` + toolCallCloseTag + "\n")
+	sb.WriteString("Do not wrap the blocks in code fences and write nothing after the last block. ")
+	sb.WriteString("Tool results are sent back in <tool_result> blocks; never write them yourself. ")
+
+	switch {
+	case choice.Name != "":
+		fmt.Fprintf(&sb, "In this reply you must call the tool %q.\n", choice.Name)
+	case choice.Mode == ToolChoiceRequired:
+		sb.WriteString("In this reply you must call at least one tool.\n")
+	default:
+		sb.WriteString("If no tool is needed, answer normally without any block.\n")
+	}
+
+	sb.WriteString("\nAvailable tools:\n")
+	for _, tool := range tools {
+		fmt.Fprintf(&sb, "- %s", tool.Name)
+		if tool.Description != "" {
+			fmt.Fprintf(&sb, ": %s", tool.Description)
+		}
+		sb.WriteString("\n")
+		if tool.Parameters != nil {
+			if schema, err := json.Marshal(tool.Parameters); err == nil {
+				fmt.Fprintf(&sb, "  Parameters: %s\n", schema)
+			}
+		}
+	}
+	return sb.String()
+}
+
+// FormatToolCall renders a past tool call the way the model is asked to write it
+func FormatToolCall(name string, arguments json.RawMessage) string {
+	call := struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}{Name: name, Arguments: normalizeArguments(arguments)}
+	data, _ := json.Marshal(call)
+	return toolCallOpenTag + string(data) + toolCallCloseTag
+}
+
+// FormatToolResult renders the result of a tool call for the next prompt
+func FormatToolResult(name, id, content string, isError bool) string {
+	var attrs strings.Builder
+	if name != "" {
+		fmt.Fprintf(&attrs, " name=%q", name)
+	}
+	if id != "" {
+		fmt.Fprintf(&attrs, " id=%q", id)
+	}
+	if isError {
+		attrs.WriteString(` error="true"`)
+	}
+	return fmt.Sprintf("<tool_result%s>\n%s\n</tool_result>", attrs.String(), content)
+}
+
+// ExtractToolCalls removes tool call blocks from a complete reply and returns
+// the remaining text and the parsed calls
+func ExtractToolCalls(text string) (string, []ToolCall) {
+	parser := &ToolCallParser{}
+	before, calls := parser.Feed(text)
+	after, more := parser.Flush()
+	calls = append(calls, more...)
+	if len(calls) == 0 {
+		return text, nil
+	}
+	return cleanToolText(before + after), calls
+}
+
+// emptyFencePattern matches code fences left empty once tool call blocks are removed
+var emptyFencePattern = regexp.MustCompile("(?s)```[a-zA-Z]*\\s*```")
+
+func cleanToolText(text string) string {
+	return strings.TrimSpace(emptyFencePattern.ReplaceAllString(text, ""))
+}
+
+// ToolCallParser splits a streamed reply into plain text and tool calls. Text
+// that might be the start of a tool call block is held back until it can be
+// decided, so clients never see partial tags.
+type ToolCallParser struct {
+	pending string
+	inCall  bool
+}
+
+// Feed consumes a text delta and returns the text that is safe to emit and the
+// tool calls completed by it
+func (p *ToolCallParser) Feed(delta string) (string, []ToolCall) {
+	p.pending += delta
+
+	var text strings.Builder
+	var calls []ToolCall
+	for {
+		if !p.inCall {
+			if idx := strings.Index(p.pending, toolCallOpenTag); idx >= 0 {
+				text.WriteString(p.pending[:idx])
+				p.pending = p.pending[idx+len(toolCallOpenTag):]
+				p.inCall = true
+				continue
+			}
+			keep := partialTagLength(p.pending, toolCallOpenTag)
+			text.WriteString(p.pending[:len(p.pending)-keep])
+			p.pending = p.pending[len(p.pending)-keep:]
+			return text.String(), calls
+		}
+
+		idx := strings.Index(p.pending, toolCallCloseTag)
+		if idx < 0 {
+			return text.String(), calls
+		}
+		body := p.pending[:idx]
+		p.pending = p.pending[idx+len(toolCallCloseTag):]
+		p.inCall = false
+		if call, ok := parseToolCall(body); ok {
+			calls = append(calls, call)
+		} else {
+			text.WriteString(toolCallOpenTag + body + toolCallCloseTag)
+		}
+	}
+}
+
+// Flush returns whatever is still held back at the end of the reply. An
+// unterminated block is parsed if it holds a complete call.
+func (p *ToolCallParser) Flush() (string, []ToolCall) {
+	pending := p.pending
+	inCall := p.inCall
+	p.pending, p.inCall = "", false
+
+	if !inCall {
+		return pending, nil
+	}
+	if call, ok := parseToolCall(pending); ok {
+		return "", []ToolCall{call}
+	}
+	return toolCallOpenTag + pending, nil
+}
+
+// partialTagLength returns the length of the longest suffix of s that is a prefix of tag
+func partialTagLength(s, tag string) int {
+	for n := min(len(s), len(tag)-1); n > 0; n-- {
+		if strings.HasSuffix(s, tag[:n]) {
+			return n
+		}
+	}
+	return 0
+}
+
+// parseToolCall decodes the JSON body of a tool call block. The web app
+// sometimes wraps it in a code fence or escapes markdown characters.
+func parseToolCall(body string) (ToolCall, bool) {
+	body = strings.TrimSpace(body)
+	body = strings.TrimPrefix(body, "```json")
+	body = strings.Trim(body, "`\n ")
+
+	var raw struct {
+		Name      string          `json:"name"`
+		Arguments json.RawMessage `json:"arguments"`
+	}
+	if err := json.Unmarshal([]byte(body), &raw); err != nil {
+		if err := json.Unmarshal([]byte(strings.ReplaceAll(body, `\_`, "_")), &raw); err != nil {
+			return ToolCall{}, false
+		}
+	}
+	if raw.Name == "" {
+		return ToolCall{}, false
+	}
+	return ToolCall{Name: raw.Name, Arguments: normalizeArguments(raw.Arguments)}, true
+}
+
+// normalizeArguments turns missing or string-encoded arguments into a JSON
+// object; arguments that are not JSON at all are kept as a JSON string
+func normalizeArguments(arguments json.RawMessage) json.RawMessage {
+	arguments = bytes.TrimSpace(arguments)
+	if len(arguments) == 0 || string(arguments) == "null" {
+		return json.RawMessage("{}")
+	}
+	if arguments[0] == '"' {
+		var encoded string
+		if err := json.Unmarshal(arguments, &encoded); err == nil && json.Valid([]byte(encoded)) {
+			arguments = json.RawMessage(encoded)
+		}
+	}
+	if !json.Valid(arguments) {
+		encoded, _ := json.Marshal(string(arguments))
+		return encoded
+	}
+
+	// Re-encode so the same arguments always render the same way, whichever
+	// API sent them back (sorted keys, no insignificant whitespace)
+	decoder := json.NewDecoder(bytes.NewReader(arguments))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return arguments
+	}
+	canonical, err := json.Marshal(value)
+	if err != nil {
+		return arguments
+	}
+	return canonical
+}
@@ -0,0 +1,114 @@
+package utils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestToolCallParser(t *testing.T) {
+	type call struct{ name, arguments string }
+	tests := []struct {
+		name    string
+		chunks  []string
+		text    string // all text emitted by Feed and Flush
+		flushed string // text held back until Flush
+		calls   []call
+	}{
+		{
+			name:   "plain text",
+			chunks: []string{"Hello ", "world"},
+			text:   "Hello world",
+		},
+		{
+			name:   "angle bracket that starts no tag",
+			chunks: []string{"a <", "b"},
+			text:   "a <b",
+		},
+		{
+			name:   "tags split across chunks",
+			chunks: []string{"Sure. <tool", `_call>{"name":"get_weather",`, `"arguments":{"city":"Paris"}}</tool`, "_call>"},
+			text:   "Sure. ",
+			calls:  []call{{"get_weather", `{"city":"Paris"}`}},
+		},
+		{
+			name:    "partial open tag at the end of the stream",
+			chunks:  []string{"Almost <tool_c"},
+			text:    "Almost <tool_c",
+			flushed: "<tool_c",
+		},
+		{
+			name:   "invalid JSON in a block",
+			chunks: []string{"<tool_call>{not json}</tool_call> after"},
+			text:   "<tool_call>{not json}</tool_call> after",
+		},
+		{
+			name:   "block without a name",
+			chunks: []string{`<tool_call>{"arguments":{}}</tool_call>`},
+			text:   `<tool_call>{"arguments":{}}</tool_call>`,
+		},
+		{
+			name: "several calls in one reply",
+			chunks: []string{
+				`First.<tool_call>{"name":"a","arguments":{"n":1}}</tool_call>`,
+				`Then.<tool_call>{"name":"b"}</tool_call>Done.`,
+			},
+			text:  "First.Then.Done.",
+			calls: []call{{"a", `{"n":1}`}, {"b", "{}"}},
+		},
+		{
+			name:   "unterminated block holding a complete call",
+			chunks: []string{`<tool_call>{"name":"a","arguments":{}}`},
+			calls:  []call{{"a", "{}"}},
+		},
+		{
+			name:    "unterminated block holding a partial call",
+			chunks:  []string{`<tool_call>{"name":`},
+			text:    `<tool_call>{"name":`,
+			flushed: `<tool_call>{"name":`,
+		},
+		{
+			name:   "call in a code fence with escaped markdown",
+			chunks: []string{"<tool_call>\n```json\n{\"name\":\"get\\_weather\",\"arguments\":{}}\n```\n</tool_call>"},
+			calls:  []call{{"get_weather", "{}"}},
+		},
+		{
+			name:   "string-encoded arguments",
+			chunks: []string{`<tool_call>{"name":"a","arguments":"{\"b\": 2, \"a\": 1}"}</tool_call>`},
+			calls:  []call{{"a", `{"a":1,"b":2}`}},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			parser := &ToolCallParser{}
+			var text strings.Builder
+			var calls []ToolCall
+			for _, chunk := range tt.chunks {
+				emitted, completed := parser.Feed(chunk)
+				if partialTagLength(emitted, toolCallOpenTag) > 0 {
+					t.Errorf("Feed(%q) emitted %q, ending in part of a tag", chunk, emitted)
+				}
+				text.WriteString(emitted)
+				calls = append(calls, completed...)
+			}
+			flushed, completed := parser.Flush()
+			text.WriteString(flushed)
+			calls = append(calls, completed...)
+
+			if text.String() != tt.text {
+				t.Errorf("text = %q, want %q", text.String(), tt.text)
+			}
+			if flushed != tt.flushed {
+				t.Errorf("Flush() = %q, want %q", flushed, tt.flushed)
+			}
+			if len(calls) != len(tt.calls) {
+				t.Fatalf("got %d calls %v, want %d", len(calls), calls, len(tt.calls))
+			}
+			for i, want := range tt.calls {
+				if calls[i].Name != want.name || string(calls[i].Arguments) != want.arguments {
+					t.Errorf("call %d = %s(%s), want %s(%s)", i, calls[i].Name, calls[i].Arguments, want.name, want.arguments)
+				}
+			}
+		})
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"bufio"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"strings"
 	"time"
@@ -45,43 +46,152 @@ func GetMessageText(content interface{}) string {
 }
 
 // BuildPromptFromMessages constructs a unified prompt from messages
-func BuildPromptFromMessages(messages []models.Message, systemPrompt string) string {
+func BuildPromptFromMessages(messages []models.Message, systemPrompt string, options ...PromptOption) string {
+	config := &promptConfig{}
+	for _, opt := range options {
+		opt(config)
+	}
+
 	var promptBuilder strings.Builder
 
 	if systemPrompt != "" {
 		promptBuilder.WriteString(fmt.Sprintf("System: %s\n\n", systemPrompt))
 	}
+	if ToolsActive(config.tools, config.choice) {
+		promptBuilder.WriteString(buildToolInstructions(config.tools, config.choice))
+		promptBuilder.WriteString("\n")
+	}
+
+	for _, line := range RenderMessages(messages) {
+		promptBuilder.WriteString(line + "\n")
+	}
+
+	return strings.TrimSpace(promptBuilder.String())
+}
 
+// RenderMessages renders each message as a "Role: text" prompt line
+func RenderMessages(messages []models.Message) []string {
+	toolNames := toolCallNames(messages)
+	lines := make([]string, 0, len(messages))
 	for _, msg := range messages {
 		role := "User"
 		if strings.EqualFold(msg.Role, "assistant") || strings.EqualFold(msg.Role, "model") {
 			role = "Model"
 		} else if strings.EqualFold(msg.Role, "system") {
 			role = "System"
+		} else if strings.EqualFold(msg.Role, "tool") {
+			role = "Tool"
 		}
-		text := GetMessageText(msg.Content)
-		promptBuilder.WriteString(fmt.Sprintf("%s: %s\n", role, text))
+		text := renderMessage(msg, toolNames)
+		lines = append(lines, fmt.Sprintf("%s: %s", role, text))
 	}
+	return lines
+}
 
-	return strings.TrimSpace(promptBuilder.String())
+// renderMessage renders a message's text together with its tool calls or tool
+// results, in OpenAI (tool_calls, role "tool") or Claude (tool_use and
+// tool_result blocks) form
+func renderMessage(msg models.Message, toolNames map[string]string) string {
+	if strings.EqualFold(msg.Role, "tool") {
+		name := msg.Name
+		if name == "" {
+			name = toolNames[msg.ToolCallID]
+		}
+		return FormatToolResult(name, msg.ToolCallID, GetMessageText(msg.Content), false)
+	}
+
+	parts := []string{}
+	if blocks, ok := msg.Content.([]interface{}); ok {
+		for _, block := range blocks {
+			m, ok := block.(map[string]interface{})
+			if !ok {
+				if s, ok := block.(string); ok {
+					parts = append(parts, s)
+				}
+				continue
+			}
+			switch m["type"] {
+			case "tool_use":
+				name, _ := m["name"].(string)
+				input, _ := json.Marshal(m["input"])
+				parts = append(parts, FormatToolCall(name, input))
+			case "tool_result":
+				id, _ := m["tool_use_id"].(string)
+				isError, _ := m["is_error"].(bool)
+				parts = append(parts, FormatToolResult(toolNames[id], id, GetMessageText(m["content"]), isError))
+			default:
+				if t, ok := m["text"].(string); ok {
+					parts = append(parts, t)
+				}
+			}
+		}
+	} else if text := GetMessageText(msg.Content); text != "" {
+		parts = append(parts, text)
+	}
+
+	for _, call := range msg.ToolCalls {
+		parts = append(parts, FormatToolCall(call.Function.Name, json.RawMessage(call.Function.Arguments)))
+	}
+	return strings.Join(parts, "\n")
+}
+
+// toolCallNames maps tool call IDs to function names so results can be labelled
+func toolCallNames(messages []models.Message) map[string]string {
+	names := make(map[string]string)
+	for _, msg := range messages {
+		for _, call := range msg.ToolCalls {
+			names[call.ID] = call.Function.Name
+		}
+		if blocks, ok := msg.Content.([]interface{}); ok {
+			for _, block := range blocks {
+				if m, ok := block.(map[string]interface{}); ok && m["type"] == "tool_use" {
+					id, _ := m["id"].(string)
+					name, _ := m["name"].(string)
+					names[id] = name
+				}
+			}
+		}
+	}
+	return names
+}
+
+// ErrInvalidRequest is matched by the errors of requests that fail validation,
+// which are answered with a 400 in the format of each API
+var ErrInvalidRequest = errors.New("invalid request")
+
+// InvalidRequestf formats a validation error matching ErrInvalidRequest. Its
+// message is the formatted text alone.
+func InvalidRequestf(format string, args ...any) error {
+	return &invalidRequestError{err: fmt.Errorf(format, args...)}
 }
 
-// ValidateMessages validates that messages array is not empty and not all empty
+type invalidRequestError struct {
+	err error
+}
+
+func (e *invalidRequestError) Error() string { return e.err.Error() }
+
+func (e *invalidRequestError) Unwrap() error { return e.err }
+
+func (e *invalidRequestError) Is(target error) bool { return target == ErrInvalidRequest }
+
+// ValidateMessages validates that messages array is not empty and not all empty.
+// Images and documents count as content.
 func ValidateMessages(messages []models.Message) error {
 	if len(messages) == 0 {
-		return fmt.Errorf("messages array cannot be empty")
+		return InvalidRequestf("messages array cannot be empty")
 	}
 
 	allEmpty := true
 	for _, msg := range messages {
-		if strings.TrimSpace(GetMessageText(msg.Content)) != "" {
+		if strings.TrimSpace(renderMessage(msg, nil)) != "" || hasAttachments(msg) {
 			allEmpty = false
 			break
 		}
 	}
 
 	if allEmpty {
-		return fmt.Errorf("all messages have empty content")
+		return InvalidRequestf("all messages have empty content")
 	}
 
 	return nil
@@ -90,11 +200,11 @@ func ValidateMessages(messages []models.Message) error {
 // ValidateGenerationRequest validates common generation request parameters
 func ValidateGenerationRequest(model string, maxTokens int, temperature float32) error {
 	if maxTokens < 0 {
-		return fmt.Errorf("max_tokens must be non-negative")
+		return InvalidRequestf("max_tokens must be non-negative")
 	}
 
 	if temperature < 0 || temperature > 2 {
-		return fmt.Errorf("temperature must be between 0 and 2")
+		return InvalidRequestf("temperature must be between 0 and 2")
 	}
 
 	return nil
@@ -142,18 +252,31 @@ func SendSSEChunk(w *bufio.Writer, log *zap.Logger, event string, chunk interfac
 	return nil
 }
 
-// SplitResponseIntoChunks simulates streaming by splitting response into chunks
-func SplitResponseIntoChunks(text string, delayMs int) []string {
-	words := strings.Split(text, " ")
-	var chunks []string
-	for i, word := range words {
-		content := word
-		if i < len(words)-1 {
-			content += " "
-		}
-		chunks = append(chunks, content)
+// SendSSEData writes an unnamed Server-Sent Event (OpenAI style "data:" frame)
+func SendSSEData(w *bufio.Writer, log *zap.Logger, chunk interface{}) error {
+	data := MarshalJSONSafely(log, chunk)
+	if _, err := fmt.Fprintf(w, "data: %s\n\n", string(data)); err != nil {
+		log.Error("Failed to write SSE chunk", zap.Error(err))
+		return err
 	}
-	return chunks
+	if err := w.Flush(); err != nil {
+		log.Error("Failed to flush SSE writer", zap.Error(err))
+		return err
+	}
+	return nil
+}
+
+// SendSSEDone writes the OpenAI stream terminator
+func SendSSEDone(w *bufio.Writer) error {
+	if _, err := w.WriteString("data: [DONE]\n\n"); err != nil {
+		return err
+	}
+	return w.Flush()
+}
+
+// EstimateTokens roughly estimates the token count of a text (~4 chars per token)
+func EstimateTokens(text string) int {
+	return len(text) / 4
 }
 
 // SleepWithCancel sleeps for the specified duration or until context is cancelled
@@ -0,0 +1,172 @@
+package auth
+
+import (
+	"errors"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"gemini-web-to-api/internal/commons/utils"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+// LocalsKeyName is the fiber.Ctx locals key holding the authenticated key name
+const LocalsKeyName = "api_key_name"
+
+// localsKey is the fiber.Ctx locals key holding the authenticated *APIKey
+const localsKey = "api_key"
+
+// publicPrefixes are paths served without an API key
+var publicPrefixes = []string{"/health", "/ready", "/swagger", "/metrics"}
+
+// NewMiddleware returns a handler that authenticates requests and enforces
+// per-key model restrictions and quotas
+func NewMiddleware(s *AuthService, log *zap.Logger) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if c.Method() == fiber.MethodOptions || isPublic(c.Path()) {
+			return c.Next()
+		}
+
+		surface := utils.DetectSurface(c.Path())
+		secret := extractKey(c)
+		if secret == "" {
+			return respondMissingKey(c, surface)
+		}
+
+		key, err := s.Authenticate(secret)
+		if err != nil {
+			log.Debug("Rejected request with invalid API key", zap.String("path", c.Path()))
+			return respondInvalidKey(c, surface)
+		}
+
+		if runsModel(c) {
+			model := utils.RequestModel(c)
+			if err := s.CheckModel(key, model); err != nil {
+				log.Debug("Rejected request for disallowed model", zap.String("key", key.Name), zap.String("model", model))
+				return c.Status(fiber.StatusForbidden).JSON(utils.SurfaceErrorBody(
+					surface, fiber.StatusForbidden, permissionErrorType(surface), "model_not_allowed", err.Error(),
+				))
+			}
+		}
+
+		if err := s.Consume(key, time.Now()); err != nil {
+			var quotaErr *QuotaError
+			if errors.As(err, &quotaErr) {
+				c.Set(fiber.HeaderRetryAfter, strconv.Itoa(int(math.Ceil(quotaErr.RetryAfter.Seconds()))))
+			}
+			log.Debug("Rejected request over quota", zap.String("key", key.Name), zap.Error(err))
+			return c.Status(fiber.StatusTooManyRequests).JSON(utils.SurfaceErrorBody(
+				surface, fiber.StatusTooManyRequests, rateLimitErrorType(surface), "rate_limit_exceeded", err.Error(),
+			))
+		}
+
+		c.Locals(LocalsKeyName, key.Name)
+		c.Locals(localsKey, key)
+		return c.Next()
+	}
+}
+
+// KeyFromContext returns the key that authenticated a request, or nil when
+// authentication is disabled
+func KeyFromContext(c fiber.Ctx) *APIKey {
+	key, _ := c.Locals(localsKey).(*APIKey)
+	return key
+}
+
+// RegisterMiddleware installs the authentication middleware when keys are configured
+func RegisterMiddleware(app *fiber.App, s *AuthService, log *zap.Logger) {
+	if !s.Enabled() {
+		return
+	}
+	app.Use(NewMiddleware(s, log))
+}
+
+// runsModel reports whether a request runs the model it names. Listings and
+// reads or deletes of stored responses and sessions run none; messages to a
+// session run the session's model, which the sessions API checks itself.
+func runsModel(c fiber.Ctx) bool {
+	if c.Method() != fiber.MethodPost {
+		return false
+	}
+	rest, ok := strings.CutPrefix(c.Path(), "/sessions/")
+	return !ok || rest == ""
+}
+
+func isPublic(path string) bool {
+	for _, prefix := range publicPrefixes {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// extractKey reads the API key from the headers or query parameter used by the
+// OpenAI (Bearer), Anthropic (x-api-key) and Gemini (x-goog-api-key, ?key=) SDKs
+func extractKey(c fiber.Ctx) string {
+	if auth := c.Get(fiber.HeaderAuthorization); auth != "" {
+		if token, ok := strings.CutPrefix(auth, "Bearer "); ok {
+			return strings.TrimSpace(token)
+		}
+	}
+	if key := c.Get("x-api-key"); key != "" {
+		return key
+	}
+	if key := c.Get("x-goog-api-key"); key != "" {
+		return key
+	}
+	return c.Query("key")
+}
+
+func respondMissingKey(c fiber.Ctx, surface string) error {
+	switch surface {
+	case utils.SurfaceGemini:
+		return c.Status(fiber.StatusForbidden).JSON(utils.SurfaceErrorBody(
+			surface, fiber.StatusForbidden, "", "",
+			"Method doesn't allow unregistered callers. Please use an API key.",
+		))
+	case utils.SurfaceClaude:
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.SurfaceErrorBody(
+			surface, fiber.StatusUnauthorized, "authentication_error", "", "x-api-key header is required",
+		))
+	default:
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.SurfaceErrorBody(
+			surface, fiber.StatusUnauthorized, "invalid_request_error", "missing_api_key",
+			"You didn't provide an API key. Provide it in the Authorization header using Bearer auth.",
+		))
+	}
+}
+
+func respondInvalidKey(c fiber.Ctx, surface string) error {
+	switch surface {
+	case utils.SurfaceGemini:
+		return c.Status(fiber.StatusBadRequest).JSON(utils.SurfaceErrorBody(
+			surface, fiber.StatusBadRequest, "", "", "API key not valid. Please pass a valid API key.",
+		))
+	case utils.SurfaceClaude:
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.SurfaceErrorBody(
+			surface, fiber.StatusUnauthorized, "authentication_error", "", ErrInvalidKey.Error(),
+		))
+	default:
+		return c.Status(fiber.StatusUnauthorized).JSON(utils.SurfaceErrorBody(
+			surface, fiber.StatusUnauthorized, "invalid_request_error", "invalid_api_key", "Incorrect API key provided.",
+		))
+	}
+}
+
+func permissionErrorType(surface string) string {
+	if surface == utils.SurfaceClaude {
+		return "permission_error"
+	}
+	return "invalid_request_error"
+}
+
+func rateLimitErrorType(surface string) string {
+	if surface == utils.SurfaceClaude {
+		return "rate_limit_error"
+	}
+	return "requests"
+}
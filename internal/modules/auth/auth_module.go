@@ -0,0 +1,8 @@
+package auth
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Provide(NewAuthService),
+	fx.Invoke(RegisterMiddleware),
+)
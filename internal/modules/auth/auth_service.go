@@ -0,0 +1,212 @@
+package auth
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/modules/providers"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+var (
+	// ErrInvalidKey is returned for unknown API keys
+	ErrInvalidKey = errors.New("invalid API key")
+	// ErrModelNotAllowed is returned when a key is not allowed to use the requested model
+	ErrModelNotAllowed = errors.New("model not allowed for this API key")
+)
+
+// QuotaError is returned when a key exceeded one of its request quotas
+type QuotaError struct {
+	Window     string // "minute" or "day"
+	Limit      int
+	RetryAfter time.Duration
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("rate limit exceeded: %d requests per %s", e.Limit, e.Window)
+}
+
+// APIKey is a client credential for the proxy
+type APIKey struct {
+	Name              string   `json:"name" yaml:"name"`
+	Key               string   `json:"key" yaml:"key"`
+	Models            []string `json:"models,omitempty" yaml:"models"` // glob patterns; empty allows all
+	RequestsPerMinute int      `json:"requests_per_minute,omitempty" yaml:"requests_per_minute"`
+	RequestsPerDay    int      `json:"requests_per_day,omitempty" yaml:"requests_per_day"`
+}
+
+// AllowsModel reports whether the key may use a registry model: its ID or one
+// of its aliases must match one of the key's patterns. The account default
+// model, which has no ID, is only allowed to keys without patterns.
+func (k *APIKey) AllowsModel(model *providers.ModelInfo) bool {
+	if len(k.Models) == 0 {
+		return true
+	}
+	if model == nil || model.ID == "" {
+		return false
+	}
+	names := append([]string{model.ID}, model.Aliases...)
+	for _, pattern := range k.Models {
+		pattern = strings.ToLower(pattern)
+		for _, name := range names {
+			if matched, _ := path.Match(pattern, strings.ToLower(name)); matched {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// keysFile is the on-disk layout of API_KEYS_FILE
+type keysFile struct {
+	Keys []APIKey `json:"keys" yaml:"keys"`
+}
+
+// usage tracks fixed-window request counters for one key
+type usage struct {
+	minuteStart time.Time
+	minuteCount int
+	dayStart    time.Time
+	dayCount    int
+}
+
+// AuthService validates API keys and enforces per-key quotas
+type AuthService struct {
+	keys     []APIKey
+	registry *providers.ModelRegistry
+	log      *zap.Logger
+
+	mu    sync.Mutex // protects: usage
+	usage map[string]*usage
+}
+
+// NewAuthService loads keys from API_KEYS_FILE and API_KEYS
+func NewAuthService(cfg *configs.Config, registry *providers.ModelRegistry, log *zap.Logger) (*AuthService, error) {
+	var keys []APIKey
+	if cfg.Auth.KeysFile != "" {
+		data, err := os.ReadFile(cfg.Auth.KeysFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read API keys file: %w", err)
+		}
+
+		var file keysFile
+		if strings.EqualFold(filepath.Ext(cfg.Auth.KeysFile), ".json") {
+			err = json.Unmarshal(data, &file)
+		} else {
+			err = yaml.Unmarshal(data, &file)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("invalid API keys file %s: %w", cfg.Auth.KeysFile, err)
+		}
+		keys = append(keys, file.Keys...)
+	}
+	for i, key := range cfg.Auth.Keys {
+		keys = append(keys, APIKey{Name: fmt.Sprintf("env-%d", i+1), Key: key})
+	}
+
+	for i, key := range keys {
+		if key.Key == "" {
+			return nil, fmt.Errorf("API key #%d (%s) has an empty key", i+1, key.Name)
+		}
+		if key.Name == "" {
+			keys[i].Name = fmt.Sprintf("key-%d", i+1)
+		}
+	}
+
+	if len(keys) == 0 {
+		log.Warn("No API keys configured — the proxy accepts unauthenticated requests. Set API_KEYS or API_KEYS_FILE to protect it")
+	} else {
+		log.Info("API key authentication enabled", zap.Int("keys", len(keys)))
+	}
+
+	return &AuthService{
+		keys:     keys,
+		registry: registry,
+		log:      log,
+		usage:    make(map[string]*usage),
+	}, nil
+}
+
+// Enabled reports whether any API key is configured
+func (s *AuthService) Enabled() bool {
+	return len(s.keys) > 0
+}
+
+// Authenticate returns the key matching the presented secret
+func (s *AuthService) Authenticate(secret string) (*APIKey, error) {
+	if secret == "" {
+		return nil, ErrInvalidKey
+	}
+	for i := range s.keys {
+		if subtle.ConstantTimeCompare([]byte(s.keys[i].Key), []byte(secret)) == 1 {
+			return &s.keys[i], nil
+		}
+	}
+	return nil, ErrInvalidKey
+}
+
+// CheckModel returns an error wrapping ErrModelNotAllowed unless key may use
+// the model a request names. The name is resolved through the registry first,
+// so aliases of an allowed model are allowed too; a key restricted to models
+// must name a known one.
+func (s *AuthService) CheckModel(key *APIKey, name string) error {
+	if key == nil || len(key.Models) == 0 {
+		return nil
+	}
+	if name == "" {
+		return fmt.Errorf("%w: a model is required", ErrModelNotAllowed)
+	}
+	model, err := s.registry.Resolve(name)
+	if err != nil || !key.AllowsModel(model) {
+		return fmt.Errorf("%w: %s", ErrModelNotAllowed, name)
+	}
+	return nil
+}
+
+// Consume counts one request against the key's quotas. Rejected requests are
+// not counted.
+func (s *AuthService) Consume(key *APIKey, now time.Time) error {
+	if key.RequestsPerMinute <= 0 && key.RequestsPerDay <= 0 {
+		return nil
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	u, ok := s.usage[key.Name]
+	if !ok {
+		u = &usage{}
+		s.usage[key.Name] = u
+	}
+
+	minute := now.Truncate(time.Minute)
+	if !u.minuteStart.Equal(minute) {
+		u.minuteStart, u.minuteCount = minute, 0
+	}
+	day := now.UTC().Truncate(24 * time.Hour)
+	if !u.dayStart.Equal(day) {
+		u.dayStart, u.dayCount = day, 0
+	}
+
+	if key.RequestsPerMinute > 0 && u.minuteCount >= key.RequestsPerMinute {
+		return &QuotaError{Window: "minute", Limit: key.RequestsPerMinute, RetryAfter: minute.Add(time.Minute).Sub(now)}
+	}
+	if key.RequestsPerDay > 0 && u.dayCount >= key.RequestsPerDay {
+		return &QuotaError{Window: "day", Limit: key.RequestsPerDay, RetryAfter: day.Add(24 * time.Hour).Sub(now)}
+	}
+
+	u.minuteCount++
+	u.dayCount++
+	return nil
+}
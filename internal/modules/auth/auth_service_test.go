@@ -0,0 +1,47 @@
+package auth
+
+import (
+	"errors"
+	"testing"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/modules/providers"
+
+	"go.uber.org/zap"
+)
+
+func TestCheckModel(t *testing.T) {
+	registry, err := providers.NewModelRegistry(&configs.Config{}, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &AuthService{registry: registry}
+	restricted := &APIKey{Name: "ci", Models: []string{"gemini-3-flash"}}
+	open := &APIKey{Name: "admin"}
+
+	tests := []struct {
+		key     *APIKey
+		model   string
+		allowed bool
+	}{
+		{key: restricted, model: "gemini-3-flash", allowed: true},
+		{key: restricted, model: "GEMINI-3-FLASH", allowed: true},
+		{key: restricted, model: "gemini-flash", allowed: true}, // alias of the allowed model
+		{key: restricted, model: "gemini-3-pro", allowed: false},
+		{key: restricted, model: "", allowed: false}, // account default model
+		{key: restricted, model: "no-such-model", allowed: false},
+		{key: &APIKey{Models: []string{"gemini-*-flash"}}, model: "gemini-flash-latest", allowed: true},
+		{key: open, model: "", allowed: true},
+		{key: open, model: "no-such-model", allowed: true},
+		{key: nil, model: "", allowed: true},
+	}
+	for _, tt := range tests {
+		err := s.CheckModel(tt.key, tt.model)
+		if allowed := err == nil; allowed != tt.allowed {
+			t.Errorf("CheckModel(%+v, %q) = %v, want allowed %v", tt.key, tt.model, err, tt.allowed)
+		}
+		if err != nil && !errors.Is(err, ErrModelNotAllowed) {
+			t.Errorf("CheckModel(%+v, %q) = %v, want ErrModelNotAllowed", tt.key, tt.model, err)
+		}
+	}
+}
@@ -1,15 +1,24 @@
 package claude
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
-	"gemini-web-to-api/internal/modules/claude/dto"
 	"time"
 
+	"gemini-web-to-api/internal/commons/models"
+	common "gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/claude/dto"
+	"gemini-web-to-api/internal/modules/providers"
+
 	"github.com/gofiber/fiber/v3"
 	"go.uber.org/zap"
 )
 
+// pingInterval is how often a ping event is sent while waiting for upstream output
+const pingInterval = 10 * time.Second
+
 type ClaudeController struct {
 	service *ClaudeService
 	log     *zap.Logger
@@ -33,31 +42,14 @@ func (h *ClaudeController) SetLogger(log *zap.Logger) {
 // @Tags Claude
 // @Accept json
 // @Produce json
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} models.ModelListResponse
 // @Router /claude/v1/models [get]
 func (h *ClaudeController) HandleModels(c fiber.Ctx) error {
-	return c.JSON(fiber.Map{
-		"data": []fiber.Map{
-			{
-				"id":           "claude-sonnet-4-6",
-				"type":         "model",
-				"created_at":   1740000000,
-				"display_name": "Claude 4.6 Sonnet",
-			},
-			{
-				"id":           "claude-opus-4-6",
-				"type":         "model",
-				"created_at":   1740000000,
-				"display_name": "Claude 4.6 Opus",
-			},
-			{
-				"id":           "claude-haiku-4-5",
-				"type":         "model",
-				"created_at":   1740000000,
-				"display_name": "Claude 4.5 Haiku",
-			},
-		},
-	})
+	data := []models.ModelData{}
+	for _, m := range h.service.ListModels() {
+		data = append(data, toClaudeModel(m))
+	}
+	return c.JSON(models.ModelListResponse{Data: data})
 }
 
 // HandleModelByID returns a specific Claude model by ID
@@ -67,27 +59,42 @@ func (h *ClaudeController) HandleModels(c fiber.Ctx) error {
 // @Accept json
 // @Produce json
 // @Param model_id path string true "Model ID"
-// @Success 200 {object} map[string]interface{}
+// @Success 200 {object} models.ModelData
+// @Failure 404 {object} map[string]interface{}
 // @Router /claude/v1/models/{model_id} [get]
 func (h *ClaudeController) HandleModelByID(c fiber.Ctx) error {
 	modelID := c.Params("model_id")
-	return c.JSON(fiber.Map{
-		"id":           modelID,
-		"type":         "model",
-		"created_at":   time.Now().Unix(),
-		"display_name": modelID,
-	})
+	m, err := h.service.GetModel(modelID)
+	if err != nil {
+		return h.respondError(c, err, modelID)
+	}
+
+	model := toClaudeModel(*m)
+	model.ID = modelID
+	return c.JSON(model)
+}
+
+// toClaudeModel converts a registry entry to the Anthropic model object
+func toClaudeModel(m providers.ModelInfo) models.ModelData {
+	return models.ModelData{
+		ID:          m.ID,
+		Type:        "model",
+		CreatedAt:   m.Created,
+		DisplayName: m.DisplayName,
+	}
 }
 
 // HandleMessages handles the main chat endpoint
 // @Summary Send Message (Claude)
-// @Description Sends a message to the Claude model
+// @Description Sends a message to the Claude model. Set "stream": true to receive Anthropic streaming events.
 // @Tags Claude
 // @Accept json
 // @Produce json
+// @Produce text/event-stream
 // @Param request body dto.MessageRequest true "Message Request"
 // @Success 200 {object} dto.MessageResponse
 // @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /claude/v1/messages [post]
 func (h *ClaudeController) HandleMessages(c fiber.Ctx) error {
@@ -100,20 +107,245 @@ func (h *ClaudeController) HandleMessages(c fiber.Ctx) error {
 		})
 	}
 
+	if req.Stream {
+		return h.handleMessagesStream(c, req)
+	}
+
 	// Add timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
 	defer cancel()
 
 	response, err := h.service.GenerateMessage(ctx, req)
 	if err != nil {
-		h.log.Error("GenerateContent failed", zap.Error(err), zap.String("model", req.Model))
-		return c.Status(fiber.StatusInternalServerError).JSON(fiber.Map{
+		return h.respondError(c, err, req.Model)
+	}
+
+	return c.JSON(response)
+}
+
+// handleMessagesStream streams the Anthropic Messages event sequence:
+// message_start, then content_block_start/delta/stop for each text, tool_use or
+// image block, message_delta and message_stop, with periodic pings while upstream is busy
+func (h *ClaudeController) handleMessagesStream(c fiber.Ctx, req dto.MessageRequest) error {
+	// The stream outlives this handler, so its context is cancelled by the body writer
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+
+	stream, err := h.service.GenerateMessageStream(ctx, req)
+	if err != nil {
+		cancel()
+		return h.respondError(c, err, req.Model)
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("Transfer-Encoding", "chunked")
+
+	c.RequestCtx().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		send := func(event dto.StreamEvent) bool {
+			return common.SendSSEChunk(w, h.log, event.Type, event) == nil
+		}
+
+		// Content blocks are numbered in order; a text block is closed before a
+		// tool_use block is emitted
+		index := 0
+		textOpen := false
+		toolUses := 0
+		// The blocks are rebuilt from the events the way the client assembles them
+		var content []dto.ConfigContent
+		startText := func() bool {
+			textOpen = true
+			content = append(content, dto.ConfigContent{Type: "text"})
+			return send(dto.StreamEvent{
+				Type:         "content_block_start",
+				Index:        &index,
+				ContentBlock: &dto.ConfigContent{Type: "text", Text: ""},
+			})
+		}
+		stopBlock := func() bool {
+			ok := send(dto.StreamEvent{Type: "content_block_stop", Index: &index})
+			index++
+			return ok
+		}
+		emit := func(text string, calls []common.ToolCall) bool {
+			if text != "" {
+				if !textOpen && !startText() {
+					return false
+				}
+				if !send(dto.StreamEvent{
+					Type:       "content_block_delta",
+					Index:      &index,
+					DeltaField: &models.Delta{Type: "text_delta", Text: text},
+				}) {
+					return false
+				}
+				content[len(content)-1].Text += text
+			}
+			for _, call := range calls {
+				if stream.DisableParallelToolUse && toolUses > 0 {
+					break
+				}
+				if textOpen {
+					textOpen = false
+					if !stopBlock() {
+						return false
+					}
+				}
+				block := NewToolUseBlock(call)
+				content = append(content, block)
+				input := string(block.Input)
+				block.Input = nil
+				if !send(dto.StreamEvent{Type: "content_block_start", Index: &index, ContentBlock: &block}) ||
+					!send(dto.StreamEvent{
+						Type:       "content_block_delta",
+						Index:      &index,
+						DeltaField: &models.Delta{Type: "input_json_delta", PartialJSON: input},
+					}) ||
+					!stopBlock() {
+					return false
+				}
+				toolUses++
+			}
+			return true
+		}
+
+		if !send(dto.StreamEvent{
+			Type: "message_start",
+			Message: &dto.MessageResponse{
+				ID:      stream.ID,
+				Type:    "message",
+				Role:    "assistant",
+				Model:   req.Model,
+				Content: []dto.ConfigContent{},
+				Usage:   models.Usage{InputTokens: stream.InputTokens},
+			},
+		}) {
+			return
+		}
+		// Without tools the text block can be opened right away; with tools the
+		// reply may start with a tool_use block
+		var parser *common.ToolCallParser
+		if stream.Tools {
+			parser = &common.ToolCallParser{}
+		} else if !startText() {
+			return
+		}
+		if !send(dto.StreamEvent{Type: "ping"}) {
+			return
+		}
+
+		ticker := time.NewTicker(pingInterval)
+		defer ticker.Stop()
+
+		chunks := providers.CompleteDeltas(ctx, stream.Chunks)
+		var completion *providers.Response
+		outputTokens := 0
+	loop:
+		for {
+			select {
+			case <-ticker.C:
+				if !send(dto.StreamEvent{Type: "ping"}) {
+					h.log.Info("Stream cancelled by client")
+					return
+				}
+			case chunk, ok := <-chunks:
+				if !ok {
+					h.log.Warn("Stream ended before completion", zap.Error(ctx.Err()), zap.String("model", req.Model))
+					send(dto.StreamEvent{
+						Type:  "error",
+						Error: &dto.ErrorDetail{Type: "api_error", Message: "stream ended before completion"},
+					})
+					return
+				}
+				if chunk.Err != nil {
+					h.log.Error("GenerateContent streaming failed", zap.Error(chunk.Err), zap.String("model", req.Model))
+					send(dto.StreamEvent{
+						Type:  "error",
+						Error: &dto.ErrorDetail{Type: providers.SurfaceErrorInfo(common.SurfaceClaude, chunk.Err).Type, Message: chunk.Err.Error()},
+					})
+					return
+				}
+				if chunk.Response != nil {
+					completion = chunk.Response
+					outputTokens = common.EstimateTokens(chunk.Response.Text)
+					break loop
+				}
+
+				text, calls := chunk.Delta, []common.ToolCall(nil)
+				if parser != nil {
+					text, calls = parser.Feed(chunk.Delta)
+				}
+				if !emit(text, calls) {
+					h.log.Info("Stream cancelled by client")
+					return
+				}
+			}
+		}
+
+		if parser != nil && !emit(parser.Flush()) {
+			return
+		}
+		if !textOpen && index == 0 && !startText() {
+			return
+		}
+		if textOpen {
+			// Sources are only known once the reply is complete
+			for _, citation := range NewCitations(completion.Sources) {
+				if !send(dto.StreamEvent{
+					Type:       "content_block_delta",
+					Index:      &index,
+					DeltaField: &models.Delta{Type: "citations_delta", Citation: &citation},
+				}) {
+					return
+				}
+			}
+			if !stopBlock() {
+				return
+			}
+		}
+		for _, block := range NewImageBlocks(completion.Images) {
+			if !send(dto.StreamEvent{Type: "content_block_start", Index: &index, ContentBlock: &block}) || !stopBlock() {
+				return
+			}
+		}
+
+		stream.Commit(content)
+
+		stopReason := "end_turn"
+		if toolUses > 0 {
+			stopReason = "tool_use"
+		}
+		if !send(dto.StreamEvent{
+			Type:       "message_delta",
+			DeltaField: &models.Delta{StopReason: stopReason},
+			UsageField: &models.Usage{OutputTokens: outputTokens},
+		}) {
+			return
+		}
+		send(dto.StreamEvent{Type: "message_stop"})
+	})
+
+	return nil
+}
+
+// respondError writes a service error as an Anthropic error body with a matching status
+func (h *ClaudeController) respondError(c fiber.Ctx, err error, model string) error {
+	if errors.Is(err, providers.ErrModelNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(fiber.Map{
 			"type":  "error",
-			"error": fiber.Map{"type": "api_error", "message": err.Error()},
+			"error": fiber.Map{"type": "not_found_error", "message": fmt.Sprintf("model: %s", model)},
 		})
 	}
 
-	return c.JSON(response)
+	info := providers.SurfaceErrorInfo(common.SurfaceClaude, err)
+	if info.Status == fiber.StatusInternalServerError {
+		h.log.Error("GenerateContent failed", zap.Error(err), zap.String("model", model))
+	} else {
+		h.log.Warn("GenerateContent failed upstream", zap.Error(err), zap.String("model", model))
+	}
+	return c.Status(info.Status).JSON(common.SurfaceErrorBody(common.SurfaceClaude, info.Status, info.Type, info.Code, err.Error()))
 }
 
 // HandleCountTokens handles token counting
@@ -2,12 +2,14 @@ package claude
 
 import (
 	"context"
+	"encoding/json"
 	"fmt"
-	"strings"
 
 	"gemini-web-to-api/internal/commons/models"
+	"gemini-web-to-api/internal/commons/tracing"
 	common "gemini-web-to-api/internal/commons/utils"
 	"gemini-web-to-api/internal/modules/claude/dto"
+	"gemini-web-to-api/internal/modules/conversation"
 	"gemini-web-to-api/internal/modules/providers"
 
 	"github.com/google/uuid"
@@ -15,58 +17,96 @@ import (
 )
 
 type ClaudeService struct {
-	client *providers.Client
-	log    *zap.Logger
+	client        *providers.AccountPool
+	conversations *conversation.ConversationService
+	log           *zap.Logger
 }
 
-func NewClaudeService(client *providers.Client, log *zap.Logger) *ClaudeService {
+func NewClaudeService(client *providers.AccountPool, conversations *conversation.ConversationService, log *zap.Logger) *ClaudeService {
 	return &ClaudeService{
-		client: client,
-		log:    log,
+		client:        client,
+		conversations: conversations,
+		log:           log,
 	}
 }
 
-func (s *ClaudeService) GenerateMessage(ctx context.Context, req dto.MessageRequest) (*dto.MessageResponse, error) {
-	// Logic: Validate
-	if err := common.ValidateMessages(req.Messages); err != nil {
-		return nil, err
-	}
+// MessageStream is a streaming message that has been accepted upstream
+type MessageStream struct {
+	ID          string
+	InputTokens int
+	Chunks      <-chan providers.StreamChunk
 
-	// Logic: Build Prompt
-	systemText := common.GetMessageText(req.System)
-	prompt := common.BuildPromptFromMessages(req.Messages, systemText)
-	if prompt == "" {
-		return nil, fmt.Errorf("no valid content in messages")
-	}
+	// Tools is set when the reply must be parsed for emulated tool calls
+	Tools                  bool
+	DisableParallelToolUse bool
 
-	// Model mapping logic
-	targetModel := "gemini-3-pro" // Default for sonnet
-	modelName := strings.ToLower(req.Model)
-	if modelName == "" {
-		modelName = "claude-sonnet-4-6"
-	}
+	turn *conversation.Turn
+}
 
-	if strings.Contains(modelName, "opus") {
-		targetModel = "gemini-3.1-pro-preview"
-	} else if strings.Contains(modelName, "sonnet") {
-		targetModel = "gemini-3-pro"
-	} else if strings.Contains(modelName, "haiku") {
-		targetModel = "gemini-3-flash"
-	}
+// Commit records the streamed content blocks as the client received them, so
+// a follow-up request continues the same Gemini conversation
+func (s *MessageStream) Commit(content []dto.ConfigContent) {
+	s.turn.Commit(newReplyMessage(content))
+}
 
-	opts := []providers.GenerateOption{
-		providers.WithModel(targetModel),
+// messagePrompt is a validated request flattened into a provider prompt
+type messagePrompt struct {
+	turn                   *conversation.Turn
+	text                   string
+	opts                   []providers.GenerateOption
+	tools                  bool
+	disableParallelToolUse bool
+}
+
+func (s *ClaudeService) ListModels() []providers.ModelInfo {
+	return s.client.ListModels()
+}
+
+func (s *ClaudeService) GetModel(id string) (*providers.ModelInfo, error) {
+	return s.client.ResolveModel(id)
+}
+
+func (s *ClaudeService) GenerateMessage(ctx context.Context, req dto.MessageRequest) (*dto.MessageResponse, error) {
+	ctx, span := tracing.Start(ctx, "ClaudeService.GenerateMessage",
+		tracing.AttrSurface.String(common.SurfaceClaude),
+		tracing.AttrModel.String(req.Model),
+	)
+	defer span.End()
+
+	prompt, err := s.preparePrompt(req)
+	if err != nil {
+		return nil, err
 	}
 
 	// Logic: Call Provider
-	response, err := s.client.GenerateContent(ctx, prompt, opts...)
+	response, err := prompt.turn.Send(ctx, prompt.opts...)
 	if err != nil {
 		return nil, err
 	}
 
 	// Logic: Construct Response
 	msgID := fmt.Sprintf("msg_%s", uuid.New().String())
-	content := []dto.ConfigContent{{Type: "text", Text: response.Text}}
+	citations := NewCitations(response.Sources)
+	content := []dto.ConfigContent{{Type: "text", Text: response.Text, Citations: citations}}
+	stopReason := "end_turn"
+	if prompt.tools {
+		text, calls := common.ExtractToolCalls(response.Text)
+		if len(calls) > 0 {
+			content = []dto.ConfigContent{}
+			if text != "" {
+				content = append(content, dto.ConfigContent{Type: "text", Text: text, Citations: citations})
+			}
+			if prompt.disableParallelToolUse {
+				calls = calls[:1]
+			}
+			for _, call := range calls {
+				content = append(content, NewToolUseBlock(call))
+			}
+			stopReason = "tool_use"
+		}
+	}
+	content = append(content, NewImageBlocks(response.Images)...)
+	prompt.turn.Commit(newReplyMessage(content))
 
 	return &dto.MessageResponse{
 		ID:         msgID,
@@ -74,10 +114,158 @@ func (s *ClaudeService) GenerateMessage(ctx context.Context, req dto.MessageRequ
 		Role:       "assistant",
 		Model:      req.Model,
 		Content:    content,
-		StopReason: "end_turn",
+		StopReason: stopReason,
 		Usage: models.Usage{
-			InputTokens:  len(prompt) / 4,
-			OutputTokens: len(response.Text) / 4,
+			InputTokens:  common.EstimateTokens(prompt.text),
+			OutputTokens: common.EstimateTokens(response.Text),
 		},
 	}, nil
 }
+
+// GenerateMessageStream starts a streaming message. Errors returned here happen
+// before any event is sent; later failures arrive on the chunk channel.
+func (s *ClaudeService) GenerateMessageStream(ctx context.Context, req dto.MessageRequest) (*MessageStream, error) {
+	ctx, span := tracing.Start(ctx, "ClaudeService.GenerateMessageStream",
+		tracing.AttrSurface.String(common.SurfaceClaude),
+		tracing.AttrModel.String(req.Model),
+	)
+	defer span.End()
+
+	prompt, err := s.preparePrompt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := prompt.turn.SendStream(ctx, prompt.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &MessageStream{
+		ID:                     fmt.Sprintf("msg_%s", uuid.New().String()),
+		InputTokens:            common.EstimateTokens(prompt.text),
+		Chunks:                 chunks,
+		Tools:                  prompt.tools,
+		DisableParallelToolUse: prompt.disableParallelToolUse,
+		turn:                   prompt.turn,
+	}, nil
+}
+
+// NewToolUseBlock converts a parsed tool call to a tool_use content block with a fresh ID
+func NewToolUseBlock(call common.ToolCall) dto.ConfigContent {
+	return dto.ConfigContent{
+		Type:  "tool_use",
+		ID:    common.NewToolCallID("toolu_"),
+		Name:  call.Name,
+		Input: call.Arguments,
+	}
+}
+
+// NewImageBlocks converts response images to image content blocks
+func NewImageBlocks(images []providers.Image) []dto.ConfigContent {
+	var blocks []dto.ConfigContent
+	for _, img := range images {
+		blocks = append(blocks, dto.ConfigContent{
+			Type:   "image",
+			Source: &dto.ImageSource{Type: "url", URL: img.URL},
+		})
+	}
+	return blocks
+}
+
+// newReplyMessage converts content blocks to the assistant message a client
+// sends back on the next turn
+func newReplyMessage(content []dto.ConfigContent) models.Message {
+	var blocks []interface{}
+	if data, err := json.Marshal(content); err == nil {
+		_ = json.Unmarshal(data, &blocks)
+	}
+	return models.Message{Role: "assistant", Content: blocks}
+}
+
+// NewCitations converts cited sources to web citations of the text block
+func NewCitations(sources []providers.Source) []models.Citation {
+	var citations []models.Citation
+	for _, source := range sources {
+		citations = append(citations, models.Citation{
+			Type:  "web_search_result_location",
+			URL:   source.URL,
+			Title: source.Title,
+		})
+	}
+	return citations
+}
+
+// preparePrompt validates the request, flattens it into a prompt and maps the Claude model
+func (s *ClaudeService) preparePrompt(req dto.MessageRequest) (*messagePrompt, error) {
+	// Logic: Validate
+	if err := common.ValidateMessages(req.Messages); err != nil {
+		return nil, err
+	}
+
+	tools, choice, err := parseTools(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logic: Build Prompt, continuing the conversation the history belongs to
+	systemText := common.GetMessageText(req.System)
+	turn := s.conversations.Begin(req.Model, systemText, req.Messages, common.WithTools(tools, choice))
+	if turn.Prompt == "" {
+		return nil, common.InvalidRequestf("no valid content in messages")
+	}
+
+	// Logic: Collect images and documents not yet sent to the conversation
+	err = turn.CollectAttachments(func(first int) ([]common.Attachment, error) {
+		return common.ExtractAttachments(req.Messages[first:])
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Claude model names are mapped to Gemini through registry aliases
+	opts := []providers.GenerateOption{
+		providers.WithModel(req.Model),
+	}
+	return &messagePrompt{
+		turn:                   turn,
+		text:                   turn.Prompt,
+		opts:                   opts,
+		tools:                  common.ToolsActive(tools, choice),
+		disableParallelToolUse: req.ToolChoice != nil && req.ToolChoice.DisableParallelToolUse,
+	}, nil
+}
+
+// parseTools converts the request's tools and tool_choice
+func parseTools(req dto.MessageRequest) ([]common.ToolDefinition, common.ToolChoice, error) {
+	var tools []common.ToolDefinition
+	for _, tool := range req.Tools {
+		if tool.Name == "" {
+			return nil, common.ToolChoice{}, common.InvalidRequestf("tools: name is required")
+		}
+		tools = append(tools, common.ToolDefinition{
+			Name:        tool.Name,
+			Description: tool.Description,
+			Parameters:  tool.InputSchema,
+		})
+	}
+
+	choice := common.ToolChoice{Mode: common.ToolChoiceAuto}
+	if req.ToolChoice != nil {
+		switch req.ToolChoice.Type {
+		case "", "auto":
+		case "none":
+			choice.Mode = common.ToolChoiceNone
+		case "any":
+			choice.Mode = common.ToolChoiceRequired
+		case "tool":
+			if req.ToolChoice.Name == "" {
+				return nil, common.ToolChoice{}, common.InvalidRequestf("tool_choice: name is required for type tool")
+			}
+			choice = common.ToolChoice{Mode: common.ToolChoiceRequired, Name: req.ToolChoice.Name}
+		default:
+			return nil, common.ToolChoice{}, common.InvalidRequestf("tool_choice: invalid type %s", req.ToolChoice.Type)
+		}
+	}
+	return tools, choice, nil
+}
@@ -1,6 +1,10 @@
 package dto
 
-import models "gemini-web-to-api/internal/commons/models"
+import (
+	"encoding/json"
+
+	models "gemini-web-to-api/internal/commons/models"
+)
 
 // MessageRequest represents the specialized Claude request body
 type MessageRequest struct {
@@ -9,6 +13,24 @@ type MessageRequest struct {
 	Messages  []models.Message `json:"messages"`
 	System    interface{}      `json:"system,omitempty"` // Can be string or []interface{}
 	Stream    bool             `json:"stream,omitempty"`
+
+	Tools      []Tool      `json:"tools,omitempty"`
+	ToolChoice *ToolChoice `json:"tool_choice,omitempty"`
+}
+
+// Tool represents a tool definition
+type Tool struct {
+	Type        string      `json:"type,omitempty"` // "custom" or empty for client tools
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	InputSchema interface{} `json:"input_schema,omitempty"` // JSON schema
+}
+
+// ToolChoice controls how the model uses tools
+type ToolChoice struct {
+	Type                   string `json:"type"` // "auto", "any", "tool" or "none"
+	Name                   string `json:"name,omitempty"`
+	DisableParallelToolUse bool   `json:"disable_parallel_tool_use,omitempty"`
 }
 
 // MessageResponse represents the non-streaming response body
@@ -18,23 +40,74 @@ type MessageResponse struct {
 	Role       string          `json:"role"` // "assistant"
 	Model      string          `json:"model"`
 	Content    []ConfigContent `json:"content"`
-	StopReason string          `json:"stop_reason"`
+	StopReason string          `json:"stop_reason,omitempty"` // absent in message_start
 	Usage      models.Usage    `json:"usage"`
 }
 
 // ConfigContent represents the content block in a response
 type ConfigContent struct {
-	Type string `json:"type"` // "text"
+	Type string `json:"type"` // "text", "tool_use" or "image"
 	Text string `json:"text"`
+
+	// text blocks
+	Citations []models.Citation `json:"citations,omitempty"`
+
+	// tool_use blocks
+	ID    string          `json:"id,omitempty"`
+	Name  string          `json:"name,omitempty"`
+	Input json.RawMessage `json:"input,omitempty" swaggertype:"object"`
+
+	// image blocks
+	Source *ImageSource `json:"source,omitempty"`
+}
+
+// ImageSource represents the source of an image block
+type ImageSource struct {
+	Type string `json:"type"` // "url"
+	URL  string `json:"url"`
+}
+
+// MarshalJSON emits only the fields of the block's type, so text blocks always
+// carry "text" and other blocks never do
+func (c ConfigContent) MarshalJSON() ([]byte, error) {
+	switch c.Type {
+	case "tool_use":
+		input := c.Input
+		if len(input) == 0 {
+			input = json.RawMessage("{}")
+		}
+		return json.Marshal(struct {
+			Type  string          `json:"type"`
+			ID    string          `json:"id"`
+			Name  string          `json:"name"`
+			Input json.RawMessage `json:"input"`
+		}{c.Type, c.ID, c.Name, input})
+	case "image":
+		return json.Marshal(struct {
+			Type   string       `json:"type"`
+			Source *ImageSource `json:"source"`
+		}{c.Type, c.Source})
+	}
+	return json.Marshal(struct {
+		Type      string            `json:"type"`
+		Text      string            `json:"text"`
+		Citations []models.Citation `json:"citations,omitempty"`
+	}{c.Type, c.Text, c.Citations})
 }
 
 // StreamEvent represents a streaming event
 type StreamEvent struct {
 	Type         string           `json:"type"`                    // e.g. message_start, content_block_delta
 	Message      *MessageResponse `json:"message,omitempty"`       // present in message_start
-	Index        int              `json:"index,omitempty"`         // present in content_block_start/delta
+	Index        *int             `json:"index,omitempty"`         // present in content_block_start/delta/stop
 	ContentBlock *ConfigContent   `json:"content_block,omitempty"` // present in content_block_start
-	DeltaField   *models.Delta    `json:"delta,omitempty"`         // present in content_block_delta
-	StopReason   string           `json:"stop_reason,omitempty"`   // present in message_stop
-	UsageField   *models.Usage    `json:"usage,omitempty"`         // present in message_delta (optional?) but essential in message_stop sometimes
+	DeltaField   *models.Delta    `json:"delta,omitempty"`         // present in content_block_delta and message_delta
+	UsageField   *models.Usage    `json:"usage,omitempty"`         // present in message_delta
+	Error        *ErrorDetail     `json:"error,omitempty"`         // present in error
+}
+
+// ErrorDetail represents the error object of an Anthropic error body or event
+type ErrorDetail struct {
+	Type    string `json:"type"`
+	Message string `json:"message"`
 }
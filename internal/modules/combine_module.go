@@ -1,16 +1,28 @@
 package modules
 
 import (
+"gemini-web-to-api/internal/modules/auth"
 "gemini-web-to-api/internal/modules/claude"
+"gemini-web-to-api/internal/modules/conversation"
 "gemini-web-to-api/internal/modules/gemini"
+"gemini-web-to-api/internal/modules/health"
 "gemini-web-to-api/internal/modules/openai"
 "gemini-web-to-api/internal/modules/providers"
+"gemini-web-to-api/internal/modules/responses"
+"gemini-web-to-api/internal/modules/sessions"
+"gemini-web-to-api/internal/modules/telemetry"
 "go.uber.org/fx"
 )
 
 var Module = fx.Options(
+health.Module, // probes come before the middleware, so they are not traced, measured or authenticated
+telemetry.Module, // must come before auth so rejected requests are traced and measured too
+auth.Module, // must come before the routes so the middleware runs first
 gemini.Module,
 claude.Module,
 openai.Module,
+responses.Module,
+conversation.Module,
+sessions.Module,
 providers.Module,
 )
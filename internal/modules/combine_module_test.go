@@ -0,0 +1,647 @@
+package modules_test
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/modules"
+	"gemini-web-to-api/internal/modules/providers"
+	"gemini-web-to-api/internal/server"
+	"gemini-web-to-api/pkg/fakegemini"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// API keys of the test app: fullKey may use any model, flashKey only gemini-3-flash
+const (
+	fullKey  = "sk-test-full"
+	flashKey = "sk-test-flash"
+)
+
+const testKeys = `keys:
+  - name: full
+    key: ` + fullKey + `
+  - name: flash
+    key: ` + flashKey + `
+    models: ["gemini-3-flash"]
+`
+
+// citedReply streams a reply whose citation Gemini rewrites while it is still open
+var citedReply = fakegemini.Reply{Steps: []string{
+	"Paris is",
+	"Paris is the capital [",
+	"Paris is the capital [1",
+	"Paris is the capital [Wiki](https://example.com/paris).",
+	"Paris is the capital [Wiki](https://example.com/paris). It lies on the Seine.",
+}}
+
+// The app is built once: the provider metrics register on the global registry
+var (
+	app  *fiber.App
+	fake *fakegemini.Server
+)
+
+func TestMain(m *testing.M) {
+	os.Exit(run(m))
+}
+
+// run builds the whole application against the fake Gemini backend and runs the tests
+func run(m *testing.M) int {
+	// The account cookie cache is written to the working directory
+	dir, err := os.MkdirTemp("", "modules-test")
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer os.RemoveAll(dir)
+	if err := os.Chdir(dir); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+
+	keysFile := filepath.Join(dir, "keys.yaml")
+	if err := os.WriteFile(keysFile, []byte(testKeys), 0o600); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	os.Setenv("GEMINI_MOCK", "true")
+	os.Setenv("API_KEYS_FILE", keysFile)
+
+	fake = fakegemini.NewServer()
+	fxApp := fx.New(
+		fx.Provide(configs.New, zap.NewNop, server.NewGeminiWebToAPI),
+		modules.Module,
+		fx.Decorate(func() *providers.Upstream {
+			return &providers.Upstream{Endpoints: providers.NewEndpoints("http://fakegemini.local"), Transport: fake.Transport()}
+		}),
+		fx.Populate(&app),
+		fx.NopLogger,
+	)
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	if err := fxApp.Start(ctx); err != nil {
+		fmt.Fprintln(os.Stderr, err)
+		return 1
+	}
+	defer fxApp.Stop(ctx)
+
+	return m.Run()
+}
+
+// request sends a JSON request authenticated with key to the app
+func request(t *testing.T, method, path, key string, body interface{}) *http.Response {
+	t.Helper()
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		reader = bytes.NewReader(data)
+	}
+	req := httptest.NewRequest(method, path, reader)
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+key)
+	resp, err := app.Test(req, fiber.TestConfig{Timeout: 10 * time.Second})
+	if err != nil {
+		t.Fatalf("%s %s: %v", method, path, err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+// decode checks the status of a response and decodes its JSON body into v
+func decode(t *testing.T, resp *http.Response, status int, v interface{}) {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != status {
+		t.Fatalf("status = %d, want %d: %s", resp.StatusCode, status, data)
+	}
+	if v != nil {
+		if err := json.Unmarshal(data, v); err != nil {
+			t.Fatalf("decoding %s: %v", data, err)
+		}
+	}
+}
+
+// sseEvent is one Server-Sent Event of a streamed response
+type sseEvent struct {
+	Name string
+	Data string
+}
+
+// readEvents checks that a response is an event stream and returns its events
+func readEvents(t *testing.T, resp *http.Response) []sseEvent {
+	t.Helper()
+	data, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if resp.StatusCode != fiber.StatusOK {
+		t.Fatalf("status = %d, want 200: %s", resp.StatusCode, data)
+	}
+	if contentType := resp.Header.Get("Content-Type"); !strings.HasPrefix(contentType, "text/event-stream") {
+		t.Fatalf("Content-Type = %q, want text/event-stream", contentType)
+	}
+
+	var events []sseEvent
+	for _, block := range strings.Split(string(data), "\n\n") {
+		if strings.TrimSpace(block) == "" {
+			continue
+		}
+		var event sseEvent
+		for _, line := range strings.Split(block, "\n") {
+			if name, ok := strings.CutPrefix(line, "event: "); ok {
+				event.Name = name
+			} else if data, ok := strings.CutPrefix(line, "data: "); ok {
+				event.Data = data
+			} else {
+				t.Fatalf("malformed event line %q in %q", line, block)
+			}
+		}
+		events = append(events, event)
+	}
+	return events
+}
+
+// unmarshal decodes the data of an event into v
+func unmarshal(t *testing.T, event sseEvent, v interface{}) {
+	t.Helper()
+	if err := json.Unmarshal([]byte(event.Data), v); err != nil {
+		t.Fatalf("decoding %s event %q: %v", event.Name, event.Data, err)
+	}
+}
+
+func userMessage(text string) []map[string]interface{} {
+	return []map[string]interface{}{{"role": "user", "content": text}}
+}
+
+func TestOpenAIStreamMatchesReply(t *testing.T) {
+	fake.Enqueue(citedReply, citedReply)
+	body := map[string]interface{}{"model": "gemini-3-flash", "messages": userMessage("What is the capital of France?")}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content string `json:"content"`
+			} `json:"message"`
+		} `json:"choices"`
+	}
+	decode(t, request(t, "POST", "/v1/chat/completions", fullKey, body), fiber.StatusOK, &completion)
+	if len(completion.Choices) != 1 {
+		t.Fatalf("got %d choices, want 1", len(completion.Choices))
+	}
+
+	body["stream"] = true
+	events := readEvents(t, request(t, "POST", "/v1/chat/completions", fullKey, body))
+	if last := events[len(events)-1]; last.Data != "[DONE]" {
+		t.Fatalf("last event = %q, want [DONE]", last.Data)
+	}
+	var streamed strings.Builder
+	finishReason := ""
+	for _, event := range events[:len(events)-1] {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content string `json:"content"`
+				} `json:"delta"`
+				FinishReason *string `json:"finish_reason"`
+			} `json:"choices"`
+		}
+		unmarshal(t, event, &chunk)
+		for _, choice := range chunk.Choices {
+			streamed.WriteString(choice.Delta.Content)
+			if choice.FinishReason != nil {
+				finishReason = *choice.FinishReason
+			}
+		}
+	}
+	if got, want := streamed.String(), completion.Choices[0].Message.Content; got != want {
+		t.Errorf("streamed %q, want %q", got, want)
+	}
+	if finishReason != "stop" {
+		t.Errorf("finish_reason = %q, want stop", finishReason)
+	}
+}
+
+func TestClaudeStreamMatchesReply(t *testing.T) {
+	fake.Enqueue(citedReply, citedReply)
+	body := map[string]interface{}{
+		"model":      "claude-sonnet-4-6",
+		"max_tokens": 1024,
+		"messages":   userMessage("What is the capital of France?"),
+	}
+
+	var message struct {
+		Content []struct {
+			Type string `json:"type"`
+			Text string `json:"text"`
+		} `json:"content"`
+	}
+	decode(t, request(t, "POST", "/v1/messages", fullKey, body), fiber.StatusOK, &message)
+	var reply strings.Builder
+	for _, block := range message.Content {
+		reply.WriteString(block.Text)
+	}
+
+	body["stream"] = true
+	events := readEvents(t, request(t, "POST", "/v1/messages", fullKey, body))
+	if first, last := events[0].Name, events[len(events)-1].Name; first != "message_start" || last != "message_stop" {
+		t.Fatalf("stream runs from %q to %q, want message_start to message_stop", first, last)
+	}
+	var streamed strings.Builder
+	for _, event := range events {
+		var data struct {
+			Type  string `json:"type"`
+			Delta struct {
+				Type string `json:"type"`
+				Text string `json:"text"`
+			} `json:"delta"`
+		}
+		unmarshal(t, event, &data)
+		if data.Type != event.Name {
+			t.Errorf("event %q carries type %q", event.Name, data.Type)
+		}
+		if data.Type == "content_block_delta" && data.Delta.Type == "text_delta" {
+			streamed.WriteString(data.Delta.Text)
+		}
+	}
+	if got, want := streamed.String(), reply.String(); got != want {
+		t.Errorf("streamed %q, want %q", got, want)
+	}
+}
+
+func TestGeminiStreamMatchesReply(t *testing.T) {
+	fake.Enqueue(citedReply, citedReply)
+	body := map[string]interface{}{
+		"contents": []map[string]interface{}{{"role": "user", "parts": []map[string]string{{"text": "What is the capital of France?"}}}},
+	}
+	type candidates struct {
+		Candidates []struct {
+			Content struct {
+				Parts []struct {
+					Text string `json:"text"`
+				} `json:"parts"`
+			} `json:"content"`
+		} `json:"candidates"`
+	}
+	text := func(response candidates) string {
+		var sb strings.Builder
+		for _, candidate := range response.Candidates {
+			for _, part := range candidate.Content.Parts {
+				sb.WriteString(part.Text)
+			}
+		}
+		return sb.String()
+	}
+
+	var response candidates
+	decode(t, request(t, "POST", "/gemini/v1beta/models/gemini-3-flash:generateContent", fullKey, body), fiber.StatusOK, &response)
+
+	events := readEvents(t, request(t, "POST", "/gemini/v1beta/models/gemini-3-flash:streamGenerateContent?alt=sse", fullKey, body))
+	var streamed strings.Builder
+	for _, event := range events {
+		var chunk candidates
+		unmarshal(t, event, &chunk)
+		streamed.WriteString(text(chunk))
+	}
+	if got, want := streamed.String(), text(response); got != want {
+		t.Errorf("streamed %q, want %q", got, want)
+	}
+}
+
+// responseText joins the output text of a Responses API response
+type responseText struct {
+	ID     string `json:"id"`
+	Status string `json:"status"`
+	Output []struct {
+		Content []struct {
+			Text string `json:"text"`
+		} `json:"content"`
+	} `json:"output"`
+}
+
+func (r responseText) text() string {
+	var sb strings.Builder
+	for _, item := range r.Output {
+		for _, content := range item.Content {
+			sb.WriteString(content.Text)
+		}
+	}
+	return sb.String()
+}
+
+func TestResponsesStreamMatchesReply(t *testing.T) {
+	fake.Enqueue(citedReply)
+	body := map[string]interface{}{"model": "gemini-3-flash", "input": "What is the capital of France?", "stream": true}
+
+	events := readEvents(t, request(t, "POST", "/v1/responses", fullKey, body))
+	var streamed strings.Builder
+	var completed *responseText
+	for _, event := range events {
+		var data struct {
+			Type     string        `json:"type"`
+			Delta    string        `json:"delta"`
+			Response *responseText `json:"response"`
+		}
+		unmarshal(t, event, &data)
+		switch data.Type {
+		case "response.output_text.delta":
+			streamed.WriteString(data.Delta)
+		case "response.completed":
+			completed = data.Response
+		}
+	}
+	if completed == nil {
+		t.Fatal("no response.completed event")
+	}
+	if got, want := streamed.String(), completed.text(); got != want {
+		t.Errorf("streamed %q, want %q", got, want)
+	}
+
+	// The stored response is the one the stream completed
+	var stored responseText
+	decode(t, request(t, "GET", "/v1/responses/"+completed.ID, fullKey, nil), fiber.StatusOK, &stored)
+	if stored.text() != completed.text() {
+		t.Errorf("stored text %q, want %q", stored.text(), completed.text())
+	}
+}
+
+// session is the part of a session the tests read
+type session struct {
+	ID             string `json:"id"`
+	Model          string `json:"model"`
+	ConversationID string `json:"conversation_id"`
+	MessageCount   int    `json:"message_count"`
+}
+
+func createSession(t *testing.T, model string) session {
+	t.Helper()
+	var s session
+	decode(t, request(t, "POST", "/sessions", fullKey, map[string]string{"model": model}), fiber.StatusOK, &s)
+	return s
+}
+
+func TestSessionStreamMatchesReply(t *testing.T) {
+	s := createSession(t, "gemini-3-flash")
+	fake.Enqueue(citedReply)
+
+	events := readEvents(t, request(t, "POST", "/sessions/"+s.ID+"/messages", fullKey, map[string]interface{}{
+		"message": "What is the capital of France?",
+		"stream":  true,
+	}))
+	var streamed strings.Builder
+	for _, event := range events[:len(events)-1] {
+		if event.Name != "delta" {
+			t.Fatalf("got %q event before the end, want delta", event.Name)
+		}
+		var delta struct {
+			Text string `json:"text"`
+		}
+		unmarshal(t, event, &delta)
+		streamed.WriteString(delta.Text)
+	}
+	last := events[len(events)-1]
+	if last.Name != "message" {
+		t.Fatalf("last event = %q, want message", last.Name)
+	}
+	var reply struct {
+		Message struct {
+			Content string `json:"content"`
+		} `json:"message"`
+	}
+	unmarshal(t, last, &reply)
+	if got, want := streamed.String(), reply.Message.Content; got != want {
+		t.Errorf("streamed %q, want %q", got, want)
+	}
+}
+
+func TestSessionLifecycle(t *testing.T) {
+	s := createSession(t, "gemini-3-flash")
+	if s.Model != "gemini-3-flash" || s.MessageCount != 0 {
+		t.Fatalf("created %+v", s)
+	}
+
+	sent := len(fake.Requests())
+	fake.Enqueue(fakegemini.Reply{Text: "Hello"}, fakegemini.Reply{Text: "Again"})
+	for _, message := range []string{"Hi", "And again"} {
+		decode(t, request(t, "POST", "/sessions/"+s.ID+"/messages", fullKey, map[string]string{"message": message}), fiber.StatusOK, nil)
+	}
+	requests := fake.Requests()[sent:]
+	if len(requests) != 2 {
+		t.Fatalf("got %d upstream requests, want 2", len(requests))
+	}
+	if requests[1].Conversation.ID == "" || requests[1].Prompt != "And again" {
+		t.Errorf("second message sent %+v, want only the new message in the first conversation", requests[1])
+	}
+
+	decode(t, request(t, "GET", "/sessions/"+s.ID, fullKey, nil), fiber.StatusOK, &s)
+	if s.MessageCount != 4 || s.ConversationID != requests[1].Conversation.ID {
+		t.Errorf("session after two messages = %+v", s)
+	}
+	var history struct {
+		Messages []struct {
+			Role    string `json:"role"`
+			Content string `json:"content"`
+		} `json:"messages"`
+	}
+	decode(t, request(t, "GET", "/sessions/"+s.ID+"/history", fullKey, nil), fiber.StatusOK, &history)
+	if len(history.Messages) != 4 || history.Messages[3].Content != "Again" {
+		t.Errorf("history = %+v", history.Messages)
+	}
+
+	var cleared session
+	decode(t, request(t, "POST", "/sessions/"+s.ID+"/clear", fullKey, nil), fiber.StatusOK, &cleared)
+	if cleared.MessageCount != 0 || cleared.ConversationID != "" {
+		t.Errorf("cleared session = %+v", cleared)
+	}
+
+	decode(t, request(t, "DELETE", "/sessions/"+s.ID, fullKey, nil), fiber.StatusOK, nil)
+	decode(t, request(t, "GET", "/sessions/"+s.ID, fullKey, nil), fiber.StatusNotFound, nil)
+}
+
+func TestConversationReused(t *testing.T) {
+	sent := len(fake.Requests())
+	fake.Enqueue(fakegemini.Reply{Text: "Bonjour!"}, fakegemini.Reply{Text: "Hallo!"})
+	messages := userMessage("Say hello in French")
+	decode(t, request(t, "POST", "/v1/chat/completions", fullKey, map[string]interface{}{"model": "gemini-3-flash", "messages": messages}), fiber.StatusOK, nil)
+
+	messages = append(messages,
+		map[string]interface{}{"role": "assistant", "content": "Bonjour!"},
+		map[string]interface{}{"role": "user", "content": "Now in German"},
+	)
+	decode(t, request(t, "POST", "/v1/chat/completions", fullKey, map[string]interface{}{"model": "gemini-3-flash", "messages": messages}), fiber.StatusOK, nil)
+
+	requests := fake.Requests()[sent:]
+	if len(requests) != 2 {
+		t.Fatalf("got %d upstream requests, want 2", len(requests))
+	}
+	if requests[1].Conversation.ID == "" {
+		t.Error("follow-up started a new conversation, want the cached one continued")
+	}
+	if strings.Contains(requests[1].Prompt, "Say hello in French") {
+		t.Errorf("follow-up resent the history: %q", requests[1].Prompt)
+	}
+}
+
+func TestResponsesChain(t *testing.T) {
+	sent := len(fake.Requests())
+	fake.Enqueue(fakegemini.Reply{Text: "Blue."}, fakegemini.Reply{Text: "Because of Rayleigh scattering."})
+
+	var first responseText
+	decode(t, request(t, "POST", "/v1/responses", fullKey, map[string]interface{}{"model": "gemini-3-flash", "input": "What colour is the sky?"}), fiber.StatusOK, &first)
+	if first.Status != "completed" || first.text() != "Blue." {
+		t.Fatalf("first response = %+v", first)
+	}
+
+	var second responseText
+	decode(t, request(t, "POST", "/v1/responses", fullKey, map[string]interface{}{
+		"model":                "gemini-3-flash",
+		"input":                "Why?",
+		"previous_response_id": first.ID,
+	}), fiber.StatusOK, &second)
+	if second.text() != "Because of Rayleigh scattering." {
+		t.Errorf("second response = %+v", second)
+	}
+	requests := fake.Requests()[sent:]
+	if len(requests) != 2 || requests[1].Conversation.ID == "" {
+		t.Errorf("chained response did not continue the conversation: %+v", requests)
+	}
+
+	decode(t, request(t, "DELETE", "/v1/responses/"+first.ID, fullKey, nil), fiber.StatusOK, nil)
+	decode(t, request(t, "GET", "/v1/responses/"+first.ID, fullKey, nil), fiber.StatusNotFound, nil)
+}
+
+func TestOpenAIToolCall(t *testing.T) {
+	sent := len(fake.Requests())
+	reply := fakegemini.Reply{Text: `Let me check. <tool_call>{"name":"get_weather","arguments":{"city":"Paris"}}</tool_call>`}
+	fake.Enqueue(reply, reply)
+	body := map[string]interface{}{
+		"model":    "gemini-3-flash",
+		"messages": userMessage("What is the weather in Paris?"),
+		"tools": []map[string]interface{}{{
+			"type":     "function",
+			"function": map[string]interface{}{"name": "get_weather", "parameters": map[string]interface{}{"type": "object"}},
+		}},
+	}
+
+	var completion struct {
+		Choices []struct {
+			Message struct {
+				Content   string `json:"content"`
+				ToolCalls []struct {
+					Function struct {
+						Name      string `json:"name"`
+						Arguments string `json:"arguments"`
+					} `json:"function"`
+				} `json:"tool_calls"`
+			} `json:"message"`
+			FinishReason string `json:"finish_reason"`
+		} `json:"choices"`
+	}
+	decode(t, request(t, "POST", "/v1/chat/completions", fullKey, body), fiber.StatusOK, &completion)
+	choice := completion.Choices[0]
+	if choice.FinishReason != "tool_calls" || len(choice.Message.ToolCalls) != 1 {
+		t.Fatalf("choice = %+v, want one tool call", choice)
+	}
+	if call := choice.Message.ToolCalls[0].Function; call.Name != "get_weather" || call.Arguments != `{"city":"Paris"}` {
+		t.Errorf("tool call = %+v", call)
+	}
+	if strings.Contains(choice.Message.Content, "<tool_call>") {
+		t.Errorf("content kept the tool call block: %q", choice.Message.Content)
+	}
+	if prompt := fake.Requests()[sent].Prompt; !strings.Contains(prompt, "get_weather") {
+		t.Errorf("prompt does not describe the tools: %q", prompt)
+	}
+
+	body["stream"] = true
+	events := readEvents(t, request(t, "POST", "/v1/chat/completions", fullKey, body))
+	var streamed strings.Builder
+	var names []string
+	for _, event := range events[:len(events)-1] {
+		var chunk struct {
+			Choices []struct {
+				Delta struct {
+					Content   string `json:"content"`
+					ToolCalls []struct {
+						Function struct {
+							Name string `json:"name"`
+						} `json:"function"`
+					} `json:"tool_calls"`
+				} `json:"delta"`
+			} `json:"choices"`
+		}
+		unmarshal(t, event, &chunk)
+		for _, choice := range chunk.Choices {
+			streamed.WriteString(choice.Delta.Content)
+			for _, call := range choice.Delta.ToolCalls {
+				names = append(names, call.Function.Name)
+			}
+		}
+	}
+	// The complete reply trims the whitespace left where the block was removed
+	if got, want := strings.TrimSpace(streamed.String()), choice.Message.Content; got != want {
+		t.Errorf("streamed %q, want %q", got, want)
+	}
+	if len(names) != 1 || names[0] != "get_weather" {
+		t.Errorf("streamed tool calls %v, want [get_weather]", names)
+	}
+}
+
+func TestRestrictedKey(t *testing.T) {
+	proSession := createSession(t, "gemini-3-pro")
+	chat := func(model string) map[string]interface{} {
+		return map[string]interface{}{"model": model, "messages": userMessage("Hi")}
+	}
+	contents := map[string]interface{}{
+		"contents": []map[string]interface{}{{"role": "user", "parts": []map[string]string{{"text": "Hi"}}}},
+	}
+
+	tests := []struct {
+		name string
+		path string
+		body interface{}
+	}{
+		{"chat completions", "/v1/chat/completions", chat("gemini-3-pro")},
+		{"chat completions without a model", "/v1/chat/completions", chat("")},
+		{"image generations", "/v1/images/generations", map[string]string{"model": "gpt-image-1", "prompt": "A cat"}},
+		{"messages", "/v1/messages", map[string]interface{}{"model": "claude-sonnet-4-6", "max_tokens": 16, "messages": userMessage("Hi")}},
+		{"count tokens", "/v1/messages/count_tokens", map[string]interface{}{"model": "claude-sonnet-4-6", "messages": userMessage("Hi")}},
+		{"generate content", "/gemini/v1beta/models/gemini-3-pro:generateContent", contents},
+		{"stream generate content", "/gemini/v1beta/models/gemini-3-pro:streamGenerateContent?alt=sse", contents},
+		{"responses", "/v1/responses", map[string]string{"model": "gemini-3-pro", "input": "Hi"}},
+		{"create session", "/sessions", map[string]string{"model": "gemini-3-pro"}},
+		{"create session without a model", "/sessions", nil},
+		{"session message", "/sessions/" + proSession.ID + "/messages", map[string]string{"message": "Hi"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			sent := len(fake.Requests())
+			decode(t, request(t, "POST", tt.path, flashKey, tt.body), fiber.StatusForbidden, nil)
+			if len(fake.Requests()) != sent {
+				t.Error("rejected request reached Gemini")
+			}
+		})
+	}
+
+	// The key's model is allowed under any of its names, and listings are open
+	fake.Enqueue(fakegemini.Reply{Text: "Hello"})
+	decode(t, request(t, "POST", "/v1/chat/completions", flashKey, chat("gemini-flash")), fiber.StatusOK, nil)
+	decode(t, request(t, "GET", "/v1/models", flashKey, nil), fiber.StatusOK, nil)
+}
@@ -0,0 +1,7 @@
+package conversation
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Provide(NewConversationService),
+)
@@ -0,0 +1,269 @@
+package conversation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"strings"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/commons/models"
+	"gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/providers"
+	"gemini-web-to-api/internal/modules/sessions"
+
+	"go.uber.org/zap"
+)
+
+// ConversationService maps message histories to the Gemini conversations they
+// were answered in. Every message array is hashed message by message; when a
+// request extends a history whose last reply came from Gemini, only the new
+// messages are sent to that conversation instead of the whole transcript.
+// Conversations are kept in the session store backend, keyed by history hash.
+type ConversationService struct {
+	client        *providers.AccountPool
+	enabled       bool
+	conversations sessions.SessionStore
+	log           *zap.Logger
+}
+
+func NewConversationService(cfg *configs.Config, client *providers.AccountPool, log *zap.Logger) (*ConversationService, error) {
+	conversations, err := sessions.Open(cfg.Session, "conversations",
+		cfg.Conversation.MaxEntries,
+		time.Duration(cfg.Conversation.TTL)*time.Second,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ConversationService{
+		client:        client,
+		enabled:       cfg.Conversation.Enabled && cfg.Conversation.MaxEntries > 0,
+		conversations: conversations,
+		log:           log,
+	}, nil
+}
+
+// Turn is one request of a conversation
+type Turn struct {
+	// Prompt is the text to send: the whole transcript for a new conversation,
+	// or only the new messages when an existing one is continued
+	Prompt string
+	// Messages are the request messages Prompt covers
+	Messages []models.Message
+	// Continued is set when Prompt continues a cached conversation
+	Continued bool
+
+	service     *ConversationService
+	model       string
+	fullPrompt  string
+	history     []models.Message           // all request messages
+	metadata    *providers.SessionMetadata // conversation being continued
+	hash        []byte                     // chained hash of all request messages
+	reply       *providers.SessionMetadata // conversation state after the reply
+	extract     AttachmentFunc
+	attachments []utils.Attachment // attachments of Messages
+}
+
+// AttachmentFunc collects the images and files of the request messages from
+// index first on
+type AttachmentFunc func(first int) ([]utils.Attachment, error)
+
+// Begin resolves a request against the cache. system and options are passed
+// to BuildPromptFromMessages; they are part of the key, since they are only
+// sent with the first turn of a conversation.
+func (s *ConversationService) Begin(model, system string, messages []models.Message, options ...utils.PromptOption) *Turn {
+	prompt := utils.BuildPromptFromMessages(messages, system, options...)
+	turn := &Turn{
+		Prompt:     prompt,
+		Messages:   messages,
+		service:    s,
+		model:      model,
+		fullPrompt: prompt,
+		history:    messages,
+	}
+	if !s.enabled {
+		return turn
+	}
+
+	lines := utils.RenderMessages(messages)
+	hashes := make([][]byte, len(lines)+1)
+	hashes[0] = chainHash(nil, model+"\x00"+utils.BuildPromptFromMessages(nil, system, options...))
+	for i, line := range lines {
+		hashes[i+1] = chainHash(hashes[i], line)
+	}
+	turn.hash = hashes[len(lines)]
+
+	// The longest known prefix wins; the last message itself must be new
+	for i := len(lines) - 1; i > 0; i-- {
+		conversation, err := s.conversations.Get(hex.EncodeToString(hashes[i]))
+		if err != nil {
+			if !errors.Is(err, sessions.ErrSessionNotFound) {
+				s.log.Warn("Failed to read cached conversation", zap.Error(err))
+			}
+			continue
+		}
+		metadata := conversation.Metadata
+		turn.Prompt = strings.Join(lines[i:], "\n")
+		turn.Messages = messages[i:]
+		turn.Continued = true
+		turn.metadata = &metadata
+		s.log.Debug("Continuing Gemini conversation",
+			zap.String("conversation_id", metadata.ConversationID),
+			zap.Int("known_messages", i),
+			zap.Int("new_messages", len(lines)-i),
+		)
+		break
+	}
+	return turn
+}
+
+// CollectAttachments collects the attachments of the messages Prompt covers
+// with extract. They are sent with the turn, and collected again from the whole
+// history when a continuation falls back to a new conversation.
+func (t *Turn) CollectAttachments(extract AttachmentFunc) error {
+	attachments, err := extract(len(t.history) - len(t.Messages))
+	if err != nil {
+		return err
+	}
+	t.extract = extract
+	t.attachments = attachments
+	return nil
+}
+
+// options appends the turn's attachments to the caller's options
+func (t *Turn) options(options []providers.GenerateOption) []providers.GenerateOption {
+	if len(t.attachments) == 0 {
+		return options
+	}
+	return append(append([]providers.GenerateOption{}, options...), providers.WithAttachments(t.attachments))
+}
+
+// Send sends the turn. A continued conversation that fails is retried once as
+// a new conversation with the whole transcript, so a stale cache entry never
+// fails a request that could be answered.
+func (t *Turn) Send(ctx context.Context, options ...providers.GenerateOption) (*providers.Response, error) {
+	if t.Continued {
+		session := t.session()
+		response, err := session.SendMessage(ctx, t.Prompt, t.options(options)...)
+		if err == nil {
+			t.reply = session.GetMetadata()
+			return response, nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if err := t.restart(err); err != nil {
+			return nil, err
+		}
+	}
+
+	response, err := t.service.client.GenerateContent(ctx, t.Prompt, t.options(options)...)
+	if err != nil {
+		return nil, err
+	}
+	t.reply = response.SessionMetadata(t.model)
+	return response, nil
+}
+
+// SendStream streams the turn, falling back like Send while the stream is
+// being established
+func (t *Turn) SendStream(ctx context.Context, options ...providers.GenerateOption) (<-chan providers.StreamChunk, error) {
+	if t.Continued {
+		session := t.session()
+		chunks, err := session.SendMessageStream(ctx, t.Prompt, t.options(options)...)
+		if err == nil {
+			return t.capture(ctx, chunks, func(*providers.Response) *providers.SessionMetadata {
+				return session.GetMetadata()
+			}), nil
+		}
+		if ctx.Err() != nil {
+			return nil, err
+		}
+		if err := t.restart(err); err != nil {
+			return nil, err
+		}
+	}
+
+	chunks, err := t.service.client.GenerateContentStream(ctx, t.Prompt, t.options(options)...)
+	if err != nil {
+		return nil, err
+	}
+	return t.capture(ctx, chunks, func(response *providers.Response) *providers.SessionMetadata {
+		return response.SessionMetadata(t.model)
+	}), nil
+}
+
+// Commit records the reply in the form the client will send it back, so the
+// next request that extends the history continues the conversation. Nothing
+// is recorded if the turn did not complete.
+func (t *Turn) Commit(reply models.Message) {
+	if !t.service.enabled || t.reply == nil || t.reply.ConversationID == "" {
+		return
+	}
+	lines := utils.RenderMessages(append(append([]models.Message{}, t.Messages...), reply))
+	key := hex.EncodeToString(chainHash(t.hash, lines[len(lines)-1]))
+	if err := t.service.conversations.Put(&sessions.Session{ID: key, Metadata: *t.reply}); err != nil {
+		t.service.log.Warn("Failed to cache conversation", zap.Error(err))
+	}
+}
+
+// session restores the continued conversation on the account that owns it
+func (t *Turn) session() providers.ChatSession {
+	return t.service.client.StartChat(
+		providers.WithChatModel(t.model),
+		providers.WithChatMetadata(t.metadata),
+	)
+}
+
+// restart turns a failed continuation into a new conversation, with the
+// messages and attachments of the whole history
+func (t *Turn) restart(err error) error {
+	t.service.log.Warn("Failed to continue Gemini conversation, resending the whole history",
+		zap.String("conversation_id", t.metadata.ConversationID),
+		zap.Error(err),
+	)
+	t.Prompt = t.fullPrompt
+	t.Messages = t.history
+	t.Continued = false
+	t.metadata = nil
+
+	if t.extract == nil {
+		return nil
+	}
+	attachments, err := t.extract(0)
+	if err != nil {
+		return err
+	}
+	t.attachments = attachments
+	return nil
+}
+
+// capture forwards a stream and records the conversation state of its final response
+func (t *Turn) capture(ctx context.Context, chunks <-chan providers.StreamChunk, metadata func(*providers.Response) *providers.SessionMetadata) <-chan providers.StreamChunk {
+	out := make(chan providers.StreamChunk)
+	go func() {
+		defer close(out)
+		for chunk := range chunks {
+			if chunk.Response != nil {
+				t.reply = metadata(chunk.Response)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// chainHash extends the hash of the previous messages with one more message
+func chainHash(prev []byte, line string) []byte {
+	h := sha256.New()
+	h.Write(prev)
+	h.Write([]byte{0})
+	h.Write([]byte(line))
+	return h.Sum(nil)
+}
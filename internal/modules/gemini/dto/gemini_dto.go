@@ -1,5 +1,7 @@
 package dto
 
+import "encoding/json"
+
 // GeminiModelsResponse represents the response from /v1beta/models
 type GeminiModelsResponse struct {
 	Models []GeminiModel `json:"models"`
@@ -11,14 +13,42 @@ type GeminiModel struct {
 	DisplayName                string   `json:"displayName"`
 	Description                string   `json:"description,omitempty"`
 	Version                    string   `json:"version,omitempty"`
+	InputTokenLimit            int      `json:"inputTokenLimit,omitempty"`
 	SupportedGenerationMethods []string `json:"supportedGenerationMethods"`
 }
 
 // GeminiGenerateRequest represents a Gemini generate request
 type GeminiGenerateRequest struct {
-	Contents         []Content           `json:"contents"`
-	GenerationConfig *GenerationConfig   `json:"generationConfig,omitempty"`
-	Safety           []map[string]string `json:"safety_settings,omitempty"`
+	Contents          []Content           `json:"contents"`
+	SystemInstruction *Content            `json:"systemInstruction,omitempty"`
+	GenerationConfig  *GenerationConfig   `json:"generationConfig,omitempty"`
+	Safety            []map[string]string `json:"safety_settings,omitempty"`
+	Tools             []Tool              `json:"tools,omitempty"`
+	ToolConfig        *ToolConfig         `json:"toolConfig,omitempty"`
+}
+
+// Tool represents a set of functions the model may call
+type Tool struct {
+	FunctionDeclarations []FunctionDeclaration `json:"functionDeclarations,omitempty"`
+}
+
+// FunctionDeclaration describes a callable function
+type FunctionDeclaration struct {
+	Name                 string      `json:"name"`
+	Description          string      `json:"description,omitempty"`
+	Parameters           interface{} `json:"parameters,omitempty"`           // OpenAPI schema subset
+	ParametersJSONSchema interface{} `json:"parametersJsonSchema,omitempty"` // JSON schema, alternative to parameters
+}
+
+// ToolConfig configures how the model uses tools
+type ToolConfig struct {
+	FunctionCallingConfig *FunctionCallingConfig `json:"functionCallingConfig,omitempty"`
+}
+
+// FunctionCallingConfig selects the function calling mode
+type FunctionCallingConfig struct {
+	Mode                 string   `json:"mode,omitempty"` // AUTO, ANY or NONE
+	AllowedFunctionNames []string `json:"allowedFunctionNames,omitempty"`
 }
 
 // Content represents a content block in Gemini API
@@ -29,8 +59,25 @@ type Content struct {
 
 // Part represents a part of content
 type Part struct {
-	Text       string      `json:"text,omitempty"`
-	InlineData *InlineData `json:"inlineData,omitempty"`
+	Text             string            `json:"text,omitempty"`
+	InlineData       *InlineData       `json:"inlineData,omitempty"`
+	FileData         *FileData         `json:"fileData,omitempty"`
+	FunctionCall     *FunctionCall     `json:"functionCall,omitempty"`
+	FunctionResponse *FunctionResponse `json:"functionResponse,omitempty"`
+}
+
+// FunctionCall represents a function call predicted by the model
+type FunctionCall struct {
+	ID   string          `json:"id,omitempty"`
+	Name string          `json:"name"`
+	Args json.RawMessage `json:"args,omitempty" swaggertype:"object"`
+}
+
+// FunctionResponse represents the result of a function call sent back by the client
+type FunctionResponse struct {
+	ID       string          `json:"id,omitempty"`
+	Name     string          `json:"name"`
+	Response json.RawMessage `json:"response" swaggertype:"object"`
 }
 
 // InlineData represents inline data (e.g., images)
@@ -39,26 +86,50 @@ type InlineData struct {
 	Data     string `json:"data"`
 }
 
+// FileData references a file by URI (e.g., generated or web images)
+type FileData struct {
+	MimeType string `json:"mimeType,omitempty"`
+	FileURI  string `json:"fileUri"`
+}
+
 // GenerationConfig represents generation configuration
 type GenerationConfig struct {
 	Temperature     float32 `json:"temperature,omitempty"`
 	TopP            float32 `json:"topP,omitempty"`
 	TopK            int32   `json:"topK,omitempty"`
 	MaxOutputTokens int32   `json:"maxOutputTokens,omitempty"`
+	CandidateCount  int32   `json:"candidateCount,omitempty"`
 }
 
 // GeminiGenerateResponse represents a Gemini generate response
 type GeminiGenerateResponse struct {
-	Candidates   []Candidate    `json:"candidates"`
+	Candidates    []Candidate    `json:"candidates"`
 	UsageMetadata *UsageMetadata `json:"usageMetadata,omitempty"`
 }
 
 // Candidate represents a candidate response
 type Candidate struct {
-	Index        int       `json:"index"`
-	Content      Content   `json:"content"`
-	FinishReason string    `json:"finishReason,omitempty"`
-	FinishMessage string   `json:"finishMessage,omitempty"`
+	Index             int                `json:"index"`
+	Content           Content            `json:"content"`
+	FinishReason      string             `json:"finishReason,omitempty"`
+	FinishMessage     string             `json:"finishMessage,omitempty"`
+	GroundingMetadata *GroundingMetadata `json:"groundingMetadata,omitempty"`
+}
+
+// GroundingMetadata lists the web sources a candidate is grounded on
+type GroundingMetadata struct {
+	GroundingChunks []GroundingChunk `json:"groundingChunks,omitempty"`
+}
+
+// GroundingChunk is a single grounding source
+type GroundingChunk struct {
+	Web *WebChunk `json:"web,omitempty"`
+}
+
+// WebChunk is a web page used as a grounding source
+type WebChunk struct {
+	URI   string `json:"uri"`
+	Title string `json:"title,omitempty"`
 }
 
 // UsageMetadata represents usage metadata
@@ -67,3 +138,15 @@ type UsageMetadata struct {
 	CandidatesTokenCount int32 `json:"candidatesTokenCount"`
 	TotalTokenCount      int32 `json:"totalTokenCount"`
 }
+
+// GeminiErrorResponse represents an error body in the official Gemini API format
+type GeminiErrorResponse struct {
+	Error GeminiError `json:"error"`
+}
+
+// GeminiError represents the error details (google.rpc.Status)
+type GeminiError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+	Status  string `json:"status"`
+}
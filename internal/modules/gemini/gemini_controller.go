@@ -3,12 +3,15 @@ package gemini
 import (
 	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"sync"
 	"time"
 
 	common "gemini-web-to-api/internal/commons/utils"
 	"gemini-web-to-api/internal/modules/gemini/dto"
+	"gemini-web-to-api/internal/modules/providers"
 
 	"github.com/gofiber/fiber/v3"
 	"go.uber.org/zap"
@@ -63,7 +66,8 @@ func (h *GeminiController) HandleV1BetaModels(c fiber.Ctx) error {
 	for _, m := range availableModels {
 		geminiModels = append(geminiModels, dto.GeminiModel{
 			Name:                       "models/" + m.ID,
-			DisplayName:                m.ID,
+			DisplayName:                m.DisplayName,
+			InputTokenLimit:            m.ContextWindow,
 			SupportedGenerationMethods: []string{"generateContent", "streamGenerateContent"},
 		})
 	}
@@ -91,16 +95,12 @@ func (h *GeminiController) HandleV1BetaGenerateContent(c fiber.Ctx) error {
 	}
 
 	// Add timeout to context
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
 	defer cancel()
 
 	response, err := h.service.GenerateContent(ctx, model, req)
 	if err != nil {
-		if err.Error() == "empty content" {
-			return c.Status(fiber.StatusBadRequest).JSON(common.ErrorToResponse(err, "invalid_request_error"))
-		}
-		h.log.Error("GenerateContent failed", zap.Error(err), zap.String("model", model))
-		return c.Status(fiber.StatusInternalServerError).JSON(common.ErrorToResponse(err, "api_error"))
+		return h.respondError(c, err, model)
 	}
 
 	return c.JSON(response)
@@ -108,13 +108,15 @@ func (h *GeminiController) HandleV1BetaGenerateContent(c fiber.Ctx) error {
 
 // HandleV1BetaStreamGenerateContent handles the official Gemini streaming endpoint
 // @Summary Stream Generate Content (Gemini)
-// @Description Streams generated content using the Gemini model
+// @Description Streams generated content using the Gemini model. By default the chunks are elements of a single JSON array; pass alt=sse to receive Server-Sent Events instead.
 // @Tags Gemini
 // @Accept json
 // @Produce json
+// @Produce text/event-stream
 // @Param model path string true "Model ID"
+// @Param alt query string false "Response format: sse for Server-Sent Events, omit for a streamed JSON array"
 // @Param request body dto.GeminiGenerateRequest true "Generate Request"
-// @Success 200 {string} string "Chunked response"
+// @Success 200 {array} dto.GeminiGenerateResponse "Streamed chunks"
 // @Router /gemini/v1beta/models/{model}:streamGenerateContent [post]
 func (h *GeminiController) HandleV1BetaStreamGenerateContent(c fiber.Ctx) error {
 	h.mu.RLock()
@@ -126,69 +128,177 @@ func (h *GeminiController) HandleV1BetaStreamGenerateContent(c fiber.Ctx) error
 		return c.Status(fiber.StatusBadRequest).JSON(common.ErrorToResponse(fmt.Errorf("invalid request body: %w", err), "invalid_request_error"))
 	}
 
-	c.Set("Content-Type", "application/json")
+	// The stream outlives this handler, so its context is cancelled by the body writer
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+
+	stream, err := h.service.GenerateContentStream(ctx, model, req)
+	if err != nil {
+		cancel()
+		return h.respondError(c, err, model)
+	}
+
+	sse := c.Query("alt") == "sse"
+	if sse {
+		c.Set("Content-Type", "text/event-stream")
+		c.Set("Cache-Control", "no-cache")
+	} else {
+		c.Set("Content-Type", "application/json")
+	}
 	c.Set("Transfer-Encoding", "chunked")
 
 	c.RequestCtx().SetBodyStreamWriter(func(w *bufio.Writer) {
-		// Add timeout to context inside stream writer
-		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
 		defer cancel()
 
-		resp, err := h.service.GenerateContent(ctx, model, req)
-		if err != nil {
-			h.log.Error("GenerateContent streaming failed", zap.Error(err), zap.String("model", model))
-			errResponse := common.ErrorToResponse(err, "api_error")
-			_ = common.SendStreamChunk(w, h.log, errResponse)
-			return
-		}
+		out := &streamWriter{w: w, log: h.log, sse: sse}
+		defer out.Close()
 
-		// Handle empty response gracefully
-		var text string
-		if len(resp.Candidates) > 0 && len(resp.Candidates[0].Content.Parts) > 0 {
-			text = resp.Candidates[0].Content.Parts[0].Text
+		// With tools, function call blocks are held back and sent as functionCall parts
+		var parser *common.ToolCallParser
+		if stream.Tools {
+			parser = &common.ToolCallParser{}
 		}
-
-		chunks := common.SplitResponseIntoChunks(text, 30)
-		for i, content := range chunks {
-			chunk := dto.GeminiGenerateResponse{
+		// The reply is rebuilt from the chunks the way the client assembles it
+		var content strings.Builder
+		var functionCalls []common.ToolCall
+		writeParts := func(text string, calls []common.ToolCall) error {
+			parts := NewFunctionCallParts(text, calls)
+			if len(parts) == 0 {
+				return nil
+			}
+			content.WriteString(text)
+			functionCalls = append(functionCalls, calls...)
+			return out.Write(dto.GeminiGenerateResponse{
 				Candidates: []dto.Candidate{
 					{
 						Index: 0,
 						Content: dto.Content{
 							Role:  "model",
-							Parts: []dto.Part{{Text: content}},
+							Parts: parts,
 						},
 					},
 				},
+			})
+		}
+
+		for chunk := range providers.CompleteDeltas(ctx, stream.Chunks) {
+			if chunk.Err != nil {
+				h.log.Error("GenerateContent streaming failed", zap.Error(chunk.Err), zap.String("model", model))
+				info := providers.SurfaceErrorInfo(common.SurfaceGemini, chunk.Err)
+				_ = out.Write(common.SurfaceErrorBody(common.SurfaceGemini, info.Status, info.Type, info.Code, chunk.Err.Error()))
+				return
 			}
 
-			if err := common.SendStreamChunk(w, h.log, chunk); err != nil {
-				h.log.Error("Failed to send stream chunk", zap.Error(err), zap.Int("chunk_index", i))
+			if chunk.Response != nil {
+				if parser != nil {
+					if err := writeParts(parser.Flush()); err != nil {
+						return
+					}
+				}
+
+				// Like the official API, the last chunk carries the finish reason and usage,
+				// along with images and sources that are only known once the reply is complete
+				finalChunk := dto.GeminiGenerateResponse{
+					Candidates: []dto.Candidate{
+						{
+							Index: 0,
+							Content: dto.Content{
+								Role:  "model",
+								Parts: append([]dto.Part{}, NewImageParts(chunk.Response.Images)...),
+							},
+							FinishReason:      "STOP",
+							GroundingMetadata: NewGroundingMetadata(chunk.Response.Sources),
+						},
+					},
+					UsageMetadata: NewUsageMetadata(stream.Prompt, chunk.Response.Text),
+				}
+				if out.Write(finalChunk) == nil {
+					stream.Commit(NewFunctionCallParts(content.String(), functionCalls))
+				}
 				return
 			}
 
-			// Check for context cancellation and sleep
-			if !common.SleepWithCancel(ctx, 30*time.Millisecond) {
+			text, calls := chunk.Delta, []common.ToolCall(nil)
+			if parser != nil {
+				text, calls = parser.Feed(chunk.Delta)
+			}
+			if err := writeParts(text, calls); err != nil {
 				h.log.Info("Stream cancelled by client")
 				return
 			}
 		}
-
-		// Send final chunk
-		finalChunk := dto.GeminiGenerateResponse{
-			Candidates: []dto.Candidate{
-				{
-					Index:        0,
-					FinishReason: "STOP",
-				},
-			},
-		}
-		_ = common.SendStreamChunk(w, h.log, finalChunk)
+		h.log.Warn("Stream ended before completion", zap.Error(ctx.Err()), zap.String("model", model))
 	})
 
 	return nil
 }
 
+// respondError writes a service error with a matching status. Unknown models use
+// the official API's NOT_FOUND error body so SDKs surface a proper exception.
+func (h *GeminiController) respondError(c fiber.Ctx, err error, model string) error {
+	if errors.Is(err, providers.ErrModelNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(dto.GeminiErrorResponse{
+			Error: dto.GeminiError{
+				Code:    fiber.StatusNotFound,
+				Message: fmt.Sprintf("models/%s is not found for API version v1beta, or is not supported for generateContent.", model),
+				Status:  "NOT_FOUND",
+			},
+		})
+	}
+
+	info := providers.SurfaceErrorInfo(common.SurfaceGemini, err)
+	if info.Status == fiber.StatusInternalServerError {
+		h.log.Error("GenerateContent failed", zap.Error(err), zap.String("model", model))
+	} else {
+		h.log.Warn("GenerateContent failed upstream", zap.Error(err), zap.String("model", model))
+	}
+	return c.Status(info.Status).JSON(common.SurfaceErrorBody(common.SurfaceGemini, info.Status, info.Type, info.Code, err.Error()))
+}
+
+// streamWriter encodes streamGenerateContent chunks in the format the official
+// SDKs expect: SSE "data:" frames for alt=sse, otherwise the elements of a
+// single JSON array that is flushed incrementally.
+type streamWriter struct {
+	w     *bufio.Writer
+	log   *zap.Logger
+	sse   bool
+	count int
+}
+
+// Write sends one chunk and flushes it to the client
+func (s *streamWriter) Write(chunk interface{}) error {
+	if s.sse {
+		return common.SendSSEData(s.w, s.log, chunk)
+	}
+
+	sep := ",\r\n"
+	if s.count == 0 {
+		sep = "["
+	}
+	s.count++
+	if _, err := s.w.WriteString(sep); err != nil {
+		return err
+	}
+	if _, err := s.w.Write(common.MarshalJSONSafely(s.log, chunk)); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
+// Close terminates the JSON array; it is a no-op in SSE mode
+func (s *streamWriter) Close() error {
+	if s.sse {
+		return nil
+	}
+	closing := "]"
+	if s.count == 0 {
+		closing = "[]"
+	}
+	if _, err := s.w.WriteString(closing); err != nil {
+		return err
+	}
+	return s.w.Flush()
+}
+
 // Register registers the Gemini routes on the provided router
 func (g *GeminiController) Register(group fiber.Router) {
 	group.Get("/models", g.HandleV1BetaModels)
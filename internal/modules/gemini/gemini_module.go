@@ -1,14 +1,11 @@
 package gemini
 
 import (
-	"gemini-web-to-api/internal/modules/providers"
-
 	"github.com/gofiber/fiber/v3"
 	"go.uber.org/fx"
 )
 
 var Module = fx.Options(
-	fx.Provide(providers.NewClient),
 	fx.Provide(NewGeminiService),
 	fx.Provide(NewGeminiController),
 	fx.Invoke(RegisterRoutes),
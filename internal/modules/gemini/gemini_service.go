@@ -2,24 +2,53 @@ package gemini
 
 import (
 	"context"
-	"fmt"
+	"mime"
+	"net/url"
+	"path"
 	"strings"
 
+	"gemini-web-to-api/internal/commons/models"
+	"gemini-web-to-api/internal/commons/tracing"
+	"gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/conversation"
 	"gemini-web-to-api/internal/modules/gemini/dto"
 	"gemini-web-to-api/internal/modules/providers"
 
 	"go.uber.org/zap"
 )
 
+// ContentStream is a streaming generation that has been accepted upstream
+type ContentStream struct {
+	Prompt string
+	Chunks <-chan providers.StreamChunk
+
+	// Tools is set when the reply must be parsed for emulated function calls
+	Tools bool
+
+	turn *conversation.Turn
+}
+
+// Commit records the streamed reply parts as the client received them, so a
+// follow-up request continues the same Gemini conversation
+func (s *ContentStream) Commit(parts []dto.Part) {
+	s.turn.Commit(models.Message{Role: "model", Content: renderParts(parts)})
+}
+
+// ErrEmptyContent is returned for a request whose contents have no text and no
+// inline data. It matches utils.ErrInvalidRequest.
+var ErrEmptyContent = utils.InvalidRequestf("empty content")
+
 type GeminiService struct {
-	client *providers.Client
-	log    *zap.Logger
+	client        *providers.AccountPool
+	conversations *conversation.ConversationService
+	log           *zap.Logger
 }
 
-func NewGeminiService(client *providers.Client, log *zap.Logger) *GeminiService {
+func NewGeminiService(client *providers.AccountPool, conversations *conversation.ConversationService, log *zap.Logger) *GeminiService {
 	return &GeminiService{
-		client: client,
-		log:    log,
+		client:        client,
+		conversations: conversations,
+		log:           log,
 	}
 }
 
@@ -28,51 +57,299 @@ func (s *GeminiService) ListModels() []providers.ModelInfo {
 }
 
 func (s *GeminiService) GenerateContent(ctx context.Context, modelID string, req dto.GeminiGenerateRequest) (*dto.GeminiGenerateResponse, error) {
+	ctx, span := tracing.Start(ctx, "GeminiService.GenerateContent",
+		tracing.AttrSurface.String(utils.SurfaceGemini),
+		tracing.AttrModel.String(modelID),
+	)
+	defer span.End()
+
 	// Logic: Extract prompt
-	var promptBuilder strings.Builder
-	for _, content := range req.Contents {
-		for _, part := range content.Parts {
-			if part.Text != "" {
-				promptBuilder.WriteString(part.Text)
-				promptBuilder.WriteString("\n")
+	prompt, err := s.buildPrompt(modelID, req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logic: Call Provider
+	response, err := prompt.turn.Send(ctx, prompt.options(modelID)...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logic: Construct Response
+	drafts := response.Candidates
+	if len(drafts) == 0 {
+		drafts = []providers.Candidate{{Content: response.Text, Images: response.Images, Sources: response.Sources}}
+	}
+	count := 1
+	if req.GenerationConfig != nil && req.GenerationConfig.CandidateCount > 1 {
+		count = int(req.GenerationConfig.CandidateCount)
+	}
+
+	candidates := []dto.Candidate{}
+	for i, draft := range drafts[:min(count, len(drafts))] {
+		parts := []dto.Part{{Text: draft.Content}}
+		if prompt.tools {
+			text, calls := utils.ExtractToolCalls(draft.Content)
+			if len(calls) > 0 {
+				parts = NewFunctionCallParts(text, calls)
 			}
 		}
+		candidates = append(candidates, dto.Candidate{
+			Index: i,
+			Content: dto.Content{
+				Role:  "model",
+				Parts: append(parts, NewImageParts(draft.Images)...),
+			},
+			FinishReason:      "STOP",
+			GroundingMetadata: NewGroundingMetadata(draft.Sources),
+		})
 	}
+	prompt.turn.Commit(models.Message{Role: "model", Content: renderParts(candidates[0].Content.Parts)})
 
-	prompt := strings.TrimSpace(promptBuilder.String())
-	if prompt == "" {
-		return nil, fmt.Errorf("empty content")
+	return &dto.GeminiGenerateResponse{
+		Candidates:    candidates,
+		UsageMetadata: NewUsageMetadata(prompt.text, response.Text),
+	}, nil
+}
+
+// GenerateContentStream starts a streaming generation. Errors returned here happen
+// before any byte is sent; later failures arrive on the chunk channel.
+func (s *GeminiService) GenerateContentStream(ctx context.Context, modelID string, req dto.GeminiGenerateRequest) (*ContentStream, error) {
+	ctx, span := tracing.Start(ctx, "GeminiService.GenerateContentStream",
+		tracing.AttrSurface.String(utils.SurfaceGemini),
+		tracing.AttrModel.String(modelID),
+	)
+	defer span.End()
+
+	prompt, err := s.buildPrompt(modelID, req)
+	if err != nil {
+		return nil, err
 	}
 
-	// Logic: Call Provider
-	opts := []providers.GenerateOption{providers.WithModel(modelID)}
-	response, err := s.client.GenerateContent(ctx, prompt, opts...)
+	chunks, err := prompt.turn.SendStream(ctx, prompt.options(modelID)...)
 	if err != nil {
 		return nil, err
 	}
+	return &ContentStream{Prompt: prompt.text, Chunks: chunks, Tools: prompt.tools, turn: prompt.turn}, nil
+}
 
-	// Logic: Construct Response
-	return &dto.GeminiGenerateResponse{
-		Candidates: []dto.Candidate{
-			{
-				Index: 0,
-				Content: dto.Content{
-					Role:  "model",
-					Parts: []dto.Part{{Text: response.Text}},
-				},
-				FinishReason: "STOP",
-			},
-		},
-		UsageMetadata: &dto.UsageMetadata{
-			TotalTokenCount: 0,
-		},
+// NewFunctionCallParts builds the parts of a reply: its text, if any, followed
+// by one functionCall part per call
+func NewFunctionCallParts(text string, calls []utils.ToolCall) []dto.Part {
+	parts := []dto.Part{}
+	if text != "" {
+		parts = append(parts, dto.Part{Text: text})
+	}
+	for _, call := range calls {
+		parts = append(parts, dto.Part{FunctionCall: &dto.FunctionCall{Name: call.Name, Args: call.Arguments}})
+	}
+	return parts
+}
+
+// NewImageParts converts images to fileData parts. The MIME type is guessed
+// from the URL; generated images are PNG and web images usually JPEG.
+func NewImageParts(images []providers.Image) []dto.Part {
+	var parts []dto.Part
+	for _, img := range images {
+		mimeType := "image/jpeg"
+		if img.Generated {
+			mimeType = "image/png"
+		}
+		if u, err := url.Parse(img.URL); err == nil {
+			if t := mime.TypeByExtension(path.Ext(u.Path)); strings.HasPrefix(t, "image/") {
+				mimeType = t
+			}
+		}
+		parts = append(parts, dto.Part{FileData: &dto.FileData{MimeType: mimeType, FileURI: img.URL}})
+	}
+	return parts
+}
+
+// NewGroundingMetadata lists cited sources as web grounding chunks, or returns
+// nil when there are none
+func NewGroundingMetadata(sources []providers.Source) *dto.GroundingMetadata {
+	if len(sources) == 0 {
+		return nil
+	}
+	metadata := &dto.GroundingMetadata{}
+	for _, source := range sources {
+		metadata.GroundingChunks = append(metadata.GroundingChunks, dto.GroundingChunk{
+			Web: &dto.WebChunk{URI: source.URL, Title: source.Title},
+		})
+	}
+	return metadata
+}
+
+// NewUsageMetadata estimates token usage for a prompt and its generated text
+func NewUsageMetadata(prompt, text string) *dto.UsageMetadata {
+	promptTokens := int32(utils.EstimateTokens(prompt))
+	candidatesTokens := int32(utils.EstimateTokens(text))
+	return &dto.UsageMetadata{
+		PromptTokenCount:     promptTokens,
+		CandidatesTokenCount: candidatesTokens,
+		TotalTokenCount:      promptTokens + candidatesTokens,
+	}
+}
+
+// contentPrompt is a request flattened into a provider prompt
+type contentPrompt struct {
+	turn  *conversation.Turn
+	text  string
+	tools bool // reply must be parsed for function calls
+}
+
+// options returns the provider options for the prompt
+func (p *contentPrompt) options(modelID string) []providers.GenerateOption {
+	return []providers.GenerateOption{providers.WithModel(modelID)}
+}
+
+// buildPrompt flattens the contents into a single prompt, rendering function
+// calls and responses the same way as the other surfaces' tool calls, and
+// collects inlineData parts as attachments. Contents already sent to the
+// conversation the history belongs to are left out.
+func (s *GeminiService) buildPrompt(modelID string, req dto.GeminiGenerateRequest) (*contentPrompt, error) {
+	tools, choice, err := parseTools(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var messages []models.Message
+	var origins []int // index of the content each message was rendered from
+	for i, content := range req.Contents {
+		if text := renderParts(content.Parts); text != "" || hasInlineData(content.Parts) {
+			messages = append(messages, models.Message{Role: content.Role, Content: text})
+			origins = append(origins, i)
+		}
+	}
+	if len(messages) == 0 {
+		return nil, ErrEmptyContent
+	}
+
+	systemText := ""
+	if req.SystemInstruction != nil {
+		systemText = renderParts(req.SystemInstruction.Parts)
+	}
+	turn := s.conversations.Begin(modelID, systemText, messages, utils.WithTools(tools, choice))
+
+	// Attachments of contents after the last message the conversation knows
+	err = turn.CollectAttachments(func(known int) ([]utils.Attachment, error) {
+		first := 0
+		if known > 0 {
+			first = origins[known-1] + 1
+		}
+		var attachments []utils.Attachment
+		for _, content := range req.Contents[first:] {
+			for _, part := range content.Parts {
+				if part.InlineData == nil {
+					continue
+				}
+				attachment, err := utils.NewAttachment(part.InlineData.MimeType, part.InlineData.Data, len(attachments)+1)
+				if err != nil {
+					return nil, err
+				}
+				attachments = append(attachments, attachment)
+			}
+		}
+		return attachments, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return &contentPrompt{
+		turn:  turn,
+		text:  turn.Prompt,
+		tools: utils.ToolsActive(tools, choice),
 	}, nil
 }
 
+// renderParts renders the text, functionCall and functionResponse parts of a content
+func renderParts(parts []dto.Part) string {
+	var rendered []string
+	for _, part := range parts {
+		switch {
+		case part.FunctionCall != nil:
+			rendered = append(rendered, utils.FormatToolCall(part.FunctionCall.Name, part.FunctionCall.Args))
+		case part.FunctionResponse != nil:
+			rendered = append(rendered, utils.FormatToolResult(
+				part.FunctionResponse.Name, part.FunctionResponse.ID, string(part.FunctionResponse.Response), false,
+			))
+		case strings.TrimSpace(part.Text) != "":
+			rendered = append(rendered, part.Text)
+		}
+	}
+	return strings.Join(rendered, "\n")
+}
+
+// hasInlineData reports whether a content carries an inlineData attachment
+func hasInlineData(parts []dto.Part) bool {
+	for _, part := range parts {
+		if part.InlineData != nil {
+			return true
+		}
+	}
+	return false
+}
+
+// parseTools converts the function declarations and function calling config.
+// ANY with allowed function names restricts the declared tools to those names.
+func parseTools(req dto.GeminiGenerateRequest) ([]utils.ToolDefinition, utils.ToolChoice, error) {
+	var tools []utils.ToolDefinition
+	for _, tool := range req.Tools {
+		for _, fn := range tool.FunctionDeclarations {
+			if fn.Name == "" {
+				return nil, utils.ToolChoice{}, utils.InvalidRequestf("function declaration name is required")
+			}
+			parameters := fn.Parameters
+			if parameters == nil {
+				parameters = fn.ParametersJSONSchema
+			}
+			tools = append(tools, utils.ToolDefinition{
+				Name:        fn.Name,
+				Description: fn.Description,
+				Parameters:  parameters,
+			})
+		}
+	}
+
+	choice := utils.ToolChoice{Mode: utils.ToolChoiceAuto}
+	if req.ToolConfig == nil || req.ToolConfig.FunctionCallingConfig == nil {
+		return tools, choice, nil
+	}
+
+	config := req.ToolConfig.FunctionCallingConfig
+	switch strings.ToUpper(config.Mode) {
+	case "", "MODE_UNSPECIFIED", "AUTO", "VALIDATED":
+	case "NONE":
+		choice.Mode = utils.ToolChoiceNone
+	case "ANY":
+		choice.Mode = utils.ToolChoiceRequired
+		if len(config.AllowedFunctionNames) > 0 {
+			allowed := make(map[string]bool, len(config.AllowedFunctionNames))
+			for _, name := range config.AllowedFunctionNames {
+				allowed[name] = true
+			}
+			var filtered []utils.ToolDefinition
+			for _, tool := range tools {
+				if allowed[tool.Name] {
+					filtered = append(filtered, tool)
+				}
+			}
+			tools = filtered
+			if len(config.AllowedFunctionNames) == 1 {
+				choice.Name = config.AllowedFunctionNames[0]
+			}
+		}
+	default:
+		return nil, utils.ToolChoice{}, utils.InvalidRequestf("invalid function calling mode: %s", config.Mode)
+	}
+	return tools, choice, nil
+}
+
 func (s *GeminiService) IsHealthy() bool {
 	return s.client.IsHealthy()
 }
 
-func (s *GeminiService) Client() *providers.Client {
+func (s *GeminiService) Client() *providers.AccountPool {
 	return s.client
 }
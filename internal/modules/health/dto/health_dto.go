@@ -0,0 +1,36 @@
+package dto
+
+import "time"
+
+// Overall states of the service
+const (
+	StatusOK          = "ok"          // every account can serve requests
+	StatusDegraded    = "degraded"    // some accounts cannot serve requests
+	StatusUnavailable = "unavailable" // no account can serve requests
+)
+
+// Health is the body of /health and /ready
+type Health struct {
+	Status   string    `json:"status"`
+	Service  string    `json:"service"`
+	Accounts []Account `json:"accounts,omitempty"` // only in deep checks
+}
+
+// Account reports the health of one Google account
+type Account struct {
+	Name                    string         `json:"name"`
+	Healthy                 bool           `json:"healthy"`   // holds a valid session token
+	Available               bool           `json:"available"` // healthy and circuit closed
+	Circuit                 string         `json:"circuit"`   // "closed" or "open"
+	CooldownUntil           *time.Time     `json:"cooldown_until,omitempty"`
+	InFlight                int64          `json:"in_flight_requests"`
+	CookiesRefreshedAt      *time.Time     `json:"cookies_refreshed_at,omitempty"`
+	SessionTokenRefreshedAt *time.Time     `json:"session_token_refreshed_at,omitempty"`
+	LastError               *UpstreamError `json:"last_error,omitempty"`
+}
+
+// UpstreamError is the last failed call to Gemini
+type UpstreamError struct {
+	Message string    `json:"message"`
+	At      time.Time `json:"at"`
+}
@@ -0,0 +1,53 @@
+package health
+
+import (
+	"gemini-web-to-api/internal/modules/health/dto"
+
+	"github.com/gofiber/fiber/v3"
+)
+
+type HealthController struct {
+	service *HealthService
+}
+
+func NewHealthController(service *HealthService) *HealthController {
+	return &HealthController{service: service}
+}
+
+// HandleHealth reports whether the service is up
+// @Summary      Health check
+// @Description  Returns the health status of the service. Used by Docker/K8s/cloud platforms to monitor the service. With deep=1 it reports every account like /ready and returns 503 when none can serve requests.
+// @Tags         System
+// @Produce      json
+// @Param        deep  query     bool  false  "Check the Gemini accounts"
+// @Success      200   {object}  dto.Health  "Service is healthy"
+// @Failure      503   {object}  dto.Health  "No account can serve requests (deep check)"
+// @Router       /health [get]
+func (h *HealthController) HandleHealth(c fiber.Ctx) error {
+	if fiber.Query[bool](c, "deep") {
+		return h.HandleReady(c)
+	}
+	return c.Status(fiber.StatusOK).JSON(h.service.Check())
+}
+
+// HandleReady reports whether the service can serve requests
+// @Summary      Readiness check
+// @Description  Reports the health of every Gemini account: session state, last cookie and SNlM0e refresh, last upstream error and circuit state. Returns 503 when no account can serve requests, so load balancers stop routing to the instance.
+// @Tags         System
+// @Produce      json
+// @Success      200  {object}  dto.Health  "At least one account can serve requests"
+// @Failure      503  {object}  dto.Health  "No account can serve requests"
+// @Router       /ready [get]
+func (h *HealthController) HandleReady(c fiber.Ctx) error {
+	health := h.service.DeepCheck()
+	status := fiber.StatusOK
+	if health.Status == dto.StatusUnavailable {
+		status = fiber.StatusServiceUnavailable
+	}
+	return c.Status(status).JSON(health)
+}
+
+func (h *HealthController) Register(app fiber.Router) {
+	app.Get("/health", h.HandleHealth)
+	app.Get("/ready", h.HandleReady)
+}
@@ -0,0 +1,16 @@
+package health
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(NewHealthService),
+	fx.Provide(NewHealthController),
+	fx.Invoke(RegisterRoutes),
+)
+
+func RegisterRoutes(app *fiber.App, c *HealthController) {
+	c.Register(app)
+}
@@ -0,0 +1,80 @@
+package health
+
+import (
+	"time"
+
+	"gemini-web-to-api/internal/modules/health/dto"
+	"gemini-web-to-api/internal/modules/providers"
+)
+
+// ServiceName is reported in every health response
+const ServiceName = "gemini-web-to-api"
+
+type HealthService struct {
+	pool *providers.AccountPool
+}
+
+func NewHealthService(pool *providers.AccountPool) *HealthService {
+	return &HealthService{pool: pool}
+}
+
+// Check reports whether the process is up, without looking at the accounts
+func (s *HealthService) Check() dto.Health {
+	return dto.Health{Status: dto.StatusOK, Service: ServiceName}
+}
+
+// DeepCheck reports the health of every account. The status is unavailable
+// when no account can serve requests.
+func (s *HealthService) DeepCheck() dto.Health {
+	accounts := s.pool.Health()
+	health := dto.Health{
+		Service:  ServiceName,
+		Accounts: make([]dto.Account, 0, len(accounts)),
+	}
+
+	available := 0
+	for _, account := range accounts {
+		if account.Available {
+			available++
+		}
+		health.Accounts = append(health.Accounts, toAccount(account))
+	}
+
+	switch available {
+	case len(accounts):
+		health.Status = dto.StatusOK
+	case 0:
+		health.Status = dto.StatusUnavailable
+	default:
+		health.Status = dto.StatusDegraded
+	}
+	return health
+}
+
+func toAccount(account providers.AccountHealth) dto.Account {
+	result := dto.Account{
+		Name:                    account.Name,
+		Healthy:                 account.Healthy,
+		Available:               account.Available,
+		Circuit:                 account.Circuit,
+		CooldownUntil:           timePtr(account.CooldownUntil),
+		InFlight:                account.InFlight,
+		CookiesRefreshedAt:      timePtr(account.CookiesRefreshedAt),
+		SessionTokenRefreshedAt: timePtr(account.SessionTokenRefreshedAt),
+	}
+	if account.LastError != nil {
+		result.LastError = &dto.UpstreamError{
+			Message: account.LastError.Error(),
+			At:      account.LastErrorAt,
+		}
+	}
+	return result
+}
+
+// timePtr returns nil for the zero time, so it is omitted from the response
+func timePtr(t time.Time) *time.Time {
+	if t.IsZero() {
+		return nil
+	}
+	return &t
+}
@@ -4,11 +4,35 @@ import models "gemini-web-to-api/internal/commons/models"
 
 // ChatCompletionRequest represents OpenAI chat completion request
 type ChatCompletionRequest struct {
-	Model       string           `json:"model"`
-	Messages    []models.Message `json:"messages"`
-	Stream      bool             `json:"stream,omitempty"`
-	Temperature float32          `json:"temperature,omitempty"`
-	MaxTokens   int              `json:"max_tokens,omitempty"`
+	Model         string           `json:"model"`
+	Messages      []models.Message `json:"messages"`
+	Stream        bool             `json:"stream,omitempty"`
+	StreamOptions *StreamOptions   `json:"stream_options,omitempty"`
+	Temperature   float32          `json:"temperature,omitempty"`
+	MaxTokens     int              `json:"max_tokens,omitempty"`
+	N             int              `json:"n,omitempty"` // choices to return (non-streaming), up to the drafts Gemini produced
+
+	Tools             []Tool      `json:"tools,omitempty"`
+	ToolChoice        interface{} `json:"tool_choice,omitempty"` // "auto", "none", "required" or {"type":"function","function":{"name":...}}
+	ParallelToolCalls *bool       `json:"parallel_tool_calls,omitempty"`
+}
+
+// Tool represents a tool the model may call
+type Tool struct {
+	Type     string             `json:"type"` // "function"
+	Function FunctionDefinition `json:"function"`
+}
+
+// FunctionDefinition describes a callable function
+type FunctionDefinition struct {
+	Name        string      `json:"name"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"` // JSON schema
+}
+
+// StreamOptions configures streaming behaviour
+type StreamOptions struct {
+	IncludeUsage bool `json:"include_usage,omitempty"`
 }
 
 // ChatCompletionResponse represents OpenAI chat completion response
@@ -35,11 +59,34 @@ type ChatCompletionChunk struct {
 	Created int64         `json:"created"`
 	Model   string        `json:"model"`
 	Choices []ChunkChoice `json:"choices"`
+	Usage   *models.Usage `json:"usage,omitempty"` // only on the trailing chunk when include_usage is set
 }
 
 // ChunkChoice represents a choice in a chunk
 type ChunkChoice struct {
 	Index        int          `json:"index"`
 	Delta        models.Delta `json:"delta"`
-	FinishReason string       `json:"finish_reason,omitempty"`
+	FinishReason *string      `json:"finish_reason"` // null until the final chunk
+}
+
+// ImageGenerationRequest represents an OpenAI image generation request
+type ImageGenerationRequest struct {
+	Model          string `json:"model,omitempty"`
+	Prompt         string `json:"prompt"`
+	N              int    `json:"n,omitempty"`               // 1-10, defaults to 1
+	Size           string `json:"size,omitempty"`            // "WIDTHxHEIGHT" or "auto", used as an aspect ratio hint
+	ResponseFormat string `json:"response_format,omitempty"` // "url" (default) or "b64_json"
+}
+
+// ImagesResponse represents an OpenAI images response
+type ImagesResponse struct {
+	Created int64       `json:"created"`
+	Data    []ImageData `json:"data"`
+}
+
+// ImageData is a single generated image
+type ImageData struct {
+	URL           string `json:"url,omitempty"`
+	B64JSON       string `json:"b64_json,omitempty"`
+	RevisedPrompt string `json:"revised_prompt,omitempty"`
 }
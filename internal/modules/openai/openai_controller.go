@@ -1,8 +1,11 @@
 package openai
 
 import (
+	"bufio"
 	"context"
+	"errors"
 	"fmt"
+	"strings"
 	"time"
 
 	models "gemini-web-to-api/internal/commons/models"
@@ -66,13 +69,15 @@ func (h *OpenAIController) HandleModels(c fiber.Ctx) error {
 
 // HandleChatCompletions accepts requests in OpenAI format
 // @Summary Chat Completions (OpenAI)
-// @Description Generates a completion for the chat message
+// @Description Generates a completion for the chat message. Set "stream": true to receive chat.completion.chunk Server-Sent Events.
 // @Tags OpenAI
 // @Accept json
 // @Produce json
+// @Produce text/event-stream
 // @Param request body dto.ChatCompletionRequest true "Chat Completion Request"
 // @Success 200 {object} dto.ChatCompletionResponse
 // @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
 // @Failure 500 {object} map[string]interface{}
 // @Router /openai/v1/chat/completions [post]
 func (h *OpenAIController) HandleChatCompletions(c fiber.Ctx) error {
@@ -81,45 +86,216 @@ func (h *OpenAIController) HandleChatCompletions(c fiber.Ctx) error {
 		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorToResponse(fmt.Errorf("invalid request body: %w", err), "invalid_request_error"))
 	}
 
+	if req.Stream {
+		return h.handleChatCompletionsStream(c, req)
+	}
+
 	// Add timeout
-	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
 	defer cancel()
 
 	response, err := h.service.CreateChatCompletion(ctx, req)
 	if err != nil {
-		h.log.Error("GenerateContent failed", zap.Error(err), zap.String("model", req.Model))
-		return c.Status(fiber.StatusInternalServerError).JSON(utils.ErrorToResponse(err, "api_error"))
+		return h.respondError(c, err, req.Model)
 	}
 
 	return c.JSON(response)
 }
 
-func (h *OpenAIController) convertToOpenAIFormat(response *providers.Response, model string) dto.ChatCompletionResponse {
-	return dto.ChatCompletionResponse{
-		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().Unix()),
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   model,
-		Choices: []dto.Choice{
-			{
-				Index: 0,
-				Message: models.Message{
-					Role:    "assistant",
-					Content: response.Text,
-				},
-				FinishReason: "stop",
+// handleChatCompletionsStream streams chat.completion.chunk events over SSE:
+// a role delta, content and tool call deltas, a finish chunk, an optional usage
+// chunk and [DONE]
+func (h *OpenAIController) handleChatCompletionsStream(c fiber.Ctx, req dto.ChatCompletionRequest) error {
+	// The stream outlives this handler, so its context is cancelled by the body writer
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+
+	stream, err := h.service.CreateChatCompletionStream(ctx, req)
+	if err != nil {
+		cancel()
+		return h.respondError(c, err, req.Model)
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("Transfer-Encoding", "chunked")
+
+	c.RequestCtx().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		newChunk := func(delta models.Delta, finishReason *string) dto.ChatCompletionChunk {
+			return dto.ChatCompletionChunk{
+				ID:      stream.ID,
+				Object:  "chat.completion.chunk",
+				Created: stream.Created,
+				Model:   req.Model,
+				Choices: []dto.ChunkChoice{{Index: 0, Delta: delta, FinishReason: finishReason}},
+			}
+		}
+
+		if err := utils.SendSSEData(w, h.log, newChunk(models.Delta{Role: "assistant"}, nil)); err != nil {
+			return
+		}
+
+		// With tools, tool call blocks are held back and sent as tool_calls deltas
+		var parser *utils.ToolCallParser
+		if stream.Tools {
+			parser = &utils.ToolCallParser{}
+		}
+		// The reply is rebuilt from the deltas the way the client assembles it
+		var content strings.Builder
+		var toolCalls []models.ToolCall
+		send := func(text string, calls []utils.ToolCall) error {
+			if text != "" {
+				if err := utils.SendSSEData(w, h.log, newChunk(models.Delta{Content: text}, nil)); err != nil {
+					return err
+				}
+				content.WriteString(text)
+			}
+			for _, call := range NewToolCalls(calls, true) {
+				if !stream.ParallelToolCalls && len(toolCalls) > 0 {
+					break
+				}
+				index := len(toolCalls)
+				call.Index = &index
+				if err := utils.SendSSEData(w, h.log, newChunk(models.Delta{ToolCalls: []models.ToolCall{call}}, nil)); err != nil {
+					return err
+				}
+				call.Index = nil
+				toolCalls = append(toolCalls, call)
+			}
+			return nil
+		}
+
+		var completion *providers.Response
+		for chunk := range providers.CompleteDeltas(ctx, stream.Chunks) {
+			if chunk.Err != nil {
+				h.log.Error("GenerateContent streaming failed", zap.Error(chunk.Err), zap.String("model", req.Model))
+				_ = utils.SendSSEData(w, h.log, utils.ErrorToResponse(chunk.Err, providers.SurfaceErrorInfo(utils.SurfaceOpenAI, chunk.Err).Type))
+				return
+			}
+			if chunk.Response != nil {
+				completion = chunk.Response
+				continue
+			}
+
+			text, calls := chunk.Delta, []utils.ToolCall(nil)
+			if parser != nil {
+				text, calls = parser.Feed(chunk.Delta)
+			}
+			if err := send(text, calls); err != nil {
+				h.log.Info("Stream cancelled by client")
+				return
+			}
+		}
+		if completion == nil {
+			h.log.Warn("Stream ended before completion", zap.Error(ctx.Err()), zap.String("model", req.Model))
+			return
+		}
+		if parser != nil {
+			if err := send(parser.Flush()); err != nil {
+				return
+			}
+		}
+
+		// Images and sources are only known once the reply is complete
+		if len(completion.Images) > 0 || len(completion.Sources) > 0 {
+			delta := models.Delta{
+				Images:      NewImageParts(completion.Images),
+				Annotations: NewAnnotations(completion.Sources),
+			}
+			if err := utils.SendSSEData(w, h.log, newChunk(delta, nil)); err != nil {
+				return
+			}
+		}
+
+		finishReason := "stop"
+		if len(toolCalls) > 0 {
+			finishReason = "tool_calls"
+		}
+		if err := utils.SendSSEData(w, h.log, newChunk(models.Delta{}, &finishReason)); err != nil {
+			return
+		}
+		reply := models.Message{Role: "assistant", ToolCalls: toolCalls}
+		if content.Len() > 0 {
+			reply.Content = content.String()
+		}
+		stream.Commit(reply)
+
+		if req.StreamOptions != nil && req.StreamOptions.IncludeUsage {
+			usage := NewUsage(stream.PromptTokens, utils.EstimateTokens(completion.Text))
+			usageChunk := dto.ChatCompletionChunk{
+				ID:      stream.ID,
+				Object:  "chat.completion.chunk",
+				Created: stream.Created,
+				Model:   req.Model,
+				Choices: []dto.ChunkChoice{},
+				Usage:   &usage,
+			}
+			if err := utils.SendSSEData(w, h.log, usageChunk); err != nil {
+				return
+			}
+		}
+
+		_ = utils.SendSSEDone(w)
+	})
+
+	return nil
+}
+
+// HandleImageGenerations generates images with Gemini's image generation
+// @Summary Create Image (OpenAI)
+// @Description Generates images from a prompt. The size is passed to Gemini as an aspect ratio hint; b64_json images are downloaded with the account that generated them.
+// @Tags OpenAI
+// @Accept json
+// @Produce json
+// @Param request body dto.ImageGenerationRequest true "Image Generation Request"
+// @Success 200 {object} dto.ImagesResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /openai/v1/images/generations [post]
+func (h *OpenAIController) HandleImageGenerations(c fiber.Ctx) error {
+	var req dto.ImageGenerationRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorToResponse(fmt.Errorf("invalid request body: %w", err), "invalid_request_error"))
+	}
+
+	// Image generation takes longer than text, one request per image
+	ctx, cancel := context.WithTimeout(c.Context(), 10*time.Minute)
+	defer cancel()
+
+	response, err := h.service.GenerateImages(ctx, req)
+	if err != nil {
+		return h.respondError(c, err, req.Model)
+	}
+	return c.JSON(response)
+}
+
+// respondError writes a service error as an OpenAI error body with a matching status
+func (h *OpenAIController) respondError(c fiber.Ctx, err error, model string) error {
+	if errors.Is(err, providers.ErrModelNotFound) {
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: models.Error{
+				Message: fmt.Sprintf("The model `%s` does not exist or you do not have access to it.", model),
+				Type:    "invalid_request_error",
+				Code:    "model_not_found",
 			},
-		},
-		Usage: models.Usage{
-			PromptTokens:     0,
-			CompletionTokens: 0,
-			TotalTokens:      0,
-		},
+		})
+	}
+
+	info := providers.SurfaceErrorInfo(utils.SurfaceOpenAI, err)
+	if info.Status == fiber.StatusInternalServerError {
+		h.log.Error("GenerateContent failed", zap.Error(err), zap.String("model", model))
+	} else {
+		h.log.Warn("GenerateContent failed upstream", zap.Error(err), zap.String("model", model))
 	}
+	return c.Status(info.Status).JSON(utils.SurfaceErrorBody(utils.SurfaceOpenAI, info.Status, info.Type, info.Code, err.Error()))
 }
 
 // Register registers the OpenAI routes onto the provided group
 func (c *OpenAIController) Register(group fiber.Router) {
 	group.Get("/models", c.HandleModels)
 	group.Post("/chat/completions", c.HandleChatCompletions)
+	group.Post("/images/generations", c.HandleImageGenerations)
 }
@@ -2,11 +2,15 @@ package openai
 
 import (
 	"context"
+	"encoding/base64"
 	"fmt"
+	"strings"
 	"time"
 
 	"gemini-web-to-api/internal/commons/models"
+	"gemini-web-to-api/internal/commons/tracing"
 	"gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/conversation"
 	"gemini-web-to-api/internal/modules/openai/dto"
 	"gemini-web-to-api/internal/modules/providers"
 
@@ -14,14 +18,16 @@ import (
 )
 
 type OpenAIService struct {
-	client *providers.Client
-	log    *zap.Logger
+	client        *providers.AccountPool
+	conversations *conversation.ConversationService
+	log           *zap.Logger
 }
 
-func NewOpenAIService(client *providers.Client, log *zap.Logger) *OpenAIService {
+func NewOpenAIService(client *providers.AccountPool, conversations *conversation.ConversationService, log *zap.Logger) *OpenAIService {
 	return &OpenAIService{
-		client: client,
-		log:    log,
+		client:        client,
+		conversations: conversations,
+		log:           log,
 	}
 }
 
@@ -29,7 +35,190 @@ func (s *OpenAIService) ListModels() []providers.ModelInfo {
 	return s.client.ListModels()
 }
 
+// ChatCompletionStream is a streaming completion that has been accepted upstream
+type ChatCompletionStream struct {
+	ID           string
+	Created      int64
+	PromptTokens int
+	Chunks       <-chan providers.StreamChunk
+
+	// Tools is set when the reply must be parsed for emulated tool calls
+	Tools             bool
+	ParallelToolCalls bool
+
+	turn *conversation.Turn
+}
+
+// Commit records the streamed reply as the client received it, so a follow-up
+// request continues the same Gemini conversation
+func (s *ChatCompletionStream) Commit(reply models.Message) {
+	s.turn.Commit(reply)
+}
+
+// chatPrompt is a validated request flattened into a provider prompt
+type chatPrompt struct {
+	turn              *conversation.Turn
+	text              string
+	opts              []providers.GenerateOption
+	tools             bool
+	parallelToolCalls bool
+}
+
 func (s *OpenAIService) CreateChatCompletion(ctx context.Context, req dto.ChatCompletionRequest) (*dto.ChatCompletionResponse, error) {
+	ctx, span := tracing.Start(ctx, "OpenAIService.CreateChatCompletion",
+		tracing.AttrSurface.String(utils.SurfaceOpenAI),
+		tracing.AttrModel.String(req.Model),
+	)
+	defer span.End()
+
+	prompt, err := s.preparePrompt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logic: Call Provider
+	response, err := prompt.turn.Send(ctx, prompt.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logic: Construct Response
+	candidates := response.Candidates
+	if len(candidates) == 0 {
+		candidates = []providers.Candidate{{Content: response.Text, Images: response.Images, Sources: response.Sources}}
+	}
+	n := max(req.N, 1)
+	if n > len(candidates) {
+		s.log.Debug("Fewer drafts than requested choices", zap.Int("n", n), zap.Int("drafts", len(candidates)))
+	}
+
+	choices := []dto.Choice{}
+	for i, candidate := range candidates[:min(n, len(candidates))] {
+		choices = append(choices, newChoice(i, candidate, prompt))
+	}
+	prompt.turn.Commit(choices[0].Message)
+
+	return &dto.ChatCompletionResponse{
+		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().Unix()),
+		Object:  "chat.completion",
+		Created: time.Now().Unix(),
+		Model:   req.Model,
+		Choices: choices,
+		Usage:   NewUsage(utils.EstimateTokens(prompt.text), utils.EstimateTokens(response.Text)),
+	}, nil
+}
+
+// NewUsage builds the token usage of a completion. Gemini reports no token
+// counts, so both are estimated from the prompt and reply texts.
+func NewUsage(promptTokens, completionTokens int) models.Usage {
+	return models.Usage{
+		PromptTokens:     promptTokens,
+		CompletionTokens: completionTokens,
+		TotalTokens:      promptTokens + completionTokens,
+	}
+}
+
+// newChoice converts one Gemini draft to a choice, parsing emulated tool calls
+// and attaching its images and cited sources
+func newChoice(index int, candidate providers.Candidate, prompt *chatPrompt) dto.Choice {
+	message := models.Message{
+		Role:        "assistant",
+		Content:     candidate.Content,
+		Images:      NewImageParts(candidate.Images),
+		Annotations: NewAnnotations(candidate.Sources),
+	}
+	finishReason := "stop"
+	if prompt.tools {
+		text, calls := utils.ExtractToolCalls(candidate.Content)
+		if len(calls) > 0 {
+			message.Content = nil
+			if text != "" {
+				message.Content = text
+			}
+			message.ToolCalls = NewToolCalls(calls, prompt.parallelToolCalls)
+			finishReason = "tool_calls"
+		}
+	}
+	return dto.Choice{
+		Index:        index,
+		Message:      message,
+		FinishReason: finishReason,
+	}
+}
+
+// NewImageParts converts response images to image_url parts
+func NewImageParts(images []providers.Image) []models.ImagePart {
+	var parts []models.ImagePart
+	for _, img := range images {
+		parts = append(parts, models.ImagePart{Type: "image_url", ImageURL: models.ImageURL{URL: img.URL}})
+	}
+	return parts
+}
+
+// NewAnnotations converts cited sources to url_citation annotations
+func NewAnnotations(sources []providers.Source) []models.Annotation {
+	var annotations []models.Annotation
+	for _, source := range sources {
+		annotations = append(annotations, models.Annotation{
+			Type:        "url_citation",
+			URLCitation: &models.URLCitation{URL: source.URL, Title: source.Title},
+		})
+	}
+	return annotations
+}
+
+// CreateChatCompletionStream starts a streaming completion. Errors returned here
+// happen before any byte is sent; later failures arrive on the chunk channel.
+func (s *OpenAIService) CreateChatCompletionStream(ctx context.Context, req dto.ChatCompletionRequest) (*ChatCompletionStream, error) {
+	ctx, span := tracing.Start(ctx, "OpenAIService.CreateChatCompletionStream",
+		tracing.AttrSurface.String(utils.SurfaceOpenAI),
+		tracing.AttrModel.String(req.Model),
+	)
+	defer span.End()
+
+	prompt, err := s.preparePrompt(req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := prompt.turn.SendStream(ctx, prompt.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ChatCompletionStream{
+		ID:                fmt.Sprintf("chatcmpl-%d", time.Now().Unix()),
+		Created:           time.Now().Unix(),
+		PromptTokens:      utils.EstimateTokens(prompt.text),
+		Chunks:            chunks,
+		Tools:             prompt.tools,
+		ParallelToolCalls: prompt.parallelToolCalls,
+		turn:              prompt.turn,
+	}, nil
+}
+
+// NewToolCalls converts parsed tool calls to OpenAI tool calls with fresh IDs.
+// Only the first call is kept when parallel tool calls are disabled.
+func NewToolCalls(calls []utils.ToolCall, parallel bool) []models.ToolCall {
+	if !parallel && len(calls) > 1 {
+		calls = calls[:1]
+	}
+	toolCalls := make([]models.ToolCall, 0, len(calls))
+	for _, call := range calls {
+		toolCalls = append(toolCalls, models.ToolCall{
+			ID:   utils.NewToolCallID("call_"),
+			Type: "function",
+			Function: models.FunctionCall{
+				Name:      call.Name,
+				Arguments: string(call.Arguments),
+			},
+		})
+	}
+	return toolCalls
+}
+
+// preparePrompt validates the request and flattens it into a provider prompt
+func (s *OpenAIService) preparePrompt(req dto.ChatCompletionRequest) (*chatPrompt, error) {
 	// Logic: Validate messages
 	if err := utils.ValidateMessages(req.Messages); err != nil {
 		return nil, err
@@ -40,43 +229,167 @@ func (s *OpenAIService) CreateChatCompletion(ctx context.Context, req dto.ChatCo
 		return nil, err
 	}
 
-	// Logic: Build Prompt
-	prompt := utils.BuildPromptFromMessages(req.Messages, "")
-	if prompt == "" {
-		return nil, fmt.Errorf("no valid content in messages")
+	tools, choice, err := parseTools(req)
+	if err != nil {
+		return nil, err
+	}
+
+	// Logic: Build Prompt, continuing the conversation the history belongs to
+	turn := s.conversations.Begin(req.Model, "", req.Messages, utils.WithTools(tools, choice))
+	if turn.Prompt == "" {
+		return nil, utils.InvalidRequestf("no valid content in messages")
+	}
+
+	// Logic: Collect images and files not yet sent to the conversation
+	err = turn.CollectAttachments(func(first int) ([]utils.Attachment, error) {
+		return utils.ExtractAttachments(req.Messages[first:])
+	})
+	if err != nil {
+		return nil, err
 	}
 
 	opts := []providers.GenerateOption{}
 	if req.Model != "" {
 		opts = append(opts, providers.WithModel(req.Model))
 	}
+	return &chatPrompt{
+		turn:              turn,
+		text:              turn.Prompt,
+		opts:              opts,
+		tools:             utils.ToolsActive(tools, choice),
+		parallelToolCalls: req.ParallelToolCalls == nil || *req.ParallelToolCalls,
+	}, nil
+}
 
-	// Logic: Call Provider
-	response, err := s.client.GenerateContent(ctx, prompt, opts...)
+// parseTools converts the request's tools and tool_choice
+func parseTools(req dto.ChatCompletionRequest) ([]utils.ToolDefinition, utils.ToolChoice, error) {
+	var tools []utils.ToolDefinition
+	for _, tool := range req.Tools {
+		if tool.Type != "" && tool.Type != "function" {
+			return nil, utils.ToolChoice{}, utils.InvalidRequestf("unsupported tool type: %s", tool.Type)
+		}
+		if tool.Function.Name == "" {
+			return nil, utils.ToolChoice{}, utils.InvalidRequestf("tool function name is required")
+		}
+		tools = append(tools, utils.ToolDefinition{
+			Name:        tool.Function.Name,
+			Description: tool.Function.Description,
+			Parameters:  tool.Function.Parameters,
+		})
+	}
+
+	choice := utils.ToolChoice{Mode: utils.ToolChoiceAuto}
+	switch v := req.ToolChoice.(type) {
+	case nil:
+	case string:
+		switch v {
+		case utils.ToolChoiceAuto, utils.ToolChoiceNone, utils.ToolChoiceRequired:
+			choice.Mode = v
+		default:
+			return nil, utils.ToolChoice{}, utils.InvalidRequestf("invalid tool_choice: %s", v)
+		}
+	case map[string]interface{}:
+		function, _ := v["function"].(map[string]interface{})
+		name, _ := function["name"].(string)
+		if name == "" {
+			return nil, utils.ToolChoice{}, utils.InvalidRequestf("tool_choice function name is required")
+		}
+		choice = utils.ToolChoice{Mode: utils.ToolChoiceRequired, Name: name}
+	default:
+		return nil, utils.ToolChoice{}, utils.InvalidRequestf("invalid tool_choice")
+	}
+	return tools, choice, nil
+}
+
+// maxImages is the largest n accepted by the images endpoint, as in the OpenAI API
+const maxImages = 10
+
+// GenerateImages asks Gemini to draw the prompt until n images were generated.
+// Gemini usually returns a single image per reply, so up to n requests are sent.
+func (s *OpenAIService) GenerateImages(ctx context.Context, req dto.ImageGenerationRequest) (*dto.ImagesResponse, error) {
+	ctx, span := tracing.Start(ctx, "OpenAIService.GenerateImages",
+		tracing.AttrSurface.String(utils.SurfaceOpenAI),
+		tracing.AttrModel.String(req.Model),
+	)
+	defer span.End()
+
+	if strings.TrimSpace(req.Prompt) == "" {
+		return nil, utils.InvalidRequestf("prompt is required")
+	}
+	n := max(req.N, 1)
+	if n > maxImages {
+		return nil, utils.InvalidRequestf("n must be between 1 and %d", maxImages)
+	}
+	if req.ResponseFormat != "" && req.ResponseFormat != "url" && req.ResponseFormat != "b64_json" {
+		return nil, utils.InvalidRequestf("invalid response_format: %s", req.ResponseFormat)
+	}
+	prompt, err := buildImagePrompt(req.Prompt, req.Size)
 	if err != nil {
 		return nil, err
 	}
 
-	// Logic: Construct Response
-	return &dto.ChatCompletionResponse{
-		ID:      fmt.Sprintf("chatcmpl-%d", time.Now().Unix()),
-		Object:  "chat.completion",
-		Created: time.Now().Unix(),
-		Model:   req.Model,
-		Choices: []dto.Choice{
-			{
-				Index: 0,
-				Message: models.Message{
-					Role:    "assistant",
-					Content: response.Text,
-				},
-				FinishReason: "stop",
-			},
-		},
-		Usage: models.Usage{
-			PromptTokens:     0,
-			CompletionTokens: 0,
-			TotalTokens:      0,
-		},
-	}, nil
+	data := []dto.ImageData{}
+	var lastText string
+	for attempt := 0; attempt < n && len(data) < n; attempt++ {
+		response, err := s.client.GenerateContent(ctx, prompt, providers.WithModel(req.Model))
+		if err != nil {
+			return nil, err
+		}
+		lastText = response.Text
+
+		for _, img := range response.Images {
+			if !img.Generated || len(data) == n {
+				continue
+			}
+			image := dto.ImageData{URL: img.URL, RevisedPrompt: img.AltText}
+			if req.ResponseFormat == "b64_json" {
+				file, err := s.client.DownloadImage(ctx, response.AccountName(), img)
+				if err != nil {
+					return nil, err
+				}
+				image.URL = ""
+				image.B64JSON = base64.StdEncoding.EncodeToString(file.Data)
+			}
+			data = append(data, image)
+		}
+	}
+
+	if len(data) == 0 {
+		// Gemini answers in text when it declines to draw the prompt
+		return nil, fmt.Errorf("no image was generated: %s", lastText)
+	}
+	if len(data) < n {
+		s.log.Debug("Fewer images than requested", zap.Int("n", n), zap.Int("images", len(data)))
+	}
+	return &dto.ImagesResponse{Created: time.Now().Unix(), Data: data}, nil
+}
+
+// buildImagePrompt turns an images request into a Gemini prompt. The web app
+// has no size parameter, so the size becomes an aspect ratio hint.
+func buildImagePrompt(prompt, size string) (string, error) {
+	text := "Generate an image of: " + strings.TrimSpace(prompt)
+	if size == "" || size == "auto" {
+		return text, nil
+	}
+
+	var width, height int
+	if _, err := fmt.Sscanf(size, "%dx%d", &width, &height); err != nil || width <= 0 || height <= 0 {
+		return "", utils.InvalidRequestf("invalid size: %s", size)
+	}
+	orientation := "square"
+	switch {
+	case width > height:
+		orientation = "landscape"
+	case width < height:
+		orientation = "portrait"
+	}
+	d := gcd(width, height)
+	return fmt.Sprintf("%s\n\nUse a %s %d:%d aspect ratio.", text, orientation, width/d, height/d), nil
+}
+
+func gcd(a, b int) int {
+	for b != 0 {
+		a, b = b, a%b
+	}
+	return a
 }
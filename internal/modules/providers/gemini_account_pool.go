@@ -0,0 +1,483 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/commons/metrics"
+
+	"go.uber.org/zap"
+)
+
+// metadataAccountKey is the SessionMetadata.Extra key holding the account a conversation belongs to
+const metadataAccountKey = "account"
+
+// ErrNoAccountAvailable is returned when every account's circuit is open or the accounts are unhealthy
+var ErrNoAccountAvailable = errors.New("no Gemini account available")
+
+// Account is a single Google account in the pool, served by its own Client
+type Account struct {
+	client   *Client
+	breaker  *circuitBreaker
+	inFlight atomic.Int64
+}
+
+// Name returns the configured account name
+func (a *Account) Name() string {
+	return a.client.name
+}
+
+// InFlight returns the number of requests currently served by this account
+func (a *Account) InFlight() int64 {
+	return a.inFlight.Load()
+}
+
+// CooldownUntil returns when the account's open circuit lets a probe through
+// (zero if the circuit is closed)
+func (a *Account) CooldownUntil() time.Time {
+	return a.breaker.OpenUntil()
+}
+
+// LastError returns the error that last opened the account's circuit
+func (a *Account) LastError() error {
+	return a.breaker.LastError()
+}
+
+// Circuit returns the circuit state of the account at now
+func (a *Account) Circuit(now time.Time) string {
+	return a.breaker.State(now)
+}
+
+// available reports whether the account can take new requests
+func (a *Account) available(now time.Time) bool {
+	return a.breaker.ready(now) && a.client.IsHealthy()
+}
+
+// AccountPool dispatches requests across several Google accounts. Each account
+// is behind a circuit breaker: accounts that hit rate limits, auth failures or
+// repeated network errors are skipped for a cooldown and the request is retried
+// on another account.
+type AccountPool struct {
+	accounts []*Account
+	registry *ModelRegistry
+	strategy string
+	cooldown time.Duration
+	next     atomic.Uint64
+	log      *zap.Logger
+}
+
+// NewAccountPool creates one client per configured account
+func NewAccountPool(cfg *configs.Config, registry *ModelRegistry, upstream *Upstream, log *zap.Logger) *AccountPool {
+	pool := &AccountPool{
+		registry: registry,
+		strategy: cfg.Gemini.PoolStrategy,
+		cooldown: time.Duration(cfg.Gemini.AccountCooldown) * time.Second,
+		log:      log,
+	}
+	for _, account := range cfg.Gemini.Accounts {
+		client := NewClient(cfg, account, registry, upstream, log.With(zap.String("account", account.Name)))
+		pool.accounts = append(pool.accounts, &Account{
+			client:  client,
+			breaker: newCircuitBreaker(cfg.Gemini.CircuitFailures, pool.cooldown),
+		})
+	}
+	return pool
+}
+
+// Init initializes all accounts concurrently. It only fails when no account
+// could be initialized; the others keep serving traffic while the failed ones
+// are retried in the background.
+func (p *AccountPool) Init(ctx context.Context) error {
+	errs := make([]error, len(p.accounts))
+	var wg sync.WaitGroup
+	for i, account := range p.accounts {
+		wg.Add(1)
+		go func(i int, account *Account) {
+			defer wg.Done()
+			if err := account.client.Start(ctx); err != nil {
+				errs[i] = fmt.Errorf("account %s: %w", account.Name(), err)
+			}
+		}(i, account)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, err := range errs {
+		if err != nil {
+			failed++
+			p.log.Warn("Gemini account initialization failed", zap.Error(err))
+		}
+	}
+	if failed == len(p.accounts) {
+		return errors.Join(errs...)
+	}
+	if len(p.accounts) > 1 {
+		p.log.Info("Gemini account pool ready",
+			zap.Int("accounts", len(p.accounts)),
+			zap.Int("healthy", len(p.accounts)-failed),
+			zap.String("strategy", p.strategy),
+		)
+	}
+	return nil
+}
+
+// GenerateContent generates a response on the next available account, moving
+// to another account when the chosen one is rate limited or unauthorized
+func (p *AccountPool) GenerateContent(ctx context.Context, prompt string, options ...GenerateOption) (*Response, error) {
+	if _, err := p.validateModel(options); err != nil {
+		return nil, err
+	}
+
+	var response *Response
+	err := p.dispatch(ctx, func(account *Account) error {
+		account.inFlight.Add(1)
+		defer account.inFlight.Add(-1)
+
+		var err error
+		response, err = account.client.GenerateContent(ctx, prompt, options...)
+		p.record(account, err)
+		if err == nil {
+			tagAccount(response, account)
+		}
+		return err
+	})
+	return response, err
+}
+
+// GenerateContentStream opens a stream on the next available account. Failover
+// only happens while establishing the stream; the account is released and the
+// outcome recorded in its circuit when the stream ends.
+func (p *AccountPool) GenerateContentStream(ctx context.Context, prompt string, options ...GenerateOption) (<-chan StreamChunk, error) {
+	model, err := p.validateModel(options)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	var stream <-chan StreamChunk
+	err = p.dispatch(ctx, func(account *Account) error {
+		account.inFlight.Add(1)
+		chunks, err := account.client.GenerateContentStream(ctx, prompt, options...)
+		if err != nil {
+			account.inFlight.Add(-1)
+			p.record(account, err)
+			return err
+		}
+
+		firstToken := true
+		stream = watchStream(ctx, chunks, func(chunk StreamChunk) {
+			if firstToken && chunk.Delta != "" {
+				firstToken = false
+				metrics.TimeToFirstToken.WithLabelValues(model.ID).Observe(time.Since(start).Seconds())
+			}
+			if chunk.Response != nil {
+				tagAccount(chunk.Response, account)
+			}
+		}, func(err error) {
+			account.inFlight.Add(-1)
+			p.record(account, err)
+		})
+		return nil
+	})
+	return stream, err
+}
+
+// DownloadImage fetches a response image with the account that generated it,
+// named in the response metadata, since generated images are only readable by
+// that account. Other accounts are used when it is unknown.
+func (p *AccountPool) DownloadImage(ctx context.Context, account string, img Image) (*File, error) {
+	if a := p.accountByName(account); a != nil {
+		return a.client.DownloadImage(ctx, img)
+	}
+
+	var file *File
+	err := p.dispatch(ctx, func(a *Account) error {
+		var err error
+		file, err = a.client.DownloadImage(ctx, img)
+		p.record(a, err)
+		return err
+	})
+	return file, err
+}
+
+// tagAccount records the account that produced a response in its metadata
+func tagAccount(response *Response, account *Account) {
+	if response.Metadata == nil {
+		response.Metadata = map[string]any{}
+	}
+	response.Metadata[metadataAccountKey] = account.Name()
+}
+
+// StartChat creates a chat session bound to one account. Sessions restored from
+// metadata go back to the account that created the conversation.
+func (p *AccountPool) StartChat(options ...ChatOption) ChatSession {
+	config := &ChatConfig{}
+	for _, opt := range options {
+		opt(config)
+	}
+
+	if config.Metadata != nil {
+		if name, ok := config.Metadata.Extra[metadataAccountKey].(string); ok {
+			if account := p.accountByName(name); account != nil {
+				return p.startChat(account, options)
+			}
+			p.log.Warn("Session account no longer configured, starting on another account", zap.String("account", name))
+		}
+	}
+
+	account, err := p.acquire(nil)
+	if err != nil {
+		// Let the session surface the error on its first message
+		account = p.accounts[0]
+	}
+	return p.startChat(account, options)
+}
+
+func (p *AccountPool) startChat(account *Account, options []ChatOption) ChatSession {
+	return &accountChatSession{
+		ChatSession: account.client.StartChat(options...),
+		pool:        p,
+		account:     account,
+	}
+}
+
+// accountChatSession records the outcome of a session's messages in the circuit
+// of its account. Conversations only exist on the account that created them, so
+// the messages are neither failed over nor held back by an open circuit; their
+// outcome is only recorded when the circuit would have let them through, so
+// they neither close an open circuit early nor settle another request's probe.
+type accountChatSession struct {
+	ChatSession
+	pool    *AccountPool
+	account *Account
+}
+
+func (s *accountChatSession) SendMessage(ctx context.Context, message string, options ...GenerateOption) (*Response, error) {
+	allowed := s.account.breaker.allow(time.Now())
+	response, err := s.ChatSession.SendMessage(ctx, message, options...)
+	if allowed {
+		s.pool.record(s.account, err)
+	}
+	return response, err
+}
+
+func (s *accountChatSession) SendMessageStream(ctx context.Context, message string, options ...GenerateOption) (<-chan StreamChunk, error) {
+	allowed := s.account.breaker.allow(time.Now())
+	chunks, err := s.ChatSession.SendMessageStream(ctx, message, options...)
+	if err != nil {
+		if allowed {
+			s.pool.record(s.account, err)
+		}
+		return nil, err
+	}
+
+	return watchStream(ctx, chunks, nil, func(err error) {
+		if allowed {
+			s.pool.record(s.account, err)
+		}
+	}), nil
+}
+
+// Close stops all account clients
+func (p *AccountPool) Close() error {
+	var errs []error
+	for _, account := range p.accounts {
+		if err := account.client.Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func (p *AccountPool) GetName() string {
+	return "gemini"
+}
+
+// IsHealthy reports whether at least one account can serve requests
+func (p *AccountPool) IsHealthy() bool {
+	now := time.Now()
+	for _, account := range p.accounts {
+		if account.available(now) {
+			return true
+		}
+	}
+	return false
+}
+
+func (p *AccountPool) ListModels() []ModelInfo {
+	var models []ModelInfo
+	for _, m := range p.registry.List() {
+		if m.Provider == "gemini" {
+			models = append(models, m)
+		}
+	}
+	return models
+}
+
+// ResolveModel looks up a model by ID or alias in the registry
+func (p *AccountPool) ResolveModel(id string) (*ModelInfo, error) {
+	return p.registry.Resolve(id)
+}
+
+// Accounts returns the accounts of the pool
+func (p *AccountPool) Accounts() []*Account {
+	return p.accounts
+}
+
+// validateModel resolves the requested model before an account is picked, so
+// callers get ErrModelNotFound rather than an unrelated account error
+func (p *AccountPool) validateModel(options []GenerateOption) (*ModelInfo, error) {
+	config := &GenerateConfig{}
+	for _, opt := range options {
+		opt(config)
+	}
+	return p.registry.Resolve(config.Model)
+}
+
+// dispatch runs fn on available accounts until it succeeds, fails with an error
+// that another account would not fix, or every account has been tried. When
+// no account is available at all, unhealthy ones are re-initialized first.
+// fn records the outcome of its request with record: a stream's outcome is only
+// known when it ends.
+func (p *AccountPool) dispatch(ctx context.Context, fn func(*Account) error) error {
+	tried := make(map[*Account]bool)
+	revived := false
+	var lastErr error
+	for {
+		account, err := p.acquire(tried)
+		if err != nil {
+			if lastErr != nil {
+				return lastErr
+			}
+			if !revived && p.revive(ctx) {
+				revived = true
+				continue
+			}
+			return err
+		}
+		tried[account] = true
+		if !account.breaker.allow(time.Now()) {
+			continue // another request is probing the account
+		}
+
+		err = fn(account)
+		if err == nil {
+			return nil
+		}
+		if ctx.Err() != nil || !shouldFailover(err) {
+			return err
+		}
+
+		lastErr = err
+		p.log.Warn("Moving request to another Gemini account",
+			zap.String("account", account.Name()),
+			zap.Error(err),
+		)
+	}
+}
+
+// revive re-initializes unhealthy accounts whose circuit is not open, until
+// one succeeds. It reports whether an account became available.
+func (p *AccountPool) revive(ctx context.Context) bool {
+	now := time.Now()
+	for _, account := range p.accounts {
+		if !account.breaker.ready(now) || account.client.IsHealthy() {
+			continue
+		}
+		if err := account.client.Revive(ctx); err != nil {
+			p.log.Debug("Gemini account re-initialization failed", zap.String("account", account.Name()), zap.Error(err))
+			continue
+		}
+		p.log.Info("Gemini account re-initialized on demand", zap.String("account", account.Name()))
+		return true
+	}
+	return false
+}
+
+// acquire picks an available account that has not been tried yet
+func (p *AccountPool) acquire(tried map[*Account]bool) (*Account, error) {
+	now := time.Now()
+	var candidates []*Account
+	for _, account := range p.accounts {
+		if !tried[account] && account.available(now) {
+			candidates = append(candidates, account)
+		}
+	}
+	if len(candidates) == 0 {
+		return nil, ErrNoAccountAvailable
+	}
+
+	offset := int(p.next.Add(1)-1) % len(candidates)
+	if p.strategy != configs.PoolStrategyLeastInFlight {
+		return candidates[offset], nil
+	}
+
+	// Least in-flight, starting at the round-robin offset so ties are spread out
+	best := candidates[offset]
+	for i := 1; i < len(candidates); i++ {
+		candidate := candidates[(offset+i)%len(candidates)]
+		if candidate.InFlight() < best.InFlight() {
+			best = candidate
+		}
+	}
+	return best, nil
+}
+
+// shouldFailover reports whether another account could serve a request that
+// failed with err: rate limits and session failures are tied to the account
+func shouldFailover(err error) bool {
+	return errors.Is(err, ErrRateLimited) ||
+		errors.Is(err, ErrAuthExpired) ||
+		errors.Is(err, ErrNotInitialized)
+}
+
+// record reports the outcome of a request to the account's circuit breaker
+func (p *AccountPool) record(account *Account, err error) {
+	if !account.breaker.record(time.Now(), err) {
+		return
+	}
+	p.log.Warn("Gemini account circuit opened",
+		zap.String("account", account.Name()),
+		zap.Duration("cooldown", p.cooldown),
+		zap.Error(err),
+	)
+}
+
+func (p *AccountPool) accountByName(name string) *Account {
+	for _, account := range p.accounts {
+		if account.Name() == name {
+			return account
+		}
+	}
+	return nil
+}
+
+// AccountName returns the account that produced the response, if known
+func (r *Response) AccountName() string {
+	name, _ := r.Metadata[metadataAccountKey].(string)
+	return name
+}
+
+// AccountName returns the account that owns the conversation, if known
+func (m *SessionMetadata) AccountName() string {
+	name, _ := m.Extra[metadataAccountKey].(string)
+	return name
+}
+
+// SessionMetadata returns the state of the conversation the response was
+// generated in, for continuing it with StartChat
+func (r *Response) SessionMetadata(model string) *SessionMetadata {
+	rcid, _ := r.Metadata["rcid"].(string)
+	return &SessionMetadata{
+		ConversationID: r.ConversationID,
+		ResponseID:     r.ResponseID,
+		ChoiceID:       rcid,
+		Model:          model,
+		Extra:          map[string]any{metadataAccountKey: r.AccountName()},
+	}
+}
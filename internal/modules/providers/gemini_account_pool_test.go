@@ -0,0 +1,138 @@
+package providers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/pkg/fakegemini"
+
+	"go.uber.org/zap"
+)
+
+// newTestPool returns an initialized pool with a single account on fake, whose
+// circuit stays open for an hour once opened
+func newTestPool(t *testing.T, fake *fakegemini.Server) (*AccountPool, *Account) {
+	t.Helper()
+	t.Chdir(t.TempDir())
+
+	cfg := &configs.Config{}
+	cfg.Gemini.Accounts = []configs.GeminiAccount{{Name: "test", Secure1PSID: "psid", Secure1PSIDTS: "psidts"}}
+	cfg.Gemini.AccountCooldown = 3600
+	cfg.Gemini.CircuitFailures = 3
+	registry, err := NewModelRegistry(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := &Upstream{Endpoints: NewEndpoints(mockBaseURL), Transport: fake.Transport()}
+
+	pool := NewAccountPool(cfg, registry, upstream, zap.NewNop())
+	t.Cleanup(func() { pool.Close() })
+	if err := pool.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return pool, pool.Accounts()[0]
+}
+
+// openCircuit opens the account's circuit; with halfOpen its cooldown is over
+func openCircuit(account *Account, halfOpen bool) {
+	b := account.breaker
+	b.record(time.Now(), ErrRateLimited)
+	if halfOpen {
+		b.mu.Lock()
+		b.openUntil = time.Now()
+		b.mu.Unlock()
+	}
+}
+
+func TestPoolStreamOutcomeRecordedWhenItEnds(t *testing.T) {
+	fake := fakegemini.NewServer()
+	pool, account := newTestPool(t, fake)
+	openCircuit(account, true)
+	fake.Enqueue(fakegemini.Reply{Text: "one two three", Abort: true})
+
+	chunks, err := pool.GenerateContentStream(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The stream is open but its outcome unknown: the probe is still running
+	if state := account.Circuit(time.Now()); state != CircuitHalfOpen || account.breaker.ready(time.Now()) {
+		t.Fatalf("circuit = %s (ready %v) while the probe streams", state, account.breaker.ready(time.Now()))
+	}
+
+	var streamErr error
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			streamErr = chunk.Err
+		}
+	}
+	if streamErr == nil {
+		t.Fatal("aborted stream ended without an error")
+	}
+	if state := account.Circuit(time.Now()); state != CircuitOpen {
+		t.Errorf("circuit = %s after the probe stream failed, want %s", state, CircuitOpen)
+	}
+	if account.InFlight() != 0 {
+		t.Errorf("in flight = %d after the stream ended", account.InFlight())
+	}
+}
+
+func TestPoolStreamProbeClosesCircuit(t *testing.T) {
+	fake := fakegemini.NewServer()
+	pool, account := newTestPool(t, fake)
+	openCircuit(account, true)
+	fake.Enqueue(fakegemini.Reply{Text: "one two three"})
+
+	chunks, err := pool.GenerateContentStream(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for chunk := range chunks {
+		if chunk.Err != nil {
+			t.Fatal(chunk.Err)
+		}
+	}
+	if state := account.Circuit(time.Now()); state != CircuitClosed {
+		t.Errorf("circuit = %s after the probe stream succeeded, want %s", state, CircuitClosed)
+	}
+}
+
+func TestSessionMessagesLeaveCircuit(t *testing.T) {
+	t.Run("open", func(t *testing.T) {
+		fake := fakegemini.NewServer()
+		pool, account := newTestPool(t, fake)
+		openCircuit(account, false)
+
+		chat := pool.StartChat()
+		if _, err := chat.SendMessage(context.Background(), "hi"); err != nil {
+			t.Fatal(err)
+		}
+		chunks, err := chat.SendMessageStream(context.Background(), "again")
+		if err != nil {
+			t.Fatal(err)
+		}
+		for range chunks {
+		}
+		if state := account.Circuit(time.Now()); state != CircuitOpen {
+			t.Errorf("circuit = %s after session messages, want %s", state, CircuitOpen)
+		}
+	})
+
+	t.Run("probing", func(t *testing.T) {
+		fake := fakegemini.NewServer()
+		pool, account := newTestPool(t, fake)
+		openCircuit(account, true)
+		if !account.breaker.allow(time.Now()) {
+			t.Fatal("half-open circuit refused the probe")
+		}
+
+		chat := pool.StartChat()
+		if _, err := chat.SendMessage(context.Background(), "hi"); err != nil {
+			t.Fatal(err)
+		}
+		if account.breaker.ready(time.Now()) {
+			t.Error("session message settled another request's probe")
+		}
+	})
+}
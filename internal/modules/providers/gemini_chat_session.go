@@ -2,8 +2,6 @@ package providers
 
 import (
 	"context"
-	"encoding/json"
-	"fmt"
 )
 
 // GeminiChatSession implements ChatSession interface for Gemini
@@ -16,94 +14,84 @@ type GeminiChatSession struct {
 
 // SendMessage sends a message in the chat session
 func (s *GeminiChatSession) SendMessage(ctx context.Context, message string, options ...GenerateOption) (*Response, error) {
-	// Read session token safely — short critical section, no lock held during HTTP call
-	s.client.mu.RLock()
-	at := s.client.at
-	s.client.mu.RUnlock()
-
-	if at == "" {
-		return nil, fmt.Errorf("client not initialized")
-	}
-
-	// Build conversation context
-	inner := []interface{}{
-		[]interface{}{message},
-		nil,
-		s.buildMetadata(),
-	}
-
-	innerJSON, _ := json.Marshal(inner)
-	outer := []interface{}{nil, string(innerJSON)}
-	outerJSON, _ := json.Marshal(outer)
-
-	formData := map[string]string{
-		"at":    at,
-		"f.req": string(outerJSON),
-	}
-
-	resp, err := s.client.httpClient.R().
-		SetContext(ctx).
-		SetFormData(formData).
-		SetQueryParam("at", at).
-		Post(EndpointGenerate)
-
+	response, err := s.client.GenerateContent(ctx, message, s.options(options)...)
 	if err != nil {
 		return nil, err
 	}
+	s.update(message, response)
+	return response, nil
+}
 
-	if resp.StatusCode != 200 {
-		return nil, fmt.Errorf("chat failed with status: %d", resp.StatusCode)
-	}
-
-	response, err := s.client.parseResponse(resp.String())
+// SendMessageStream sends a message in the chat session and streams the reply.
+// The session is only updated if the stream completes.
+func (s *GeminiChatSession) SendMessageStream(ctx context.Context, message string, options ...GenerateOption) (<-chan StreamChunk, error) {
+	chunks, err := s.client.GenerateContentStream(ctx, message, s.options(options)...)
 	if err != nil {
 		return nil, err
 	}
 
-	// Update session metadata
-	if response.Metadata != nil {
-		if cid, ok := response.Metadata["cid"].(string); ok && cid != "" {
-			if s.metadata == nil {
-				s.metadata = &SessionMetadata{}
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		for chunk := range chunks {
+			if chunk.Response != nil {
+				s.update(message, chunk.Response)
 			}
-			s.metadata.ConversationID = cid
-		}
-		if rid, ok := response.Metadata["rid"].(string); ok && rid != "" {
-			if s.metadata == nil {
-				s.metadata = &SessionMetadata{}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
 			}
-			s.metadata.ResponseID = rid
 		}
-		if rcid, ok := response.Metadata["rcid"].(string); ok && rcid != "" {
-			if s.metadata == nil {
-				s.metadata = &SessionMetadata{}
-			}
-			s.metadata.ChoiceID = rcid
-		}
-	}
+	}()
+	return out, nil
+}
 
-	// Update history
-	s.history = append(s.history, Message{
-		Role:    "user",
-		Content: message,
-	})
-	s.history = append(s.history, Message{
-		Role:    "model",
-		Content: response.Text,
+// options pins the session's model and conversation after the caller's options
+func (s *GeminiChatSession) options(options []GenerateOption) []GenerateOption {
+	return append(options, WithModel(s.model), func(c *GenerateConfig) {
+		c.Conversation = s.metadata
 	})
+}
 
-	return response, nil
+// update records a reply: the conversation now continues from it
+func (s *GeminiChatSession) update(message string, response *Response) {
+	if s.metadata == nil {
+		s.metadata = &SessionMetadata{}
+	}
+	if cid, ok := response.Metadata["cid"].(string); ok && cid != "" {
+		s.metadata.ConversationID = cid
+	}
+	if rid, ok := response.Metadata["rid"].(string); ok && rid != "" {
+		s.metadata.ResponseID = rid
+	}
+	if rcid, ok := response.Metadata["rcid"].(string); ok && rcid != "" {
+		s.metadata.ChoiceID = rcid
+	}
+
+	s.history = append(s.history,
+		Message{Role: "user", Content: message},
+		Message{Role: "model", Content: response.Text, Images: response.Images},
+	)
 }
 
-// GetMetadata returns session metadata
+// GetMetadata returns session metadata. The account name is recorded in Extra
+// because Gemini conversation IDs are only valid for the account that created them.
 func (s *GeminiChatSession) GetMetadata() *SessionMetadata {
-	if s.metadata == nil {
-		return &SessionMetadata{
-			Model: s.model,
+	metadata := SessionMetadata{}
+	if s.metadata != nil {
+		metadata = *s.metadata
+	}
+	metadata.Model = s.model
+
+	extra := map[string]any{metadataAccountKey: s.client.name}
+	for k, v := range metadata.Extra {
+		if k != metadataAccountKey {
+			extra[k] = v
 		}
 	}
-	s.metadata.Model = s.model
-	return s.metadata
+	metadata.Extra = extra
+	return &metadata
 }
 
 // GetHistory returns conversation history
@@ -116,16 +104,3 @@ func (s *GeminiChatSession) Clear() {
 	s.history = []Message{}
 	s.metadata = nil
 }
-
-// buildMetadata builds metadata array for API request
-func (s *GeminiChatSession) buildMetadata() []interface{} {
-	if s.metadata == nil {
-		return []interface{}{nil, nil, nil}
-	}
-
-	return []interface{}{
-		s.metadata.ConversationID,
-		s.metadata.ResponseID,
-		s.metadata.ChoiceID,
-	}
-}
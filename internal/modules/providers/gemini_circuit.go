@@ -0,0 +1,134 @@
+package providers
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// Circuit states of an account
+const (
+	CircuitClosed   = "closed"    // serving requests
+	CircuitOpen     = "open"      // skipped until its cooldown ends
+	CircuitHalfOpen = "half_open" // cooldown over, the next request probes the account
+)
+
+// circuitBreaker stops sending requests to an account that keeps failing.
+// Rate limits and rejected sessions open it at once, network and parse
+// failures after threshold consecutive ones. Once the cooldown is over a single
+// probe request is let through: success closes the circuit, failure opens it
+// again.
+type circuitBreaker struct {
+	threshold int
+	cooldown  time.Duration
+
+	mu        sync.Mutex // protects: state, failures, openUntil, probing, lastErr
+	state     string
+	failures  int
+	openUntil time.Time
+	probing   bool
+	lastErr   error
+}
+
+func newCircuitBreaker(threshold int, cooldown time.Duration) *circuitBreaker {
+	return &circuitBreaker{
+		threshold: max(threshold, 1),
+		cooldown:  cooldown,
+		state:     CircuitClosed,
+	}
+}
+
+// State returns the state of the circuit at now
+func (b *circuitBreaker) State(now time.Time) string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.stateAt(now)
+}
+
+func (b *circuitBreaker) stateAt(now time.Time) string {
+	if b.state == CircuitOpen && !now.Before(b.openUntil) {
+		return CircuitHalfOpen
+	}
+	return b.state
+}
+
+// OpenUntil returns when an open circuit lets a probe through (zero otherwise)
+func (b *circuitBreaker) OpenUntil() time.Time {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.state != CircuitOpen {
+		return time.Time{}
+	}
+	return b.openUntil
+}
+
+// LastError returns the error that last opened the circuit
+func (b *circuitBreaker) LastError() error {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastErr
+}
+
+// ready reports whether allow would let a request through at now
+func (b *circuitBreaker) ready(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateAt(now) {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		return !b.probing
+	default:
+		return false
+	}
+}
+
+// allow claims the right to send a request. A half-open circuit only lets one
+// probe through until its outcome is recorded.
+func (b *circuitBreaker) allow(now time.Time) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	switch b.stateAt(now) {
+	case CircuitClosed:
+		return true
+	case CircuitHalfOpen:
+		if b.probing {
+			return false
+		}
+		b.state = CircuitHalfOpen
+		b.probing = true
+		return true
+	default:
+		return false
+	}
+}
+
+// record reports the outcome of a request and whether it opened the circuit.
+// Errors that say nothing about the account (cancellation, unknown model,
+// blocked content) leave the circuit as it is.
+func (b *circuitBreaker) record(now time.Time, err error) bool {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	probe := b.probing
+	b.probing = false
+
+	switch {
+	case err == nil:
+		b.state = CircuitClosed
+		b.failures = 0
+		return false
+	case errors.Is(err, ErrRateLimited), errors.Is(err, ErrAuthExpired), errors.Is(err, ErrNotInitialized):
+	case errors.Is(err, ErrNetwork), errors.Is(err, ErrMalformedResponse):
+		b.failures++
+		if !probe && b.failures < b.threshold {
+			return false
+		}
+	default:
+		return false
+	}
+
+	b.state = CircuitOpen
+	b.openUntil = now.Add(b.cooldown)
+	b.lastErr = err
+	return true
+}
@@ -0,0 +1,86 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"gemini-web-to-api/internal/commons/metrics"
+	"gemini-web-to-api/internal/commons/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// rpcDeleteConversation is the batchexecute RPC the web app uses to delete a chat
+const rpcDeleteConversation = "GzXR5e"
+
+// DeleteConversation removes a conversation from the account's Gemini history
+func (c *Client) DeleteConversation(ctx context.Context, conversationID string) error {
+	at, err := c.sessionToken(ctx)
+	if err != nil {
+		return err
+	}
+
+	if err := c.batchExecute(ctx, at, rpcDeleteConversation, []interface{}{conversationID}); err != nil {
+		return fmt.Errorf("failed to delete conversation: %w", err)
+	}
+	c.log.Debug("Deleted Gemini conversation", zap.String("conversation_id", conversationID))
+	return nil
+}
+
+// batchExecute calls a single RPC on the batchexecute endpoint
+func (c *Client) batchExecute(ctx context.Context, at, rpcID string, payload []interface{}) (err error) {
+	ctx, span := tracing.Start(ctx, "gemini.batchexecute",
+		tracing.AttrAccount.String(c.name),
+		attribute.String("gemini.rpc", rpcID),
+	)
+	defer func() {
+		c.recordError(err)
+		tracing.End(span, err)
+	}()
+
+	payloadJSON, _ := json.Marshal(payload)
+	request, _ := json.Marshal([]interface{}{
+		[]interface{}{
+			[]interface{}{rpcID, string(payloadJSON), nil, "generic"},
+		},
+	})
+
+	start := time.Now()
+	resp, err := c.request(ctx).
+		SetFormData(map[string]string{
+			"at":    at,
+			"f.req": string(request),
+		}).
+		SetQueryParams(map[string]string{
+			"rpcids":      rpcID,
+			"source-path": "/app",
+			"hl":          "en",
+			"rt":          "c",
+		}).
+		Post(c.endpoints.BatchExec)
+	metrics.ObserveUpstream(metrics.OperationBatchExecute, c.name, statusCode(resp), time.Since(start))
+	if err != nil {
+		return networkError(err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return statusError(resp.StatusCode)
+	}
+	return nil
+}
+
+// DeleteConversation deletes a session's conversation on the account that owns it
+func (p *AccountPool) DeleteConversation(ctx context.Context, metadata *SessionMetadata) error {
+	if metadata == nil || metadata.ConversationID == "" {
+		return nil
+	}
+	account := p.accountByName(metadata.AccountName())
+	if account == nil {
+		return errors.New("the account of the conversation is no longer configured")
+	}
+	return account.client.DeleteConversation(ctx, metadata.ConversationID)
+}
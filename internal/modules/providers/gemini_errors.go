@@ -0,0 +1,147 @@
+package providers
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	utils "gemini-web-to-api/internal/commons/utils"
+)
+
+// Categories of upstream failures. Errors returned by the client wrap one of
+// them in an *UpstreamError, so callers can test them with errors.Is.
+var (
+	ErrRateLimited       = errors.New("rate limited by Gemini")
+	ErrAuthExpired       = errors.New("session rejected by Gemini")
+	ErrContentBlocked    = errors.New("reply blocked by Gemini")
+	ErrMalformedResponse = errors.New("malformed response from Gemini")
+	ErrNetwork           = errors.New("cannot reach Gemini")
+)
+
+// Error codes Gemini reports inside a 200 StreamGenerate answer
+const (
+	errorCodeUsageLimit = 1037 // the account exhausted its usage limit
+	errorCodeIPBlocked  = 1060 // the IP address is temporarily blocked
+)
+
+// UpstreamError is a classified failure of a call to Gemini
+type UpstreamError struct {
+	Kind error // ErrRateLimited, ErrAuthExpired, ErrContentBlocked, ErrMalformedResponse or ErrNetwork
+	Err  error // underlying failure
+}
+
+func (e *UpstreamError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+func (e *UpstreamError) Unwrap() []error {
+	return []error{e.Kind, e.Err}
+}
+
+// statusError classifies a non-200 answer. Other 4xx statuses stay unclassified.
+func statusError(code int) error {
+	err := &StatusError{StatusCode: code}
+	switch {
+	case code == http.StatusTooManyRequests:
+		return &UpstreamError{Kind: ErrRateLimited, Err: err}
+	case code == http.StatusUnauthorized, code == http.StatusForbidden:
+		return &UpstreamError{Kind: ErrAuthExpired, Err: err}
+	case code >= http.StatusInternalServerError:
+		return &UpstreamError{Kind: ErrNetwork, Err: err}
+	default:
+		return err
+	}
+}
+
+// networkError classifies a request that got no answer. Cancellation by the
+// caller is not Gemini's fault and is returned as is.
+func networkError(err error) error {
+	if errors.Is(err, context.Canceled) {
+		return err
+	}
+	return &UpstreamError{Kind: ErrNetwork, Err: err}
+}
+
+// responseError classifies a StreamGenerate body without a usable reply:
+// either an error frame or something the parser does not understand
+func responseError(frames []string, sample string) error {
+	for _, frame := range frames {
+		if code, ok := parseErrorCode(frame); ok {
+			kind := ErrMalformedResponse
+			if code == errorCodeUsageLimit || code == errorCodeIPBlocked {
+				kind = ErrRateLimited
+			}
+			return &UpstreamError{Kind: kind, Err: fmt.Errorf("error code %d", code)}
+		}
+	}
+
+	if len(sample) > 500 {
+		sample = sample[:500]
+	}
+	return &UpstreamError{Kind: ErrMalformedResponse, Err: fmt.Errorf("no reply in response. Sample: %s", sample)}
+}
+
+// parseErrorCode reads the code of an error frame:
+// [["wrb.fr", null, null, null, null, [3, null, [["...BardErrorInfo", [code]]]]]]
+func parseErrorCode(frame string) (int, bool) {
+	var root []interface{}
+	if err := json.Unmarshal([]byte(frame), &root); err != nil {
+		return 0, false
+	}
+	for _, item := range root {
+		if tag, _ := stringAt(item, 0); tag != "wrb.fr" {
+			continue
+		}
+		if code, ok := at(item, 5, 2, 0, 1, 0).(float64); ok {
+			return int(code), true
+		}
+	}
+	return 0, false
+}
+
+// blockedError is returned when Gemini answers with an empty reply, which is
+// how filtered prompts come back
+func blockedError(response *Response) error {
+	if response.Text != "" || len(response.Images) > 0 {
+		return nil
+	}
+	return &UpstreamError{Kind: ErrContentBlocked, Err: errors.New("empty reply")}
+}
+
+// ErrorInfo is how a failure is reported on an API surface
+type ErrorInfo struct {
+	Status int    // HTTP status
+	Type   string // OpenAI or Anthropic error type
+	Code   string // OpenAI error code
+}
+
+// SurfaceErrorInfo maps err to the status and error type of surface. Upstream
+// failures get the status and type clients of that API retry or report on,
+// requests failing validation a 400; anything else is a 500 api_error.
+func SurfaceErrorInfo(surface string, err error) ErrorInfo {
+	claude := surface == utils.SurfaceClaude
+	switch {
+	case errors.Is(err, ErrRateLimited):
+		if claude {
+			return ErrorInfo{Status: utils.StatusOverloaded, Type: "overloaded_error"}
+		}
+		return ErrorInfo{Status: http.StatusTooManyRequests, Type: "rate_limit_error", Code: "rate_limit_exceeded"}
+	case errors.Is(err, ErrAuthExpired), errors.Is(err, ErrNoAccountAvailable):
+		if claude {
+			return ErrorInfo{Status: utils.StatusOverloaded, Type: "overloaded_error"}
+		}
+		return ErrorInfo{Status: http.StatusServiceUnavailable, Type: "server_error", Code: "service_unavailable"}
+	case errors.Is(err, utils.ErrInvalidRequest):
+		return ErrorInfo{Status: http.StatusBadRequest, Type: "invalid_request_error"}
+	case errors.Is(err, ErrContentBlocked):
+		return ErrorInfo{Status: http.StatusBadRequest, Type: "invalid_request_error", Code: "content_filter"}
+	case errors.Is(err, context.DeadlineExceeded):
+		return ErrorInfo{Status: http.StatusGatewayTimeout, Type: "api_error", Code: "upstream_timeout"}
+	case errors.Is(err, ErrNetwork), errors.Is(err, ErrMalformedResponse):
+		return ErrorInfo{Status: http.StatusBadGateway, Type: "api_error", Code: "upstream_error"}
+	default:
+		return ErrorInfo{Status: http.StatusInternalServerError, Type: "api_error"}
+	}
+}
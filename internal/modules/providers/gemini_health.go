@@ -0,0 +1,82 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// ClientHealth is a snapshot of the session state of a client
+type ClientHealth struct {
+	Healthy                 bool      // holds a session token that has not been invalidated
+	CookiesRefreshedAt      time.Time // last successful __Secure-1PSIDTS rotation
+	SessionTokenRefreshedAt time.Time // last successful SNlM0e fetch
+	LastError               error     // last failed upstream call
+	LastErrorAt             time.Time
+}
+
+// Health returns the session state of the client
+func (c *Client) Health() ClientHealth {
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	return ClientHealth{
+		Healthy:                 c.healthy,
+		CookiesRefreshedAt:      c.cookiesRotatedAt,
+		SessionTokenRefreshedAt: c.tokenRefreshedAt,
+		LastError:               c.lastErr,
+		LastErrorAt:             c.lastErrAt,
+	}
+}
+
+// recordError remembers a failed upstream call for health reporting. Errors
+// caused by the caller (cancellation, unknown model, foreign image) are ignored,
+// and so is ErrNotInitialized, whose cause was recorded by the failed Init.
+func (c *Client) recordError(err error) {
+	if err == nil ||
+		errors.Is(err, context.Canceled) ||
+		errors.Is(err, ErrNotInitialized) ||
+		errors.Is(err, ErrModelNotFound) ||
+		errors.Is(err, ErrImageHost) {
+		return
+	}
+
+	c.mu.Lock()
+	c.lastErr = err
+	c.lastErrAt = time.Now()
+	c.mu.Unlock()
+}
+
+// AccountHealth is a snapshot of the health of an account in the pool
+type AccountHealth struct {
+	ClientHealth
+	Name          string
+	Available     bool   // healthy and not cooling down
+	Circuit       string // CircuitClosed, CircuitOpen or CircuitHalfOpen
+	CooldownUntil time.Time
+	InFlight      int64
+}
+
+// Health returns the health of the account at now
+func (a *Account) Health(now time.Time) AccountHealth {
+	health := AccountHealth{
+		ClientHealth: a.client.Health(),
+		Name:         a.Name(),
+		Available:    a.available(now),
+		Circuit:      a.Circuit(now),
+		InFlight:     a.InFlight(),
+	}
+	if health.Circuit == CircuitOpen {
+		health.CooldownUntil = a.CooldownUntil()
+	}
+	return health
+}
+
+// Health returns the health of every account of the pool
+func (p *AccountPool) Health() []AccountHealth {
+	now := time.Now()
+	accounts := make([]AccountHealth, 0, len(p.accounts))
+	for _, account := range p.accounts {
+		accounts = append(accounts, account.Health(now))
+	}
+	return accounts
+}
@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+
+	"gemini-web-to-api/internal/commons/metrics"
+	"gemini-web-to-api/internal/commons/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// generatedImageFullSize is appended to generated image URLs to fetch the
+// full resolution instead of the preview the web app embeds
+const generatedImageFullSize = "=s2048"
+
+// ErrImageHost is returned for images outside Google's content hosts. The
+// client sends the account's cookies with every request, so other hosts are
+// never fetched with it.
+var ErrImageHost = errors.New("image is not hosted by Google")
+
+// DownloadImage fetches an image from Gemini's content servers with the account's cookies
+func (c *Client) DownloadImage(ctx context.Context, img Image) (file *File, err error) {
+	ctx, span := tracing.Start(ctx, "gemini.download_image",
+		tracing.AttrAccount.String(c.name),
+		attribute.Bool("gemini.image.generated", img.Generated),
+	)
+	defer func() {
+		c.recordError(err)
+		tracing.End(span, err)
+	}()
+
+	u, err := url.Parse(img.URL)
+	if err != nil || !c.isImageHost(u) {
+		return nil, fmt.Errorf("%w: %s", ErrImageHost, img.URL)
+	}
+
+	target := img.URL
+	if img.Generated && !strings.Contains(path.Base(u.Path), "=") {
+		target += generatedImageFullSize
+	}
+
+	start := time.Now()
+	resp, err := c.request(ctx).
+		Get(target)
+	metrics.ObserveUpstream(metrics.OperationDownloadImage, c.name, statusCode(resp), time.Since(start))
+	if err != nil {
+		return nil, fmt.Errorf("failed to download image: %w", networkError(err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to download image: %w", statusError(resp.StatusCode))
+	}
+
+	mimeType, _, _ := mime.ParseMediaType(resp.GetHeader("Content-Type"))
+	if !strings.HasPrefix(mimeType, "image/") {
+		return nil, fmt.Errorf("failed to download image: unexpected content type %q", mimeType)
+	}
+	data := resp.Bytes()
+
+	c.log.Debug("Downloaded image",
+		zap.String("mime_type", mimeType),
+		zap.Int("bytes", len(data)),
+	)
+	name := path.Base(u.Path)
+	if exts, _ := mime.ExtensionsByType(mimeType); len(exts) > 0 && path.Ext(name) == "" {
+		name += exts[0]
+	}
+	return &File{Name: name, MimeType: mimeType, Data: data}, nil
+}
+
+// isImageHost reports whether the authenticated client may fetch u: Google
+// content hosts over https, or the upstream itself when it is not Google
+func (c *Client) isImageHost(u *url.URL) bool {
+	if u.Scheme == "https" && isGoogleContentHost(u.Hostname()) {
+		return true
+	}
+	upstream := c.endpoints.host()
+	return !isGoogleContentHost(upstream) && strings.EqualFold(u.Hostname(), upstream) &&
+		(u.Scheme == "https" || u.Scheme == "http")
+}
+
+// isGoogleContentHost reports whether host serves Gemini images
+func isGoogleContentHost(host string) bool {
+	host = strings.ToLower(host)
+	for _, domain := range []string{"googleusercontent.com", "gstatic.com", "google.com"} {
+		if host == domain || strings.HasSuffix(host, "."+domain) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,77 @@
+package providers
+
+import (
+	"time"
+
+	"gemini-web-to-api/internal/commons/metrics"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	healthyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "", "healthy"),
+		"Whether at least one Gemini account can serve requests.",
+		nil, nil,
+	)
+	accountHealthyDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "account", "healthy"),
+		"Whether the account holds a valid session token.",
+		[]string{"account"}, nil,
+	)
+	accountAvailableDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "account", "available"),
+		"Whether the account is healthy and its circuit lets requests through.",
+		[]string{"account"}, nil,
+	)
+	accountCircuitDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "account", "circuit_state"),
+		"Circuit state of the account: 1 for the current state, 0 for the others.",
+		[]string{"account", "state"}, nil,
+	)
+	accountInFlightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(metrics.Namespace, "account", "in_flight_requests"),
+		"Requests currently served by the account.",
+		[]string{"account"}, nil,
+	)
+)
+
+// poolCollector reports the health of the account pool at scrape time
+type poolCollector struct {
+	pool *AccountPool
+}
+
+func (c poolCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- healthyDesc
+	ch <- accountHealthyDesc
+	ch <- accountAvailableDesc
+	ch <- accountCircuitDesc
+	ch <- accountInFlightDesc
+}
+
+func (c poolCollector) Collect(ch chan<- prometheus.Metric) {
+	now := time.Now()
+	ch <- prometheus.MustNewConstMetric(healthyDesc, prometheus.GaugeValue, gaugeBool(c.pool.IsHealthy()))
+	for _, account := range c.pool.accounts {
+		name := account.Name()
+		ch <- prometheus.MustNewConstMetric(accountHealthyDesc, prometheus.GaugeValue, gaugeBool(account.client.IsHealthy()), name)
+		ch <- prometheus.MustNewConstMetric(accountAvailableDesc, prometheus.GaugeValue, gaugeBool(account.available(now)), name)
+		circuit := account.Circuit(now)
+		for _, state := range []string{CircuitClosed, CircuitOpen, CircuitHalfOpen} {
+			ch <- prometheus.MustNewConstMetric(accountCircuitDesc, prometheus.GaugeValue, gaugeBool(circuit == state), name, state)
+		}
+		ch <- prometheus.MustNewConstMetric(accountInFlightDesc, prometheus.GaugeValue, float64(account.InFlight()), name)
+	}
+}
+
+// RegisterMetrics exposes the health of the pool's accounts on /metrics
+func RegisterMetrics(pool *AccountPool) error {
+	return metrics.Registry.Register(poolCollector{pool: pool})
+}
+
+func gaugeBool(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
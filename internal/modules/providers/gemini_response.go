@@ -0,0 +1,218 @@
+package providers
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+// The web app replaces rich content in the text with placeholder links and
+// ships the content elsewhere in the candidate
+var (
+	cardContentPattern     = regexp.MustCompile(`^http://googleusercontent\.com/card_content/\d+`)
+	generatedImagePattern  = regexp.MustCompile(`http://googleusercontent\.com/image_generation_content/\d+`)
+	placeholderLinkPattern = regexp.MustCompile(`^http://googleusercontent\.com/`)
+)
+
+// parseFrame extracts all candidates of a single StreamGenerate frame.
+// It reports false for frames that carry no candidate text (acks, metadata).
+//
+// Payload layout (payload = JSON string at item[2]):
+//
+//	payload[1]        [conversation ID, response ID]
+//	payload[4][i]     candidate i:
+//	  [0]             choice ID (rcid)
+//	  [1][0]          text
+//	  [2]             citation/source cards
+//	  [12][1]         web images
+//	  [12][7][0]      generated images
+//	  [22][0]         card content replacing a card_content placeholder text
+func parseFrame(frame string) (*Response, bool) {
+	var root []interface{}
+	if err := json.Unmarshal([]byte(frame), &root); err != nil {
+		return nil, false
+	}
+
+	for _, item := range root {
+		payloadStr, ok := stringAt(item, 2)
+		if !ok {
+			continue
+		}
+
+		var payload []interface{}
+		if err := json.Unmarshal([]byte(payloadStr), &payload); err != nil {
+			continue
+		}
+
+		var candidates []Candidate
+		for i, raw := range arrayAt(payload, 4) {
+			candidate, ok := parseCandidate(raw)
+			if !ok {
+				if i == 0 {
+					break
+				}
+				continue
+			}
+			candidates = append(candidates, candidate)
+		}
+		if len(candidates) == 0 {
+			continue
+		}
+
+		cid, _ := stringAt(payload, 1, 0)
+		rid, _ := stringAt(payload, 1, 1)
+		chosen := candidates[0]
+		return &Response{
+			Text:           chosen.Content,
+			Images:         chosen.Images,
+			Sources:        chosen.Sources,
+			Candidates:     candidates,
+			ChosenIndex:    0,
+			ConversationID: cid,
+			ResponseID:     rid,
+			Metadata: map[string]any{
+				"cid":  cid,
+				"rid":  rid,
+				"rcid": chosen.ID,
+			},
+		}, true
+	}
+	return nil, false
+}
+
+// parseCandidate extracts the text, images and sources of one candidate
+func parseCandidate(raw interface{}) (Candidate, bool) {
+	text, ok := stringAt(raw, 1, 0)
+	if !ok {
+		return Candidate{}, false
+	}
+	if cardContentPattern.MatchString(text) {
+		if card, ok := stringAt(raw, 22, 0); ok {
+			text = card
+		}
+	}
+
+	// Generated images are returned separately; drop their placeholders, which
+	// show up in the text before the images themselves are ready
+	if generatedImagePattern.MatchString(text) {
+		text = strings.TrimRight(generatedImagePattern.ReplaceAllString(text, ""), " \n")
+	}
+
+	candidate := Candidate{Content: text}
+	candidate.ID, _ = stringAt(raw, 0)
+
+	for _, img := range arrayAt(raw, 12, 1) {
+		url, ok := stringAt(img, 0, 0, 0)
+		if !ok {
+			continue
+		}
+		title, _ := stringAt(img, 7, 0)
+		alt, _ := stringAt(img, 0, 4)
+		width, height := imageSize(at(img, 0))
+		candidate.Images = append(candidate.Images, Image{
+			URL: url, Title: title, AltText: alt, Width: width, Height: height,
+		})
+	}
+
+	for i, img := range arrayAt(raw, 12, 7, 0) {
+		url, ok := stringAt(img, 0, 3, 3)
+		if !ok {
+			continue
+		}
+		title := "[Generated Image]"
+		if id := at(img, 3, 6); id != nil {
+			title = fmt.Sprintf("[Generated Image %v]", id)
+		}
+		alts := arrayAt(img, 3, 5)
+		alt := ""
+		if i < len(alts) {
+			alt, _ = alts[i].(string)
+		} else if len(alts) > 0 {
+			alt, _ = alts[0].(string)
+		}
+		width, height := imageSize(at(img, 0, 3))
+		candidate.Images = append(candidate.Images, Image{
+			URL: url, Title: title, AltText: alt, Width: width, Height: height, Generated: true,
+		})
+	}
+
+	candidate.Sources = collectSources(at(raw, 2), nil, map[string]bool{})
+	return candidate, true
+}
+
+// collectSources walks the citation cards and returns every linked page with
+// the first non-URL string next to it as its title. The card layout is not
+// stable, so this only relies on URLs and titles sharing an array.
+func collectSources(node interface{}, sources []Source, seen map[string]bool) []Source {
+	arr, ok := node.([]interface{})
+	if !ok {
+		return sources
+	}
+
+	var url, title string
+	for _, v := range arr {
+		s, ok := v.(string)
+		if !ok {
+			continue
+		}
+		switch {
+		case strings.HasPrefix(s, "http://") || strings.HasPrefix(s, "https://"):
+			if url == "" && !placeholderLinkPattern.MatchString(s) {
+				url = s
+			}
+		case title == "" && strings.TrimSpace(s) != "":
+			title = s
+		}
+	}
+	if url != "" && !seen[url] {
+		seen[url] = true
+		sources = append(sources, Source{URL: url, Title: title})
+	}
+
+	for _, v := range arr {
+		sources = collectSources(v, sources, seen)
+	}
+	return sources
+}
+
+// imageSize looks for a [width, height] pair among the direct children of an
+// image's info array; zero values mean the size is unknown
+func imageSize(node interface{}) (int, int) {
+	arr, _ := node.([]interface{})
+	for _, v := range arr {
+		pair, ok := v.([]interface{})
+		if !ok || len(pair) != 2 {
+			continue
+		}
+		w, wok := pair[0].(float64)
+		h, hok := pair[1].(float64)
+		if wok && hok && w > 0 && h > 0 {
+			return int(w), int(h)
+		}
+	}
+	return 0, 0
+}
+
+// at follows a path of array indexes through decoded JSON, returning nil when
+// any step is missing
+func at(v interface{}, path ...int) interface{} {
+	for _, i := range path {
+		arr, ok := v.([]interface{})
+		if !ok || i < 0 || i >= len(arr) {
+			return nil
+		}
+		v = arr[i]
+	}
+	return v
+}
+
+func stringAt(v interface{}, path ...int) (string, bool) {
+	s, ok := at(v, path...).(string)
+	return s, ok
+}
+
+func arrayAt(v interface{}, path ...int) []interface{} {
+	arr, _ := at(v, path...).([]interface{})
+	return arr
+}
@@ -1,6 +1,7 @@
 package providers
 
 import (
+	"bufio"
 	"compress/gzip"
 	"context"
 	"crypto/sha256"
@@ -13,51 +14,130 @@ import (
 	"os"
 	"path/filepath"
 	"regexp"
+	"slices"
 	"strings"
 	"sync"
 	"time"
 
 	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/commons/metrics"
+	"gemini-web-to-api/internal/commons/tracing"
 
 	"github.com/imroc/req/v3"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"go.uber.org/zap"
 )
 
 type Client struct {
+	name       string // account name, used in logs and session metadata
 	httpClient *req.Client
-	cookies    *CookieStore
+	endpoints  Endpoints
+	transport  http.RoundTripper // nil for http.DefaultTransport
+	registry   *ModelRegistry
+	account    configs.GeminiAccount // configured cookies, never modified
+	cookies    *CookieStore          // live cookies, rotated over time
 	at         string
-	mu         sync.RWMutex // protects: at, healthy
+	mu         sync.RWMutex // protects: at, healthy, initAttemptAt, cookiesRotatedAt, tokenRefreshedAt, lastErr, lastErrAt
 	healthy    bool
 	log        *zap.Logger
 
+	cookiesRotatedAt time.Time
+	tokenRefreshedAt time.Time
+	lastErr          error
+	lastErrAt        time.Time
+
 	autoRefresh     bool
 	refreshInterval time.Duration
+	refreshOnce     sync.Once
 	stopRefresh     chan struct{}
+	stopOnce        sync.Once // Close may be called more than once
 	maxRetries      int
+
+	initLock       chan struct{} // held while Init runs
+	initAttemptAt  time.Time
+	reinitRequests chan struct{}
+	superviseOnce  sync.Once
+	cookiesOnce    sync.Once // seeds the live cookies on the first Init
 }
 
 type CookieStore struct {
 	Secure1PSID   string    `json:"__Secure-1PSID"`
 	Secure1PSIDTS string    `json:"__Secure-1PSIDTS"`
 	UpdatedAt     time.Time `json:"updated_at"`
-	mu            sync.RWMutex
+	// other cookies set by Google, sent along with the two above
+	extra []*http.Cookie
+	// mu protects Secure1PSID, Secure1PSIDTS, UpdatedAt and extra
+	mu sync.RWMutex
+}
+
+// values returns the current cookies
+func (cs *CookieStore) values() (psid, psidts string) {
+	cs.mu.RLock()
+	defer cs.mu.RUnlock()
+	return cs.Secure1PSID, cs.Secure1PSIDTS
+}
+
+// set replaces the cookies
+func (cs *CookieStore) set(psid, psidts string) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.Secure1PSID, cs.Secure1PSIDTS = psid, psidts
+	cs.UpdatedAt = time.Now()
+}
+
+// add keeps cookies set by Google to send them with later requests
+func (cs *CookieStore) add(cookies ...*http.Cookie) {
+	cs.mu.Lock()
+	defer cs.mu.Unlock()
+	cs.addLocked(cookies...)
+}
+
+func (cs *CookieStore) addLocked(cookies ...*http.Cookie) {
+	for _, cookie := range cookies {
+		if cookie.Name == "__Secure-1PSID" || cookie.Name == "__Secure-1PSIDTS" {
+			continue
+		}
+		cs.extra = slices.DeleteFunc(cs.extra, func(kept *http.Cookie) bool { return kept.Name == cookie.Name })
+		cs.extra = append(cs.extra, cookie)
+	}
 }
 
 const (
 	defaultRefreshIntervalMinutes = 30
 )
 
-func NewClient(cfg *configs.Config, log *zap.Logger) *Client {
+// ErrNotInitialized is returned when a request is made before the session token was obtained
+var ErrNotInitialized = errors.New("client not initialized")
+
+// StatusError is returned when Gemini answers with a non-200 status
+type StatusError struct {
+	StatusCode int
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("generate failed with status: %d", e.StatusCode)
+}
+
+// NewClient creates a client for a single Google account. All of its traffic
+// goes to the upstream's endpoints through the upstream's transport.
+func NewClient(cfg *configs.Config, account configs.GeminiAccount, registry *ModelRegistry, upstream *Upstream, log *zap.Logger) *Client {
+	account.Secure1PSID = cleanCookie(account.Secure1PSID)
+	account.Secure1PSIDTS = cleanCookie(account.Secure1PSIDTS)
 	cookies := &CookieStore{
-		Secure1PSID:   cfg.Gemini.Secure1PSID,
-		Secure1PSIDTS: cfg.Gemini.Secure1PSIDTS,
+		Secure1PSID:   account.Secure1PSID,
+		Secure1PSIDTS: account.Secure1PSIDTS,
 		UpdatedAt:     time.Now(),
 	}
 
 	client := req.NewClient().
 		SetTimeout(2 * time.Minute).
 		SetCommonHeaders(DefaultHeaders)
+	if upstream.Transport != nil {
+		client.GetTransport().WrapRoundTrip(func(http.RoundTripper) http.RoundTripper {
+			return upstream.Transport
+		})
+	}
 
 	refreshIntervalMinutes := cfg.Gemini.RefreshInterval
 	if refreshIntervalMinutes <= 0 {
@@ -65,58 +145,67 @@ func NewClient(cfg *configs.Config, log *zap.Logger) *Client {
 	}
 
 	return &Client{
+		name:            account.Name,
 		httpClient:      client,
+		endpoints:       upstream.Endpoints,
+		transport:       upstream.Transport,
+		registry:        registry,
+		account:         account,
 		cookies:         cookies,
 		autoRefresh:     true,
 		refreshInterval: time.Duration(refreshIntervalMinutes) * time.Minute,
 		stopRefresh:     make(chan struct{}),
 		maxRetries:      cfg.Gemini.MaxRetries,
 		log:             log,
+		initLock:        make(chan struct{}, 1),
+		reinitRequests:  make(chan struct{}, 1),
 	}
 }
 
-func (c *Client) Init(ctx context.Context) error {
-	// Clean cookies
-	c.cookies.Secure1PSID = cleanCookie(c.cookies.Secure1PSID)
-	configPSIDTS := cleanCookie(c.cookies.Secure1PSIDTS) // Save original config value
-	c.cookies.Secure1PSIDTS = configPSIDTS
-
-	// Check if we should use cached cookies or clear cache
-	if c.cookies.Secure1PSID != "" {
-		cachedTS, err := c.LoadCachedCookies()
-		
-		// If config has a new PSIDTS that differs from cache, clear cache and use config
-		if configPSIDTS != "" && cachedTS != "" && configPSIDTS != cachedTS {
-			_ = c.ClearCookieCache()
-			// Keep using the config value (already set above)
-		} else if err == nil && cachedTS != "" && configPSIDTS == "" {
-			// Only use cache if config doesn't provide PSIDTS
-			c.cookies.Secure1PSIDTS = cachedTS
-			c.log.Info("Loaded __Secure-1PSIDTS from cache")
+func (c *Client) Init(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "gemini.init", tracing.AttrAccount.String(c.name))
+	defer func() { tracing.End(span, err) }()
+
+	// The first initialization starts from the configured cookies; later ones
+	// (re-initialization by the supervisor) keep the rotated live cookies
+	c.cookiesOnce.Do(func() {
+		psid, configPSIDTS := c.account.Secure1PSID, c.account.Secure1PSIDTS
+		psidts := configPSIDTS
+
+		// Check if we should use cached cookies or clear cache
+		if psid != "" {
+			cachedTS, err := loadCachedCookies(psid)
+
+			// If config has a new PSIDTS that differs from cache, clear cache and use config
+			if configPSIDTS != "" && cachedTS != "" && configPSIDTS != cachedTS {
+				_ = clearCookieCache(psid)
+			} else if err == nil && cachedTS != "" && configPSIDTS == "" {
+				// Only use cache if config doesn't provide PSIDTS
+				psidts = cachedTS
+				c.log.Info("Loaded __Secure-1PSIDTS from cache")
+			}
 		}
-	}
+		c.cookies.set(psid, psidts)
+	})
 
 	// Obtain PSIDTS via rotation if missing
-	if c.cookies.Secure1PSID != "" && c.cookies.Secure1PSIDTS == "" {
+	if psid, psidts := c.cookies.values(); psid != "" && psidts == "" {
 		c.log.Info("Only __Secure-1PSID provided, attempting to obtain __Secure-1PSIDTS via rotation...")
-		if err := c.RotateCookies(); err != nil {
+		if err := c.RotateCookies(ctx); err != nil {
 			c.log.Info("Rotation failed, proceeding with just __Secure-1PSID (might fail)", zap.String("error", err.Error()))
 		} else {
 			c.log.Info("Successfully obtained __Secure-1PSIDTS via rotation")
 		}
 	}
 
-	// Populate cookies
-	c.httpClient.SetCommonCookies(c.cookies.ToHTTPCookies()...)
-
 	// Get SNlM0e token
-	err := c.refreshSessionToken()
+	err = c.refreshSessionToken(ctx)
 	if err != nil {
 		c.log.Debug("Initial session token fetch failed, attempting cookie rotation", zap.Error(err))
 		// Try to rotate cookies and retry
-		if rotErr := c.RotateCookies(); rotErr == nil {
+		if rotErr := c.RotateCookies(ctx); rotErr == nil {
 			c.log.Debug("Cookie rotation succeeded, retrying session token fetch")
-			err = c.refreshSessionToken()
+			err = c.refreshSessionToken(ctx)
 		} else {
 			c.log.Debug("Cookie rotation failed", zap.Error(rotErr))
 		}
@@ -131,28 +220,36 @@ func (c *Client) Init(ctx context.Context) error {
 
 	c.log.Info("✅ Gemini client initialized successfully")
 
-	// 5. Start auto-refresh in background
+	// 5. Start auto-refresh in background (once, Init runs again on re-initialization)
 	if c.autoRefresh {
-		go c.startAutoRefresh()
+		c.refreshOnce.Do(func() { go c.startAutoRefresh() })
 	}
 
 	return nil
 }
 
-func (c *Client) refreshSessionToken() error {
+func (c *Client) refreshSessionToken(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "gemini.refresh_session_token", tracing.AttrAccount.String(c.name))
+	defer func() {
+		metrics.SessionTokenRefreshes.WithLabelValues(c.name, metrics.Result(err)).Inc()
+		c.recordError(err)
+		tracing.End(span, err)
+	}()
+
 	// 1. Initial hit to google.com to get extra cookies (NID, etc)
-	tmpClient := req.NewClient().
-		SetTimeout(30 * time.Second).
-		SetUserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
-	
-	resp1, err := tmpClient.R().Get("https://www.google.com/")
+	hClient := c.newHTTPClient(30 * time.Second)
+
+	warmup, _ := http.NewRequestWithContext(ctx, "GET", c.endpoints.Google, nil)
+	warmup.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
+	resp1, err := hClient.Do(warmup)
 	extraCookies := ""
 	if err == nil {
+		resp1.Body.Close()
 		parts := []string{}
 		for _, ck := range resp1.Cookies() {
 			parts = append(parts, fmt.Sprintf("%s=%s", ck.Name, ck.Value))
 			// Also sync to main client
-			c.httpClient.SetCommonCookies(ck)
+			c.cookies.add(ck)
 		}
 		if len(parts) > 0 {
 			extraCookies = strings.Join(parts, "; ") + "; "
@@ -160,8 +257,9 @@ func (c *Client) refreshSessionToken() error {
 	}
 
 	// 2. Prepare full cookie string
+	psid, psidts := c.cookies.values()
 	cookieStr := fmt.Sprintf("%s__Secure-1PSID=%s; __Secure-1PSIDTS=%s", 
-		extraCookies, c.cookies.Secure1PSID, c.cookies.Secure1PSIDTS)
+		extraCookies, psid, psidts)
 
 	commonHeaders := map[string]string{
 		"Accept":                    "text/html,application/xhtml+xml,application/xml;q=0.9,image/avif,image/webp,image/apng,*/*;q=0.8,application/signed-exchange;v=b3;q=0.7",
@@ -180,13 +278,6 @@ func (c *Client) refreshSessionToken() error {
 		"User-Agent":                "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
 	}
 
-	hClient := &http.Client{
-		Timeout: 30 * time.Second,
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return nil // follow redirects
-		},
-	}
-
 	// Helper to merge cookies into a map to avoid duplicates
 	mergeCookies := func(baseStr string, newCks []*http.Cookie) string {
 		m := make(map[string]string)
@@ -210,7 +301,7 @@ func (c *Client) refreshSessionToken() error {
 		return strings.Join(res, "; ")
 	}
 
-	req1, _ := http.NewRequest("GET", "https://gemini.google.com/?hl=en", nil)
+	req1, _ := http.NewRequestWithContext(ctx, "GET", c.endpoints.Home+"?hl=en", nil)
 	for k, v := range commonHeaders {
 		req1.Header.Set(k, v)
 	}
@@ -218,14 +309,12 @@ func (c *Client) refreshSessionToken() error {
 	resp1_direct, _ := hClient.Do(req1)
 	if resp1_direct != nil {
 		cookieStr = mergeCookies(cookieStr, resp1_direct.Cookies())
-		for _, ck := range resp1_direct.Cookies() {
-			c.httpClient.SetCommonCookies(ck)
-		}
+		c.cookies.add(resp1_direct.Cookies()...)
 		resp1_direct.Body.Close()
 	}
 
 	// 2. The main INIT hit
-	req2, _ := http.NewRequest("GET", EndpointInit+"?hl=en", nil)
+	req2, _ := http.NewRequestWithContext(ctx, "GET", c.endpoints.Init+"?hl=en", nil)
 	for k, v := range commonHeaders {
 		req2.Header.Set(k, v)
 	}
@@ -279,10 +368,23 @@ func (c *Client) refreshSessionToken() error {
 	c.mu.Lock()
 	c.at = matches[1]
 	c.healthy = true
+	c.tokenRefreshedAt = time.Now()
 	c.mu.Unlock()
 	return nil
 }
 
+// newHTTPClient returns a plain HTTP client on the upstream transport, for the
+// requests that manage their own cookie headers
+func (c *Client) newHTTPClient(timeout time.Duration) *http.Client {
+	return &http.Client{Transport: c.transport, Timeout: timeout}
+}
+
+// request returns a request on the upstream client carrying the current
+// cookies
+func (c *Client) request(ctx context.Context) *req.Request {
+	return c.httpClient.R().SetContext(ctx).SetCookies(c.cookies.ToHTTPCookies()...)
+}
+
 // startAutoRefresh periodically refreshes the PSIDTS cookie
 func (c *Client) startAutoRefresh() {
 	ticker := time.NewTicker(c.refreshInterval)
@@ -292,7 +394,8 @@ func (c *Client) startAutoRefresh() {
 		select {
 		case <-ticker.C:
 			c.log.Debug("Starting scheduled cookie refresh")
-			rotateErr := c.RotateCookies()
+			ctx, span := tracing.Start(context.Background(), "gemini.scheduled_refresh", tracing.AttrAccount.String(c.name))
+			rotateErr := c.RotateCookies(ctx)
 			if rotateErr != nil {
 				// Check if it's a 401/403 (cookies fully expired) — no point retrying session token
 				isCookieExpired := strings.Contains(rotateErr.Error(), "status 401") ||
@@ -306,13 +409,15 @@ func (c *Client) startAutoRefresh() {
 					c.mu.Lock()
 					c.healthy = false
 					c.mu.Unlock()
+					c.requestReinit()
+					tracing.End(span, rotateErr)
 					continue
 				}
 
 				// RotateCookies failed but NOT due to expired cookies (Google may not return new cookie every time)
 				// Fallback: try to refresh the session token (SNlM0e/at) to keep client alive
 				c.log.Warn("Cookie rotation failed, falling back to session token refresh", zap.Error(rotateErr))
-				if sessionErr := c.refreshSessionToken(); sessionErr != nil {
+				if sessionErr := c.refreshSessionToken(ctx); sessionErr != nil {
 					// Both methods failed — mark client as unhealthy so callers know
 					c.log.Error("Session token refresh also failed, marking client unhealthy",
 						zap.NamedError("rotation_error", rotateErr),
@@ -321,6 +426,7 @@ func (c *Client) startAutoRefresh() {
 					c.mu.Lock()
 					c.healthy = false
 					c.mu.Unlock()
+					c.requestReinit()
 				} else {
 					c.log.Info("Session token refreshed successfully after rotation failure")
 					// Ensure client is marked healthy since session token is valid
@@ -330,19 +436,32 @@ func (c *Client) startAutoRefresh() {
 				}
 			} else {
 				// Rotation succeeded — also refresh session token to keep SNlM0e/at up to date
-				if sessionErr := c.refreshSessionToken(); sessionErr != nil {
+				if sessionErr := c.refreshSessionToken(ctx); sessionErr != nil {
 					c.log.Warn("Cookie rotated but session token refresh failed", zap.Error(sessionErr))
 				} else {
 					c.log.Info("Cookie and session token refreshed successfully")
 				}
 			}
+			span.End()
 		case <-c.stopRefresh:
 			return
 		}
 	}
 }
 
-func (c *Client) RotateCookies() error {
+func (c *Client) RotateCookies(ctx context.Context) (err error) {
+	ctx, span := tracing.Start(ctx, "gemini.rotate_cookies", tracing.AttrAccount.String(c.name))
+	defer func() {
+		metrics.CookieRotations.WithLabelValues(c.name, metrics.Result(err)).Inc()
+		if err == nil {
+			c.mu.Lock()
+			c.cookiesRotatedAt = time.Now()
+			c.mu.Unlock()
+		}
+		c.recordError(err)
+		tracing.End(span, err)
+	}()
+
 	c.cookies.mu.Lock()
 	defer c.cookies.mu.Unlock()
 
@@ -359,16 +478,15 @@ func (c *Client) RotateCookies() error {
 
 	// Payload must be exactly this string
 	strBody := `[000,"-0000000000000000000"]`
-	req, _ := http.NewRequest("POST", EndpointRotateCookies, strings.NewReader(strBody))
+	req, _ := http.NewRequestWithContext(ctx, "POST", c.endpoints.RotateCookies, strings.NewReader(strBody))
 	
 	req.Header.Set("Content-Type", "application/json")
 	// Google often blocks requests with default Go-http-client User-Agent
 	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36")
 	req.Header.Set("Cookie", cookieStr)
 
-	c.log.Debug("Sending rotation request", zap.String("url", EndpointRotateCookies))
-	hClient := &http.Client{Timeout: 5 * time.Second}
-	resp, err := hClient.Do(req)
+	c.log.Debug("Sending rotation request", zap.String("url", c.endpoints.RotateCookies))
+	resp, err := c.newHTTPClient(5 * time.Second).Do(req)
 	if err != nil {
 		// Log as Info to avoid scary stacktraces in development mode for expected auth failures
 		c.log.Info("Rotation request failed (network/auth issue)", zap.String("error", err.Error()))
@@ -389,10 +507,10 @@ func (c *Client) RotateCookies() error {
 			c.cookies.UpdatedAt = time.Now()
 			found = true
 			// Save the new cookie to cache immediately
-			_ = c.SaveCachedCookies()
+			_ = c.saveCachedCookies(c.cookies.Secure1PSID, c.cookies.Secure1PSIDTS)
 		}
-		// Sync to req/v3 client for future calls
-		c.httpClient.SetCommonCookies(cookie)
+		// Keep for future calls
+		c.cookies.addLocked(cookie)
 	}
 
 	if found {
@@ -414,38 +532,44 @@ func (c *Client) GetCookies() *CookieStore {
 	}
 }
 
-func (c *Client) GenerateContent(ctx context.Context, prompt string, options ...GenerateOption) (*Response, error) {
-	config := &GenerateConfig{
-		Model: "gemini-pro", // default
-	}
+func (c *Client) GenerateContent(ctx context.Context, prompt string, options ...GenerateOption) (response *Response, err error) {
+	config := &GenerateConfig{}
 	for _, opt := range options {
 		opt(config)
 	}
 
-	// Read session token safely — short critical section, no lock held during HTTP call
-	c.mu.RLock()
-	at := c.at
-	c.mu.RUnlock()
+	ctx, span := c.startGenerateSpan(ctx, "gemini.generate", prompt, config)
+	defer func() {
+		if response != nil {
+			span.SetAttributes(tracing.AttrResponseSize.Int(len(response.Text)))
+		}
+		if errors.Is(err, ErrAuthExpired) {
+			c.invalidateSession(err)
+		}
+		c.recordError(err)
+		tracing.End(span, err)
+	}()
 
-	if at == "" {
-		return nil, errors.New("client not initialized")
+	model, err := c.registry.Resolve(config.Model)
+	if err != nil {
+		return nil, err
 	}
 
-	// Build request payload
-	inner := []interface{}{
-		[]interface{}{prompt},
-		nil,
-		nil,
+	// Initializes the client first when it has no session token
+	at, err := c.sessionToken(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	innerJSON, _ := json.Marshal(inner)
-	outer := []interface{}{nil, string(innerJSON)}
-	outerJSON, _ := json.Marshal(outer)
-
-	formData := map[string]string{
-		"at":    at,
-		"f.req": string(outerJSON),
+	message, err := c.buildMessagePart(ctx, prompt, config.Files)
+	if err != nil {
+		return nil, err
 	}
+	formData := buildGenerateForm(at, []interface{}{
+		message,
+		nil,
+		conversationMetadata(config.Conversation),
+	})
 
 	maxAttempts := c.maxRetries
 	if maxAttempts <= 0 {
@@ -457,43 +581,40 @@ func (c *Client) GenerateContent(ctx context.Context, prompt string, options ...
 	var lastErr error
 	for attempt := 1; attempt <= maxAttempts; attempt++ {
 		if attempt > 1 {
-			// Exponential backoff: 1s, 2s, 4s...
-			backoff := time.Duration(1<<uint(attempt-2)) * time.Second
-			c.log.Warn("Retrying GenerateContent",
-				zap.Int("attempt", attempt),
-				zap.Int("max_attempts", maxAttempts),
-				zap.Duration("backoff", backoff),
-				zap.Error(lastErr),
-			)
-			select {
-			case <-time.After(backoff):
-			case <-ctx.Done():
-				return nil, ctx.Err()
+			if err := c.waitBackoff(ctx, "GenerateContent", attempt, maxAttempts, lastErr); err != nil {
+				return nil, err
 			}
+			metrics.Retries.WithLabelValues(metrics.OperationGenerate, c.name).Inc()
 		}
 
+		attemptCtx, attemptSpan := tracing.Start(ctx, "gemini.generate.attempt", tracing.AttrAttempt.Int(attempt))
 		httpStart := time.Now()
-		resp, err := c.httpClient.R().
-			SetContext(ctx).
+		resp, err := c.request(attemptCtx).
+			SetHeaders(model.modelHeaders()).
 			SetFormData(formData).
 			SetQueryParam("at", at).
-			Post(EndpointGenerate)
+			Post(c.endpoints.Generate)
 
 		httpDuration := time.Since(httpStart)
+		metrics.ObserveUpstream(metrics.OperationGenerate, c.name, statusCode(resp), httpDuration)
+		endAttempt(attemptSpan, resp, err)
 		if err != nil {
+			lastErr = networkError(err)
+			if ctx.Err() != nil {
+				return nil, lastErr
+			}
 			c.log.Warn("Generate request failed, will retry",
 				zap.Error(err),
 				zap.Duration("http_duration", httpDuration),
 				zap.Int("attempt", attempt),
 			)
-			lastErr = err
 			continue
 		}
 
 		if resp.StatusCode != http.StatusOK {
-			lastErr = fmt.Errorf("generate failed with status: %d", resp.StatusCode)
-			// Only retry on 5xx (server errors), not 4xx (client errors)
-			if resp.StatusCode >= 500 {
+			lastErr = statusError(resp.StatusCode)
+			// Only retry on 5xx (server errors); rate limits and auth failures are for the pool
+			if errors.Is(lastErr, ErrNetwork) {
 				c.log.Warn("Server error, will retry",
 					zap.Int("status", resp.StatusCode),
 					zap.Int("attempt", attempt),
@@ -504,10 +625,18 @@ func (c *Client) GenerateContent(ctx context.Context, prompt string, options ...
 		}
 
 		parseStart := time.Now()
+		_, parseSpan := tracing.Start(ctx, "gemini.parse_response", attribute.Int("http.response.body.size", len(resp.String())))
 		result, parseErr := c.parseResponse(resp.String())
+		tracing.End(parseSpan, parseErr)
 		parseDuration := time.Since(parseStart)
+		metrics.ParseDuration.Observe(parseDuration.Seconds())
 
 		if parseErr != nil {
+			// Error frames and blocked replies are answers, only garbage is retried
+			if !errors.Is(parseErr, ErrMalformedResponse) {
+				return nil, parseErr
+			}
+			metrics.ParseFailures.WithLabelValues(c.name).Inc()
 			lastErr = parseErr
 			c.log.Warn("Failed to parse response, will retry",
 				zap.Error(parseErr),
@@ -537,24 +666,302 @@ func (c *Client) GenerateContent(ctx context.Context, prompt string, options ...
 	return nil, fmt.Errorf("after %d attempts: %w", maxAttempts, lastErr)
 }
 
-func (c *Client) StartChat(options ...ChatOption) ChatSession {
-	config := &ChatConfig{
-		Model: "gemini-pro",
+// GenerateContentStream sends the prompt to StreamGenerate and emits text deltas
+// as Gemini flushes each frame, instead of buffering the whole body first.
+// Retries only happen while establishing the stream; once the first byte has
+// been handed to the caller, failures are reported through StreamChunk.Err.
+func (c *Client) GenerateContentStream(ctx context.Context, prompt string, options ...GenerateOption) (stream <-chan StreamChunk, err error) {
+	config := &GenerateConfig{}
+	for _, opt := range options {
+		opt(config)
+	}
+
+	// Once the stream is established, readStream ends the span
+	ctx, span := c.startGenerateSpan(ctx, "gemini.generate_stream", prompt, config)
+	defer func() {
+		if err != nil {
+			if errors.Is(err, ErrAuthExpired) {
+				c.invalidateSession(err)
+			}
+			c.recordError(err)
+			tracing.End(span, err)
+		}
+	}()
+
+	model, err := c.registry.Resolve(config.Model)
+	if err != nil {
+		return nil, err
+	}
+
+	at, err := c.sessionToken(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	message, err := c.buildMessagePart(ctx, prompt, config.Files)
+	if err != nil {
+		return nil, err
+	}
+	formData := buildGenerateForm(at, []interface{}{
+		message,
+		nil,
+		conversationMetadata(config.Conversation),
+	})
+
+	maxAttempts := c.maxRetries
+	if maxAttempts <= 0 {
+		maxAttempts = 1
 	}
+
+	var lastErr error
+	for attempt := 1; attempt <= maxAttempts; attempt++ {
+		if attempt > 1 {
+			if err := c.waitBackoff(ctx, "GenerateContentStream", attempt, maxAttempts, lastErr); err != nil {
+				return nil, err
+			}
+			metrics.Retries.WithLabelValues(metrics.OperationGenerateStream, c.name).Inc()
+		}
+
+		attemptCtx, attemptSpan := tracing.Start(ctx, "gemini.generate_stream.attempt", tracing.AttrAttempt.Int(attempt))
+		httpStart := time.Now()
+		resp, err := c.request(attemptCtx).
+			DisableAutoReadResponse().
+			SetHeaders(model.modelHeaders()).
+			SetFormData(formData).
+			SetQueryParam("at", at).
+			Post(c.endpoints.Generate)
+		// Streams are timed until the response headers arrive
+		metrics.ObserveUpstream(metrics.OperationGenerateStream, c.name, statusCode(resp), time.Since(httpStart))
+		endAttempt(attemptSpan, resp, err)
+		if err != nil {
+			lastErr = networkError(err)
+			if ctx.Err() != nil {
+				return nil, lastErr
+			}
+			c.log.Warn("Stream request failed, will retry", zap.Error(err), zap.Int("attempt", attempt))
+			continue
+		}
+
+		if resp.StatusCode != http.StatusOK {
+			resp.Body.Close()
+			lastErr = statusError(resp.StatusCode)
+			if errors.Is(lastErr, ErrNetwork) {
+				c.log.Warn("Server error, will retry",
+					zap.Int("status", resp.StatusCode),
+					zap.Int("attempt", attempt),
+				)
+				continue
+			}
+			return nil, lastErr
+		}
+
+		chunks := make(chan StreamChunk)
+		go c.readStream(ctx, resp.Body, chunks, span)
+		return chunks, nil
+	}
+
+	c.log.Error("GenerateContentStream failed after all attempts",
+		zap.Int("attempts", maxAttempts),
+		zap.Error(lastErr),
+	)
+	return nil, fmt.Errorf("after %d attempts: %w", maxAttempts, lastErr)
+}
+
+// readStream parses StreamGenerate frames as they arrive and forwards the
+// difference against the previously seen cumulative text. The channel is
+// closed once the body is exhausted, an error occurs or ctx is cancelled.
+func (c *Client) readStream(ctx context.Context, body io.ReadCloser, chunks chan<- StreamChunk, span trace.Span) {
+	var last *Response
+	var streamErr error
+	defer func() {
+		if last != nil {
+			span.SetAttributes(tracing.AttrResponseSize.Int(len(last.Text)))
+		}
+		c.recordError(streamErr)
+		tracing.End(span, streamErr)
+	}()
+	defer close(chunks)
+	defer body.Close()
+
+	send := func(chunk StreamChunk) bool {
+		select {
+		case chunks <- chunk:
+			return true
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	start := time.Now()
+	frames := newFrameReader(body)
+	var unparsed []string
+	emitted, streaming := "", false
+	for {
+		frame, err := frames.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			streamErr = &UpstreamError{Kind: ErrNetwork, Err: fmt.Errorf("stream interrupted: %w", err)}
+			send(StreamChunk{Err: streamErr})
+			return
+		}
+
+		result, ok := parseFrame(frame)
+		if !ok {
+			unparsed = append(unparsed, frame)
+			continue
+		}
+		last = result
+
+		// Each frame carries the full text generated so far, so only the new
+		// suffix is forwarded, less an unfinished citation or link at its end.
+		// Text already sent is never sent again: a frame that rewrote it is
+		// skipped and later ones are compared against what was sent.
+		if !strings.HasPrefix(result.Text, emitted) {
+			c.log.Debug("Stream frame rewrote streamed text",
+				zap.Int("emitted_bytes", len(emitted)),
+				zap.Int("frame_bytes", len(result.Text)),
+			)
+			continue
+		}
+		stable := stableLength(result.Text, len(emitted))
+		delta := result.Text[len(emitted):stable]
+		emitted = result.Text[:stable]
+		if delta == "" {
+			continue
+		}
+		if !streaming {
+			streaming = true
+			span.AddEvent("first_token")
+		}
+		if !send(StreamChunk{Delta: delta}) {
+			streamErr = ctx.Err()
+			return
+		}
+	}
+
+	if last == nil {
+		streamErr = responseError(unparsed, "")
+		if errors.Is(streamErr, ErrMalformedResponse) {
+			metrics.ParseFailures.WithLabelValues(c.name).Inc()
+		}
+		send(StreamChunk{Err: streamErr})
+		return
+	}
+	if streamErr = blockedError(last); streamErr != nil {
+		send(StreamChunk{Err: streamErr})
+		return
+	}
+
+	// The held back tail is final now
+	if tail, ok := strings.CutPrefix(last.Text, emitted); !ok {
+		c.log.Warn("Reply rewrote text already streamed",
+			zap.Int("emitted_bytes", len(emitted)),
+			zap.Int("response_bytes", len(last.Text)),
+		)
+	} else if tail != "" && !send(StreamChunk{Delta: tail}) {
+		streamErr = ctx.Err()
+		return
+	}
+
+	c.log.Debug("GenerateContentStream finished",
+		zap.Duration("stream_duration", time.Since(start)),
+		zap.Int("response_bytes", len(last.Text)),
+	)
+	send(StreamChunk{Response: last})
+}
+
+// statusCode returns the HTTP status of resp, or 0 when no response was received
+func statusCode(resp *req.Response) int {
+	if resp == nil || resp.Response == nil {
+		return 0
+	}
+	return resp.StatusCode
+}
+
+// startGenerateSpan starts the span of a generate call on this account
+func (c *Client) startGenerateSpan(ctx context.Context, name, prompt string, config *GenerateConfig) (context.Context, trace.Span) {
+	return tracing.Start(ctx, name,
+		tracing.AttrAccount.String(c.name),
+		tracing.AttrModel.String(config.Model),
+		tracing.AttrPromptSize.Int(len(prompt)),
+		tracing.AttrFiles.Int(len(config.Files)),
+	)
+}
+
+// endAttempt ends the span of one upstream attempt with its HTTP outcome
+func endAttempt(span trace.Span, resp *req.Response, err error) {
+	if code := statusCode(resp); code > 0 {
+		span.SetAttributes(attribute.Int("http.response.status_code", code))
+		if err == nil && code != http.StatusOK {
+			err = &StatusError{StatusCode: code}
+		}
+	}
+	tracing.End(span, err)
+}
+
+// waitBackoff sleeps before a retry attempt using exponential backoff (1s, 2s, 4s...)
+func (c *Client) waitBackoff(ctx context.Context, op string, attempt, maxAttempts int, lastErr error) error {
+	backoff := time.Duration(1<<uint(attempt-2)) * time.Second
+	c.log.Warn("Retrying "+op,
+		zap.Int("attempt", attempt),
+		zap.Int("max_attempts", maxAttempts),
+		zap.Duration("backoff", backoff),
+		zap.Error(lastErr),
+	)
+	select {
+	case <-time.After(backoff):
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// conversationMetadata returns the [cid, rid, rcid] element that continues a
+// conversation, or nil to start a new one
+func conversationMetadata(metadata *SessionMetadata) []interface{} {
+	if metadata == nil || metadata.ConversationID == "" {
+		return nil
+	}
+	return []interface{}{metadata.ConversationID, metadata.ResponseID, metadata.ChoiceID}
+}
+
+// buildGenerateForm wraps the inner request array into StreamGenerate form data
+func buildGenerateForm(at string, inner []interface{}) map[string]string {
+	innerJSON, _ := json.Marshal(inner)
+	outer := []interface{}{nil, string(innerJSON)}
+	outerJSON, _ := json.Marshal(outer)
+
+	return map[string]string{
+		"at":    at,
+		"f.req": string(outerJSON),
+	}
+}
+
+func (c *Client) StartChat(options ...ChatOption) ChatSession {
+	config := &ChatConfig{}
 	for _, opt := range options {
 		opt(config)
 	}
 
+	// The session updates its metadata in place, so it works on a copy
+	var metadata *SessionMetadata
+	if config.Metadata != nil {
+		copied := *config.Metadata
+		metadata = &copied
+	}
+
 	return &GeminiChatSession{
 		client:   c,
 		model:    config.Model,
-		metadata: config.Metadata,
-		history:  []Message{},
+		metadata: metadata,
+		history:  append([]Message{}, config.History...),
 	}
 }
 
 func (c *Client) Close() error {
-	close(c.stopRefresh)
+	c.stopOnce.Do(func() { close(c.stopRefresh) })
 	c.mu.Lock()
 	c.healthy = false
 	c.mu.Unlock()
@@ -565,6 +972,11 @@ func (c *Client) GetName() string {
 	return "gemini"
 }
 
+// AccountName returns the name of the Google account this client serves
+func (c *Client) AccountName() string {
+	return c.name
+}
+
 func (c *Client) IsHealthy() bool {
 	c.mu.RLock()
 	defer c.mu.RUnlock()
@@ -573,8 +985,7 @@ func (c *Client) IsHealthy() bool {
 
 func (c *Client) ListModels() []ModelInfo {
 	var models []ModelInfo
-	// Assuming SupportedModels is defined in this package now
-	for _, m := range SupportedModels {
+	for _, m := range c.registry.List() {
 		if m.Provider == "gemini" {
 			models = append(models, m)
 		}
@@ -582,78 +993,79 @@ func (c *Client) ListModels() []ModelInfo {
 	return models
 }
 
-// parseResponse parses Gemini's response format
+// ResolveModel looks up a model by ID or alias in the registry
+func (c *Client) ResolveModel(id string) (*ModelInfo, error) {
+	return c.registry.Resolve(id)
+}
+
+// parseResponse parses a fully buffered StreamGenerate body. Every frame
+// carries the cumulative text, so the last parsable frame is the most complete.
 func (c *Client) parseResponse(text string) (*Response, error) {
-	lines := strings.Split(text, "\n")
-	for _, line := range lines {
-		line = strings.TrimSpace(line)
-		if line == "" {
-			continue
+	var result *Response
+	var unparsed []string
+	frames := newFrameReader(strings.NewReader(text))
+	for {
+		frame, err := frames.Next()
+		if err != nil {
+			break
+		}
+		if parsed, ok := parseFrame(frame); ok {
+			result = parsed
+		} else {
+			unparsed = append(unparsed, frame)
 		}
-		line = strings.TrimPrefix(line, ")]}'")
+	}
+	if result != nil {
+		return result, blockedError(result)
+	}
+	return nil, responseError(unparsed, text)
+}
 
-		var root []interface{}
-		if err := json.Unmarshal([]byte(line), &root); err == nil {
-			for _, item := range root {
-				itemArray, ok := item.([]interface{})
-				if !ok || len(itemArray) < 3 {
-					continue
-				}
+// frameReader splits a StreamGenerate body into its JSON frames. The body
+// starts with the )]}' anti-XSSI guard followed by repeated "<length>\n<json>"
+// pairs which Gemini flushes one at a time while generating.
+type frameReader struct {
+	r *bufio.Reader
+}
 
-				payloadStr, ok := itemArray[2].(string)
-				if !ok {
-					continue
-				}
+func newFrameReader(r io.Reader) *frameReader {
+	return &frameReader{r: bufio.NewReader(r)}
+}
 
-				var payload []interface{}
-				if err := json.Unmarshal([]byte(payloadStr), &payload); err != nil {
-					continue
-				}
+// Next blocks until a complete frame is available. It returns io.EOF once the
+// body is exhausted.
+func (f *frameReader) Next() (string, error) {
+	var buf strings.Builder
+	for {
+		line, err := f.r.ReadString('\n')
+		trimmed := strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), ")]}'"))
+
+		// Length prefixes are only meaningful between frames. The JSON itself
+		// never spans lines in practice, but keep accumulating until it is
+		// valid so a wrapped frame is not split into garbage.
+		if trimmed != "" && !(buf.Len() == 0 && isFrameLength(trimmed)) {
+			buf.WriteString(trimmed)
+			if json.Valid([]byte(buf.String())) {
+				return buf.String(), nil
+			}
+		}
 
-				if len(payload) > 4 {
-					candidates, ok := payload[4].([]interface{})
-					if ok && candidates != nil && len(candidates) > 0 {
-						firstCandidate, ok := candidates[0].([]interface{})
-						if ok && len(firstCandidate) >= 2 {
-							contentParts, ok := firstCandidate[1].([]interface{})
-							if ok && len(contentParts) > 0 {
-								resText, ok := contentParts[0].(string)
-								if ok {
-									// Extract conversation metadata if available
-									var cid, rid, rcid string
-									if len(firstCandidate) > 0 {
-										if id, ok := firstCandidate[0].(string); ok {
-											rcid = id
-										}
-									}
-									if len(payload) > 1 {
-										if id, ok := payload[1].(string); ok {
-											cid = id
-										}
-									}
-
-									return &Response{
-										Text: resText,
-										Metadata: map[string]any{
-											"cid":  cid,
-											"rid":  rid,
-											"rcid": rcid,
-										},
-									}, nil
-								}
-							}
-						}
-					}
-				}
+		if err != nil {
+			if err == io.EOF && buf.Len() > 0 {
+				return buf.String(), nil
 			}
+			return "", err
 		}
 	}
+}
 
-	sample := text
-	if len(sample) > 500 {
-		sample = sample[:500]
+func isFrameLength(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
 	}
-	return nil, fmt.Errorf("failed to parse response. Sample: %s", sample)
+	return true
 }
 
 func (cs *CookieStore) ToHTTPCookies() []*http.Cookie {
@@ -685,7 +1097,7 @@ func (cs *CookieStore) ToHTTPCookies() []*http.Cookie {
 			SameSite: http.SameSiteNoneMode,
 		})
 	}
-	return cookies
+	return append(cookies, cs.extra...)
 }
 
 func cleanCookie(v string) string {
@@ -698,12 +1110,16 @@ func cleanCookie(v string) string {
 
 // LoadCachedCookies attempts to read the saved 1PSIDTS from disk
 func (c *Client) LoadCachedCookies() (string, error) {
-	if c.cookies.Secure1PSID == "" {
+	psid, _ := c.cookies.values()
+	return loadCachedCookies(psid)
+}
+
+func loadCachedCookies(psid string) (string, error) {
+	if psid == "" {
 		return "", errors.New("no PSID available")
 	}
 
-	hash := sha256.Sum256([]byte(c.cookies.Secure1PSID))
-	filename := filepath.Join(".cookies", hex.EncodeToString(hash[:])+".txt")
+	filename := cookieCacheFile(psid)
 
 	data, err := os.ReadFile(filename)
 	if err != nil {
@@ -719,7 +1135,13 @@ func (c *Client) LoadCachedCookies() (string, error) {
 
 // SaveCachedCookies writes the current 1PSIDTS to disk
 func (c *Client) SaveCachedCookies() error {
-	if c.cookies.Secure1PSID == "" || c.cookies.Secure1PSIDTS == "" {
+	return c.saveCachedCookies(c.cookies.values())
+}
+
+// saveCachedCookies writes psidts to disk; RotateCookies calls it while
+// holding the cookie lock
+func (c *Client) saveCachedCookies(psid, psidts string) error {
+	if psid == "" || psidts == "" {
 		return nil
 	}
 
@@ -728,10 +1150,9 @@ func (c *Client) SaveCachedCookies() error {
 		return err
 	}
 
-	hash := sha256.Sum256([]byte(c.cookies.Secure1PSID))
-	filename := filepath.Join(".cookies", hex.EncodeToString(hash[:])+".txt")
+	filename := cookieCacheFile(psid)
 
-	err := os.WriteFile(filename, []byte(c.cookies.Secure1PSIDTS), 0600)
+	err := os.WriteFile(filename, []byte(psidts), 0600)
 	if err == nil {
 		c.log.Debug("Saved __Secure-1PSIDTS to local cache for future use", zap.String("file", filename))
 	} else {
@@ -742,14 +1163,22 @@ func (c *Client) SaveCachedCookies() error {
 
 // ClearCookieCache deletes the cached cookie file for the current PSID
 func (c *Client) ClearCookieCache() error {
-	if c.cookies.Secure1PSID == "" {
+	psid, _ := c.cookies.values()
+	return clearCookieCache(psid)
+}
+
+// cookieCacheFile returns the cache file of the cookies of psid
+func cookieCacheFile(psid string) string {
+	hash := sha256.Sum256([]byte(psid))
+	return filepath.Join(".cookies", hex.EncodeToString(hash[:])+".txt")
+}
+
+func clearCookieCache(psid string) error {
+	if psid == "" {
 		return nil
 	}
 
-	hash := sha256.Sum256([]byte(c.cookies.Secure1PSID))
-	filename := filepath.Join(".cookies", hex.EncodeToString(hash[:])+".txt")
-
-	err := os.Remove(filename)
+	err := os.Remove(cookieCacheFile(psid))
 	if err != nil && !os.IsNotExist(err) {
 		return err
 	}
@@ -759,10 +1188,12 @@ func (c *Client) ClearCookieCache() error {
 
 const (
 EndpointGoogle        = "https://www.google.com"
+EndpointHome          = "https://gemini.google.com/"
 EndpointInit          = "https://gemini.google.com/app"
 EndpointGenerate      = "https://gemini.google.com/_/BardChatUi/data/assistant.lamda.BardFrontendService/StreamGenerate"
 EndpointRotateCookies = "https://accounts.google.com/RotateCookies"
 EndpointBatchExec     = "https://gemini.google.com/_/BardChatUi/data/batchexecute"
+EndpointUpload        = "https://content-push.googleapis.com/upload"
 )
 
 var DefaultHeaders = map[string]string{
@@ -0,0 +1,417 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"gemini-web-to-api/internal/commons/configs"
+	utils "gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/pkg/fakegemini"
+
+	"go.uber.org/zap"
+)
+
+// newTestClient returns an initialized client talking to fake through its
+// transport, with retries and the background refresh disabled
+func newTestClient(t *testing.T, fake *fakegemini.Server) *Client {
+	t.Helper()
+	// The cookie cache is written to the working directory
+	t.Chdir(t.TempDir())
+
+	cfg := &configs.Config{}
+	registry, err := NewModelRegistry(cfg, zap.NewNop())
+	if err != nil {
+		t.Fatal(err)
+	}
+	upstream := &Upstream{Endpoints: NewEndpoints(mockBaseURL), Transport: fake.Transport()}
+	account := configs.GeminiAccount{Name: "test", Secure1PSID: "psid", Secure1PSIDTS: "psidts"}
+
+	client := NewClient(cfg, account, registry, upstream, zap.NewNop())
+	client.autoRefresh = false
+	t.Cleanup(func() { client.Close() })
+
+	if err := client.Init(context.Background()); err != nil {
+		t.Fatalf("Init: %v", err)
+	}
+	return client
+}
+
+func TestInitFetchesSessionToken(t *testing.T) {
+	fake := fakegemini.NewServer()
+	client := newTestClient(t, fake)
+
+	if !client.IsHealthy() {
+		t.Fatal("client is not healthy after Init")
+	}
+	at, err := client.sessionToken(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if at != fake.Token {
+		t.Errorf("session token = %q, want %q", at, fake.Token)
+	}
+}
+
+func TestInitRejectedCookies(t *testing.T) {
+	fake := fakegemini.NewServer()
+	fake.PSID = "another account"
+	t.Chdir(t.TempDir())
+
+	cfg := &configs.Config{}
+	registry, _ := NewModelRegistry(cfg, zap.NewNop())
+	upstream := &Upstream{Endpoints: NewEndpoints(mockBaseURL), Transport: fake.Transport()}
+	client := NewClient(cfg, configs.GeminiAccount{Name: "test", Secure1PSID: "psid", Secure1PSIDTS: "psidts"}, registry, upstream, zap.NewNop())
+	client.autoRefresh = false
+	defer client.Close()
+
+	if err := client.Init(context.Background()); err == nil {
+		t.Fatal("Init succeeded with cookies of a signed-out account")
+	}
+	if client.IsHealthy() {
+		t.Error("client is healthy after a failed Init")
+	}
+}
+
+func TestGenerateContentParsesReply(t *testing.T) {
+	fake := fakegemini.NewServer()
+	client := newTestClient(t, fake)
+
+	fake.Enqueue(fakegemini.Reply{
+		Text:    "Here are two cats",
+		Drafts:  []string{"Two cats, as requested"},
+		Sources: []fakegemini.Source{{URL: "https://example.com/cats", Title: "Cats"}},
+		Images: []fakegemini.Image{
+			{URL: "https://example.com/cat.jpg", Title: "A cat", AltText: "cat"},
+			{URL: "https://lh3.googleusercontent.com/gg/generated", AltText: "drawn cat", Generated: true},
+		},
+	})
+
+	response, err := client.GenerateContent(context.Background(), "show me cats")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if response.Text != "Here are two cats" {
+		t.Errorf("Text = %q", response.Text)
+	}
+	if response.ConversationID == "" || response.ResponseID == "" {
+		t.Errorf("conversation IDs not parsed: %q, %q", response.ConversationID, response.ResponseID)
+	}
+	if len(response.Candidates) != 2 || response.Candidates[1].Content != "Two cats, as requested" {
+		t.Errorf("Candidates = %+v", response.Candidates)
+	}
+	if len(response.Sources) != 1 || response.Sources[0].URL != "https://example.com/cats" || response.Sources[0].Title != "Cats" {
+		t.Errorf("Sources = %+v", response.Sources)
+	}
+
+	var web, generated int
+	for _, img := range response.Images {
+		if img.Generated {
+			generated++
+		} else {
+			web++
+			if img.URL != "https://example.com/cat.jpg" || img.Title != "A cat" {
+				t.Errorf("web image = %+v", img)
+			}
+		}
+	}
+	if web != 1 || generated != 1 {
+		t.Errorf("Images = %+v, want one web and one generated image", response.Images)
+	}
+
+	if got := fake.Requests(); len(got) != 1 || got[0].Prompt != "show me cats" {
+		t.Errorf("requests = %+v", got)
+	}
+}
+
+func TestGenerateContentStreamDeltas(t *testing.T) {
+	tests := []struct {
+		name   string
+		reply  fakegemini.Reply
+		deltas []string
+		text   string
+	}{
+		{
+			name:   "incremental",
+			reply:  fakegemini.Reply{Text: "one two three"},
+			deltas: []string{"one ", "two ", "three"},
+			text:   "one two three",
+		},
+		{
+			name:   "citation rewritten while open",
+			reply:  fakegemini.Reply{Steps: []string{"See [", "See [1](http://ex", "See [example](http://example.com) for", "See [example](http://example.com) for details."}},
+			deltas: []string{"See ", "[example](http://example.com) for", " details."},
+			text:   "See [example](http://example.com) for details.",
+		},
+		{
+			name:   "open citation at the end",
+			reply:  fakegemini.Reply{Steps: []string{"Done", "Done [1"}},
+			deltas: []string{"Done", " ", "[1"},
+			text:   "Done [1",
+		},
+		{
+			name:   "repeated frame",
+			reply:  fakegemini.Reply{Steps: []string{"Hello", "Hello", "Hello world"}},
+			deltas: []string{"Hello", " world"},
+			text:   "Hello world",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := fakegemini.NewServer()
+			client := newTestClient(t, fake)
+			fake.Enqueue(tt.reply)
+
+			deltas, final := collectStream(t, client)
+			if strings.Join(deltas, "|") != strings.Join(tt.deltas, "|") {
+				t.Errorf("deltas = %q, want %q", deltas, tt.deltas)
+			}
+			if final == nil || final.Text != tt.text {
+				t.Fatalf("final response = %+v, want text %q", final, tt.text)
+			}
+			if streamed := strings.Join(deltas, ""); streamed != final.Text {
+				t.Errorf("streamed text = %q, final text = %q", streamed, final.Text)
+			}
+		})
+	}
+}
+
+func TestGenerateContentStreamNeverResends(t *testing.T) {
+	fake := fakegemini.NewServer()
+	client := newTestClient(t, fake)
+	fake.Enqueue(fakegemini.Reply{Steps: []string{"café au lait", "cafè au lait, please"}})
+
+	deltas, final := collectStream(t, client)
+	if strings.Join(deltas, "|") != "café au lait" {
+		t.Errorf("deltas = %q, want only the text before the rewrite", deltas)
+	}
+	if final == nil || final.Text != "cafè au lait, please" {
+		t.Errorf("final response = %+v", final)
+	}
+}
+
+// collectStream streams a reply from client and returns its deltas and final
+// response
+func collectStream(t *testing.T, client *Client) ([]string, *Response) {
+	t.Helper()
+	chunks, err := client.GenerateContentStream(context.Background(), "hi")
+	if err != nil {
+		t.Fatal(err)
+	}
+	var deltas []string
+	var final *Response
+	for chunk := range chunks {
+		switch {
+		case chunk.Err != nil:
+			t.Fatal(chunk.Err)
+		case chunk.Response != nil:
+			final = chunk.Response
+		default:
+			deltas = append(deltas, chunk.Delta)
+		}
+	}
+	return deltas, final
+}
+
+func TestStableLength(t *testing.T) {
+	tests := []struct {
+		text string
+		want string
+	}{
+		{text: "plain text", want: "plain text"},
+		{text: "see [1", want: "see "},
+		{text: "see [1]", want: "see "},
+		{text: "see [1] and", want: "see [1] and"},
+		{text: "a [link](http://ex", want: "a "},
+		{text: "a [link](http://example.com) b [", want: "a [link](http://example.com) b "},
+		{text: "x[" + strings.Repeat("y", maxHeldBytes+1), want: "x[" + strings.Repeat("y", maxHeldBytes+1)},
+	}
+	for _, tt := range tests {
+		if got := tt.text[:stableLength(tt.text, 0)]; got != tt.want {
+			t.Errorf("stable part of %q = %q, want %q", tt.text, got, tt.want)
+		}
+	}
+}
+
+func TestCompleteDeltasSendsUnstreamedTail(t *testing.T) {
+	chunks := make(chan StreamChunk, 3)
+	chunks <- StreamChunk{Delta: "Hello"}
+	chunks <- StreamChunk{Delta: " wor"}
+	chunks <- StreamChunk{Response: &Response{Text: "Hello world"}}
+	close(chunks)
+
+	var text strings.Builder
+	var final *Response
+	for chunk := range CompleteDeltas(context.Background(), chunks) {
+		if chunk.Response != nil {
+			final = chunk.Response
+			continue
+		}
+		if final != nil {
+			t.Fatal("delta after the final response")
+		}
+		text.WriteString(chunk.Delta)
+	}
+	if text.String() != "Hello world" {
+		t.Errorf("streamed text = %q, want %q", text.String(), "Hello world")
+	}
+	if final == nil {
+		t.Error("final response not forwarded")
+	}
+}
+
+func TestRotateCookies(t *testing.T) {
+	fake := fakegemini.NewServer()
+	client := newTestClient(t, fake)
+	before := client.GetCookies().Secure1PSIDTS
+
+	if err := client.RotateCookies(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	after := client.GetCookies().Secure1PSIDTS
+	if after == before || !strings.HasPrefix(after, "sidts-") {
+		t.Errorf("__Secure-1PSIDTS = %q after rotation, was %q", after, before)
+	}
+	if fake.Rotations() != 1 {
+		t.Errorf("rotations = %d, want 1", fake.Rotations())
+	}
+	if client.Health().CookiesRefreshedAt.IsZero() {
+		t.Error("rotation time not recorded")
+	}
+
+	// The rotated cookie is used by later requests
+	if _, err := client.GenerateContent(context.Background(), "hi"); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReinitKeepsRotatedCookies(t *testing.T) {
+	fake := fakegemini.NewServer()
+	client := newTestClient(t, fake)
+
+	if err := client.RotateCookies(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	rotated := client.GetCookies().Secure1PSIDTS
+
+	if err := client.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := client.GetCookies().Secure1PSIDTS; got != rotated {
+		t.Errorf("__Secure-1PSIDTS = %q after re-initialization, want the rotated %q", got, rotated)
+	}
+	if client.account.Secure1PSIDTS != "psidts" {
+		t.Errorf("configured __Secure-1PSIDTS changed to %q", client.account.Secure1PSIDTS)
+	}
+}
+
+// TestRotateCookiesDuringInit is meant for go test -race. Init itself is
+// never run concurrently, the supervisor serializes it.
+func TestRotateCookiesDuringInit(t *testing.T) {
+	fake := fakegemini.NewServer()
+	client := newTestClient(t, fake)
+
+	var wg sync.WaitGroup
+	wg.Add(3)
+	go func() {
+		defer wg.Done()
+		for range 4 {
+			_ = client.Init(context.Background())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 4 {
+			_ = client.RotateCookies(context.Background())
+		}
+	}()
+	go func() {
+		defer wg.Done()
+		for range 4 {
+			_ = client.GetCookies()
+			_ = client.SaveCachedCookies()
+		}
+	}()
+	wg.Wait()
+
+	if fake.Rotations() < 4 {
+		t.Errorf("rotations = %d, want at least 4", fake.Rotations())
+	}
+}
+
+func TestCloseTwice(t *testing.T) {
+	client := newTestClient(t, fakegemini.NewServer())
+	if err := client.Init(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	// The cleanup closes the client again
+	if err := client.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if client.IsHealthy() {
+		t.Error("closed client reports healthy")
+	}
+}
+
+func TestGenerateContentClassifiesErrors(t *testing.T) {
+	tests := []struct {
+		name      string
+		reply     fakegemini.Reply
+		kind      error
+		unhealthy bool
+	}{
+		{name: "429", reply: fakegemini.Reply{Status: http.StatusTooManyRequests}, kind: ErrRateLimited},
+		{name: "401", reply: fakegemini.Reply{Status: http.StatusUnauthorized}, kind: ErrAuthExpired, unhealthy: true},
+		{name: "usage limit", reply: fakegemini.Reply{ErrorCode: errorCodeUsageLimit}, kind: ErrRateLimited},
+		{name: "503", reply: fakegemini.Reply{Status: http.StatusServiceUnavailable}, kind: ErrNetwork},
+		{name: "empty reply", reply: fakegemini.Reply{Text: ""}, kind: ErrContentBlocked},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fake := fakegemini.NewServer()
+			client := newTestClient(t, fake)
+			fake.Enqueue(tt.reply)
+
+			_, err := client.GenerateContent(context.Background(), "hi")
+			if !errors.Is(err, tt.kind) {
+				t.Fatalf("error = %v, want %v", err, tt.kind)
+			}
+			if client.IsHealthy() == tt.unhealthy {
+				t.Errorf("healthy = %v after %v", client.IsHealthy(), err)
+			}
+		})
+	}
+}
+
+func TestSurfaceErrorInfo(t *testing.T) {
+	rateLimited := statusError(http.StatusTooManyRequests)
+	tests := []struct {
+		surface string
+		err     error
+		status  int
+		errType string
+	}{
+		{surface: "openai", err: rateLimited, status: http.StatusTooManyRequests, errType: "rate_limit_error"},
+		{surface: "claude", err: rateLimited, status: 529, errType: "overloaded_error"},
+		{surface: "gemini", err: rateLimited, status: http.StatusTooManyRequests, errType: "rate_limit_error"},
+		{surface: "claude", err: statusError(http.StatusUnauthorized), status: 529, errType: "overloaded_error"},
+		{surface: "openai", err: statusError(http.StatusUnauthorized), status: http.StatusServiceUnavailable, errType: "server_error"},
+		{surface: "openai", err: utils.InvalidRequestf("unsupported tool type: %s", "web"), status: http.StatusBadRequest, errType: "invalid_request_error"},
+		{surface: "claude", err: fmt.Errorf("input[0]: %w", utils.InvalidRequestf("invalid type")), status: http.StatusBadRequest, errType: "invalid_request_error"},
+		{surface: "gemini", err: utils.InvalidRequestf("empty content"), status: http.StatusBadRequest, errType: "invalid_request_error"},
+		{surface: "openai", err: errors.New("boom"), status: http.StatusInternalServerError, errType: "api_error"},
+	}
+	for _, tt := range tests {
+		info := SurfaceErrorInfo(tt.surface, tt.err)
+		if info.Status != tt.status || info.Type != tt.errType {
+			t.Errorf("SurfaceErrorInfo(%s, %v) = %+v, want %d %s", tt.surface, tt.err, info, tt.status, tt.errType)
+		}
+	}
+}
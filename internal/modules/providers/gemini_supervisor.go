@@ -0,0 +1,157 @@
+package providers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+const (
+	// Background re-initialization backoff, doubled after every failure
+	reinitMinBackoff = 5 * time.Second
+	reinitMaxBackoff = 5 * time.Minute
+
+	// reinitThrottle is the minimum time between two initializations started
+	// by requests, so a dead upstream is not hit by every incoming request
+	reinitThrottle = 10 * time.Second
+)
+
+// Start initializes the client and supervises it: when Init fails, or the
+// session is later invalidated, it is retried in the background with jittered
+// backoff until it succeeds. The first error is returned for logging.
+func (c *Client) Start(ctx context.Context) error {
+	c.superviseOnce.Do(func() { go c.superviseInit() })
+
+	err := c.reinit(ctx)
+	if err != nil {
+		c.requestReinit()
+	}
+	return err
+}
+
+// superviseInit re-runs Init after a backoff whenever requestReinit is
+// called, until it succeeds or the client is closed
+func (c *Client) superviseInit() {
+	for {
+		select {
+		case <-c.reinitRequests:
+		case <-c.stopRefresh:
+			return
+		}
+
+		// Requests may still initialize the client on demand while waiting
+		backoff := reinitBackoff(1)
+		for attempt := 1; ; attempt++ {
+			select {
+			case <-time.After(backoff):
+			case <-c.stopRefresh:
+				return
+			}
+
+			err := c.reinit(context.Background())
+			if err == nil {
+				break
+			}
+			backoff = reinitBackoff(attempt + 1)
+			c.log.Warn("Gemini client initialization failed, retrying in background",
+				zap.Int("attempt", attempt),
+				zap.Duration("backoff", backoff),
+				zap.Error(err),
+			)
+		}
+	}
+}
+
+// reinitBackoff returns the delay before the next background attempt, with
+// jitter so accounts failing together do not retry in lockstep
+func reinitBackoff(attempt int) time.Duration {
+	backoff := reinitMaxBackoff
+	if attempt < 10 {
+		backoff = min(reinitMinBackoff<<(attempt-1), reinitMaxBackoff)
+	}
+	return backoff/2 + rand.N(backoff/2)
+}
+
+// requestReinit wakes the supervisor without waiting for it
+func (c *Client) requestReinit() {
+	select {
+	case c.reinitRequests <- struct{}{}:
+	default: // already pending
+	}
+}
+
+// reinit runs Init unless the client became healthy while waiting for
+// another initialization to finish
+func (c *Client) reinit(ctx context.Context) error {
+	select {
+	case c.initLock <- struct{}{}:
+		defer func() { <-c.initLock }()
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+
+	if c.IsHealthy() {
+		return nil
+	}
+	c.mu.Lock()
+	c.initAttemptAt = time.Now()
+	c.mu.Unlock()
+	return c.Init(ctx)
+}
+
+// Revive initializes an unhealthy client on behalf of a request, at most once
+// per reinitThrottle
+func (c *Client) Revive(ctx context.Context) error {
+	c.mu.RLock()
+	healthy, lastAttempt := c.healthy, c.initAttemptAt
+	c.mu.RUnlock()
+
+	if healthy {
+		return nil
+	}
+	if time.Since(lastAttempt) < reinitThrottle {
+		return ErrNotInitialized
+	}
+	return c.reinit(ctx)
+}
+
+// sessionToken returns the SNlM0e token, initializing the client first when
+// it has none
+func (c *Client) sessionToken(ctx context.Context) (string, error) {
+	c.mu.RLock()
+	at := c.at
+	c.mu.RUnlock()
+	if at != "" {
+		return at, nil
+	}
+
+	if err := c.Revive(ctx); err != nil {
+		if errors.Is(err, ErrNotInitialized) {
+			return "", err
+		}
+		return "", fmt.Errorf("%w: %w", ErrNotInitialized, err)
+	}
+
+	c.mu.RLock()
+	defer c.mu.RUnlock()
+	if c.at == "" {
+		return "", ErrNotInitialized
+	}
+	return c.at, nil
+}
+
+// invalidateSession drops a session token that Gemini rejected and starts a
+// full re-initialization in the background
+func (c *Client) invalidateSession(err error) {
+	c.mu.Lock()
+	c.at = ""
+	c.healthy = false
+	c.mu.Unlock()
+
+	c.log.Warn("Gemini rejected the session, re-initializing", zap.Error(err))
+	c.requestReinit()
+}
@@ -0,0 +1,73 @@
+package providers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"gemini-web-to-api/internal/commons/metrics"
+	"gemini-web-to-api/internal/commons/tracing"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+// uploadPushID is the content-push feed the web app uploads attachments to
+const uploadPushID = "feeds/mcudyrk2a4khkz"
+
+// uploadFile uploads an attachment and returns the reference StreamGenerate expects
+func (c *Client) uploadFile(ctx context.Context, file File) (id string, err error) {
+	ctx, span := tracing.Start(ctx, "gemini.upload",
+		tracing.AttrAccount.String(c.name),
+		attribute.String("gemini.file.mime_type", file.MimeType),
+		attribute.Int("gemini.file.size", len(file.Data)),
+	)
+	defer func() {
+		c.recordError(err)
+		tracing.End(span, err)
+	}()
+
+	start := time.Now()
+	resp, err := c.request(ctx).
+		SetHeader("Push-ID", uploadPushID).
+		SetFileBytes("file", file.Name, file.Data).
+		Post(c.endpoints.Upload)
+	metrics.ObserveUpstream(metrics.OperationUpload, c.name, statusCode(resp), time.Since(start))
+	if err != nil {
+		return "", fmt.Errorf("failed to upload %s: %w", file.Name, networkError(err))
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to upload %s: %w", file.Name, statusError(resp.StatusCode))
+	}
+
+	id = strings.TrimSpace(resp.String())
+	if id == "" {
+		return "", fmt.Errorf("failed to upload %s: empty upload reference", file.Name)
+	}
+	c.log.Debug("Uploaded attachment",
+		zap.String("name", file.Name),
+		zap.String("mime_type", file.MimeType),
+		zap.Int("bytes", len(file.Data)),
+	)
+	return id, nil
+}
+
+// buildMessagePart uploads the attachments and returns the message element of
+// the StreamGenerate request: [prompt] or [prompt, 0, null, [[[ref], name]...]]
+func (c *Client) buildMessagePart(ctx context.Context, prompt string, files []File) ([]interface{}, error) {
+	if len(files) == 0 {
+		return []interface{}{prompt}, nil
+	}
+
+	refs := make([]interface{}, 0, len(files))
+	for _, file := range files {
+		id, err := c.uploadFile(ctx, file)
+		if err != nil {
+			return nil, err
+		}
+		refs = append(refs, []interface{}{[]interface{}{id}, file.Name})
+	}
+	return []interface{}{prompt, 0, nil, refs}, nil
+}
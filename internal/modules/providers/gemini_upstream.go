@@ -0,0 +1,99 @@
+package providers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/pkg/fakegemini"
+
+	"go.uber.org/zap"
+)
+
+// mockBaseURL is the host the in-process fake Gemini backend answers on in mock mode
+const mockBaseURL = "http://fakegemini.local"
+
+// mockFrameDelay paces the fake's streamed frames so mock replies visibly stream
+const mockFrameDelay = 20 * time.Millisecond
+
+// Endpoints are the upstream URLs a Client talks to
+type Endpoints struct {
+	Google        string // www.google.com, visited for the NID cookies
+	Home          string // gemini.google.com, visited before the app page
+	Init          string // app page carrying the SNlM0e session token
+	Generate      string
+	RotateCookies string
+	BatchExec     string
+	Upload        string
+}
+
+// DefaultEndpoints returns the production Google endpoints
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		Google:        EndpointGoogle + "/",
+		Home:          EndpointHome,
+		Init:          EndpointInit,
+		Generate:      EndpointGenerate,
+		RotateCookies: EndpointRotateCookies,
+		BatchExec:     EndpointBatchExec,
+		Upload:        EndpointUpload,
+	}
+}
+
+// NewEndpoints serves every endpoint from one base URL, keeping the production
+// paths, so a recorded or fake Gemini server can stand in for all Google hosts
+func NewEndpoints(baseURL string) Endpoints {
+	base := strings.TrimRight(baseURL, "/")
+	withPath := func(endpoint string) string {
+		u, err := url.Parse(endpoint)
+		if err != nil {
+			return base
+		}
+		return base + u.Path
+	}
+
+	defaults := DefaultEndpoints()
+	return Endpoints{
+		Google:        withPath(defaults.Google),
+		Home:          withPath(defaults.Home),
+		Init:          withPath(defaults.Init),
+		Generate:      withPath(defaults.Generate),
+		RotateCookies: withPath(defaults.RotateCookies),
+		BatchExec:     withPath(defaults.BatchExec),
+		Upload:        withPath(defaults.Upload),
+	}
+}
+
+// Upstream is where clients send their traffic. Transport, when set, carries
+// every upstream request, including cookie rotation and session token fetches;
+// nil uses the default transport.
+type Upstream struct {
+	Endpoints Endpoints
+	Transport http.RoundTripper
+}
+
+// NewUpstream targets an in-process fake Gemini backend in mock mode,
+// GEMINI_BASE_URL when set, otherwise Google
+func NewUpstream(cfg *configs.Config, log *zap.Logger) *Upstream {
+	if cfg.Gemini.Mock {
+		log.Warn("Mock mode: replies come from the built-in fake Gemini backend, not Google")
+		fake := fakegemini.NewServer()
+		fake.FrameDelay = mockFrameDelay
+		return &Upstream{Endpoints: NewEndpoints(mockBaseURL), Transport: fake.Transport()}
+	}
+	if cfg.Gemini.BaseURL != "" {
+		return &Upstream{Endpoints: NewEndpoints(cfg.Gemini.BaseURL)}
+	}
+	return &Upstream{Endpoints: DefaultEndpoints()}
+}
+
+// host returns the host the endpoints are served from
+func (e Endpoints) host() string {
+	u, err := url.Parse(e.Generate)
+	if err != nil {
+		return ""
+	}
+	return strings.ToLower(u.Hostname())
+}
@@ -1,6 +1,10 @@
 package providers
 
-import "context"
+import (
+	"context"
+
+	"gemini-web-to-api/internal/commons/utils"
+)
 
 // Provider defines the interface that all AI providers must implement
 type Provider interface {
@@ -9,6 +13,9 @@ type Provider interface {
 	
 	// GenerateContent generates a single response
 	GenerateContent(ctx context.Context, prompt string, options ...GenerateOption) (*Response, error)
+
+	// GenerateContentStream generates a response and emits text deltas as they arrive
+	GenerateContentStream(ctx context.Context, prompt string, options ...GenerateOption) (<-chan StreamChunk, error)
 	
 	// StartChat creates a new chat session
 	StartChat(options ...ChatOption) ChatSession
@@ -30,6 +37,10 @@ type Provider interface {
 type ChatSession interface {
 	// SendMessage sends a message and returns the response
 	SendMessage(ctx context.Context, message string, options ...GenerateOption) (*Response, error)
+
+	// SendMessageStream sends a message and emits text deltas as they arrive.
+	// The session is updated when the final Response has been received.
+	SendMessageStream(ctx context.Context, message string, options ...GenerateOption) (<-chan StreamChunk, error)
 	
 	// GetMetadata returns session metadata for persistence
 	GetMetadata() *SessionMetadata
@@ -41,15 +52,26 @@ type ChatSession interface {
 	Clear()
 }
 
-// Response represents a provider's response
+// Response represents a provider's response. Text, Images and Sources belong to
+// the chosen candidate; Candidates holds every draft the web app produced.
 type Response struct {
-	Text          string              `json:"text"`
-	Images        []Image             `json:"images,omitempty"`
-	Candidates    []Candidate         `json:"candidates,omitempty"`
-	Metadata      map[string]any      `json:"metadata,omitempty"`
-	ChosenIndex   int                 `json:"chosen_index"`
-	ConversationID string             `json:"conversation_id,omitempty"`
-	ResponseID    string              `json:"response_id,omitempty"`
+	Text           string         `json:"text"`
+	Images         []Image        `json:"images,omitempty"`
+	Sources        []Source       `json:"sources,omitempty"`
+	Candidates     []Candidate    `json:"candidates,omitempty"`
+	Metadata       map[string]any `json:"metadata,omitempty"`
+	ChosenIndex    int            `json:"chosen_index"`
+	ConversationID string         `json:"conversation_id,omitempty"`
+	ResponseID     string         `json:"response_id,omitempty"`
+}
+
+// StreamChunk is a single update emitted by GenerateContentStream.
+// Delta holds the text generated since the previous chunk. The last chunk
+// on the channel carries either the complete Response or a terminal Err.
+type StreamChunk struct {
+	Delta    string
+	Response *Response
+	Err      error
 }
 
 // Message represents a single message in conversation
@@ -61,17 +83,26 @@ type Message struct {
 
 // Image represents an image in the response
 type Image struct {
-	URL         string `json:"url"`
-	Title       string `json:"title,omitempty"`
-	AltText     string `json:"alt_text,omitempty"`
-	Width       int    `json:"width,omitempty"`
-	Height      int    `json:"height,omitempty"`
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	AltText   string `json:"alt_text,omitempty"`
+	Width     int    `json:"width,omitempty"`
+	Height    int    `json:"height,omitempty"`
+	Generated bool   `json:"generated,omitempty"` // created by the model rather than found on the web
+}
+
+// Source is a web page cited by a response
+type Source struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
 }
 
 // Candidate represents an alternative response
 type Candidate struct {
-	ID      string `json:"id"`
-	Content string `json:"content"`
+	ID      string   `json:"id"`
+	Content string   `json:"content"`
+	Images  []Image  `json:"images,omitempty"`
+	Sources []Source `json:"sources,omitempty"`
 }
 
 // SessionMetadata contains information to restore a session
@@ -89,9 +120,20 @@ type GenerateOption func(*GenerateConfig)
 // GenerateConfig holds generation configuration
 type GenerateConfig struct {
 	Model       string
-	Files       []string
+	Files       []File
 	Temperature float64
 	MaxTokens   int
+
+	// Conversation continues an existing Gemini conversation instead of
+	// starting a new one; set by ChatSession
+	Conversation *SessionMetadata
+}
+
+// File is an attachment (image, PDF, ...) uploaded along with the prompt
+type File struct {
+	Name     string // file name including an extension matching MimeType
+	MimeType string
+	Data     []byte
 }
 
 // ChatOption configures chat session behavior
@@ -101,6 +143,7 @@ type ChatOption func(*ChatConfig)
 type ChatConfig struct {
 	Model    string
 	Metadata *SessionMetadata
+	History  []Message
 }
 
 // WithModel sets the model to use
@@ -110,13 +153,22 @@ func WithModel(model string) GenerateOption {
 	}
 }
 
-// WithFiles adds files to the request
-func WithFiles(files []string) GenerateOption {
+// WithFiles attaches files to the request
+func WithFiles(files []File) GenerateOption {
 	return func(c *GenerateConfig) {
 		c.Files = files
 	}
 }
 
+// WithAttachments attaches decoded API attachments to the request
+func WithAttachments(attachments []utils.Attachment) GenerateOption {
+	return func(c *GenerateConfig) {
+		for _, a := range attachments {
+			c.Files = append(c.Files, File{Name: a.Name, MimeType: a.MimeType, Data: a.Data})
+		}
+	}
+}
+
 // WithChatModel sets the model for chat session
 func WithChatModel(model string) ChatOption {
 	return func(c *ChatConfig) {
@@ -130,3 +182,10 @@ func WithChatMetadata(metadata *SessionMetadata) ChatOption {
 		c.Metadata = metadata
 	}
 }
+
+// WithChatHistory restores the history of a previous chat session
+func WithChatHistory(history []Message) ChatOption {
+	return func(c *ChatConfig) {
+		c.History = history
+	}
+}
@@ -1,32 +1,174 @@
 package providers
 
-// ModelInfo contains basic information about an AI model
+import (
+	_ "embed"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"gemini-web-to-api/internal/commons/configs"
+
+	"go.uber.org/zap"
+	"gopkg.in/yaml.v3"
+)
+
+// ErrModelNotFound is returned when a request names a model that is not in the registry
+var ErrModelNotFound = errors.New("model not found")
+
+// modelHeader is the header the Gemini web app uses to select a model for StreamGenerate
+const modelHeader = "x-goog-ext-525001261-jspb"
+
+// defaultModelsFile is the registry used when MODELS_FILE is not set
+//
+//go:embed models.yaml
+var defaultModelsFile []byte
+
+// ModelInfo contains information about an AI model exposed by the proxy
 type ModelInfo struct {
-	ID       string `json:"id"`
-	Created  int64  `json:"created"`
-	OwnedBy  string `json:"owned_by"`
-	Provider string `json:"provider"` // "gemini", "claude", etc.
-}
-
-// SupportedModels is the central registry of all models supported by the system.
-// In the future, this could be loaded from a configuration file or database.
-var SupportedModels = []ModelInfo{
-	{
-		ID:       "gemini-1.5-pro",
-		Created:  1715644800, // May 14, 2024
-		OwnedBy:  "google",
-		Provider: "gemini",
-	},
-	{
-		ID:       "gemini-1.5-flash",
-		Created:  1715644800,
-		OwnedBy:  "google",
-		Provider: "gemini",
-	},
-	{
-		ID:       "gpt-4o",
-		Created:  1715558400, // May 13, 2024
-		OwnedBy:  "openai-alias",
-		Provider: "gemini", // Served via Gemini proxy
-	},
+	ID            string            `json:"id" yaml:"id"`
+	DisplayName   string            `json:"display_name,omitempty" yaml:"display_name"`
+	Created       int64             `json:"created" yaml:"created"`
+	OwnedBy       string            `json:"owned_by" yaml:"owned_by"`
+	Provider      string            `json:"provider" yaml:"provider"` // "gemini", "claude", etc.
+	ContextWindow int               `json:"context_window,omitempty" yaml:"context_window"`
+	Capabilities  ModelCapabilities `json:"capabilities" yaml:"capabilities"`
+	Aliases       []string          `json:"aliases,omitempty" yaml:"aliases"`
+
+	// Upstream is the web app's internal model identifier, as sent by the model
+	// picker, or the ID of another registry model to reuse its identifier.
+	// Empty means the request is served by the account's default model.
+	Upstream string `json:"upstream,omitempty" yaml:"upstream"`
+}
+
+// ModelCapabilities describes which features a model supports
+type ModelCapabilities struct {
+	Vision   bool `json:"vision" yaml:"vision"`
+	Tools    bool `json:"tools" yaml:"tools"`
+	Thinking bool `json:"thinking" yaml:"thinking"`
+}
+
+// modelsFile is the on-disk layout of a registry file
+type modelsFile struct {
+	Models []ModelInfo `json:"models" yaml:"models"`
+}
+
+// ModelRegistry is the central registry of all models supported by the system.
+// It is loaded once at startup from MODELS_FILE, or from the built-in defaults.
+type ModelRegistry struct {
+	models []ModelInfo
+}
+
+// NewModelRegistry loads the registry configured by MODELS_FILE
+func NewModelRegistry(cfg *configs.Config, log *zap.Logger) (*ModelRegistry, error) {
+	if cfg.ModelsFile == "" {
+		return ParseModelRegistry(defaultModelsFile, "models.yaml")
+	}
+
+	registry, err := LoadModelRegistry(cfg.ModelsFile)
+	if err != nil {
+		return nil, err
+	}
+	log.Info("Loaded model registry", zap.String("file", cfg.ModelsFile), zap.Int("models", len(registry.models)))
+	return registry, nil
+}
+
+// LoadModelRegistry reads a YAML or JSON registry file
+func LoadModelRegistry(filename string) (*ModelRegistry, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read models file: %w", err)
+	}
+	return ParseModelRegistry(data, filename)
+}
+
+// ParseModelRegistry decodes a registry; the format is chosen by the file extension
+func ParseModelRegistry(data []byte, filename string) (*ModelRegistry, error) {
+	var file modelsFile
+	var err error
+	if strings.EqualFold(filepath.Ext(filename), ".json") {
+		err = json.Unmarshal(data, &file)
+	} else {
+		err = yaml.Unmarshal(data, &file)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid models file %s: %w", filename, err)
+	}
+
+	byID := make(map[string]ModelInfo, len(file.Models))
+	for i, m := range file.Models {
+		if m.ID == "" {
+			return nil, fmt.Errorf("invalid models file %s: model #%d has no id", filename, i+1)
+		}
+		if _, exists := byID[m.ID]; exists {
+			return nil, fmt.Errorf("invalid models file %s: duplicate model id %q", filename, m.ID)
+		}
+		if m.Provider == "" {
+			file.Models[i].Provider = "gemini"
+		}
+		if m.DisplayName == "" {
+			file.Models[i].DisplayName = m.ID
+		}
+		byID[m.ID] = m
+	}
+
+	// Resolve upstream references to other models
+	for i, m := range file.Models {
+		if target, ok := byID[m.Upstream]; ok && target.ID != m.ID {
+			file.Models[i].Upstream = target.Upstream
+		}
+	}
+
+	return &ModelRegistry{models: file.Models}, nil
+}
+
+// List returns all registered models
+func (r *ModelRegistry) List() []ModelInfo {
+	models := make([]ModelInfo, len(r.models))
+	copy(models, r.models)
+	return models
+}
+
+// Resolve looks up a model by ID or alias. Aliases may be glob patterns such as
+// "claude-*sonnet*". An empty ID selects the account's default model; unknown
+// IDs return an error wrapping ErrModelNotFound.
+func (r *ModelRegistry) Resolve(id string) (*ModelInfo, error) {
+	if id == "" {
+		return &ModelInfo{Provider: "gemini"}, nil
+	}
+
+	name := strings.ToLower(id)
+	for i := range r.models {
+		if strings.ToLower(r.models[i].ID) == name {
+			return &r.models[i], nil
+		}
+	}
+	for i := range r.models {
+		for _, alias := range r.models[i].Aliases {
+			if strings.ToLower(alias) == name {
+				return &r.models[i], nil
+			}
+		}
+	}
+	for i := range r.models {
+		for _, alias := range r.models[i].Aliases {
+			if matched, _ := path.Match(strings.ToLower(alias), name); matched {
+				return &r.models[i], nil
+			}
+		}
+	}
+	return nil, fmt.Errorf("%w: %s", ErrModelNotFound, id)
+}
+
+// modelHeaders returns the request headers that select the model upstream
+func (m *ModelInfo) modelHeaders() map[string]string {
+	if m.Upstream == "" {
+		return nil
+	}
+	return map[string]string{
+		modelHeader: fmt.Sprintf(`[1,null,null,null,"%s",null,null,0,[4]]`, m.Upstream),
+	}
 }
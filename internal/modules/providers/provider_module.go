@@ -9,14 +9,18 @@ import (
 
 var Module = fx.Options(
 	fx.Provide(NewProviderManager),
+	fx.Provide(NewModelRegistry),
+	fx.Provide(NewUpstream),
+	fx.Provide(NewAccountPool),
 	fx.Invoke(RegisterProvider),
+	fx.Invoke(RegisterMetrics),
 )
 
-func RegisterProvider(pm *ProviderManager, c *Client, log *zap.Logger) {
-	pm.Register("gemini", c)
+func RegisterProvider(pm *ProviderManager, pool *AccountPool, log *zap.Logger) {
+	pm.Register("gemini", pool)
 
 	// Initialize specifically this provider
-	if err := c.Init(context.Background()); err != nil {
+	if err := pool.Init(context.Background()); err != nil {
 		log.Error("Gemini provider initialization failed (will retry in background)", zap.Error(err))
 	}
 
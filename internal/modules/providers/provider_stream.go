@@ -0,0 +1,112 @@
+package providers
+
+import (
+	"context"
+	"strings"
+)
+
+// maxHeldBytes bounds the unfinished tail held back from a stream, so a stray
+// "[" in the reply does not stall the rest of it
+const maxHeldBytes = 512
+
+// CompleteDeltas forwards the chunks of a stream. Before the final Response it
+// sends a delta with any part of the reply text that no delta carried, so a
+// client building the reply from deltas always gets all of it.
+func CompleteDeltas(ctx context.Context, chunks <-chan StreamChunk) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		send := func(chunk StreamChunk) bool {
+			select {
+			case out <- chunk:
+				return true
+			case <-ctx.Done():
+				return false
+			}
+		}
+
+		var streamed strings.Builder
+		for chunk := range chunks {
+			streamed.WriteString(chunk.Delta)
+			if chunk.Response != nil {
+				// Text already sent cannot be taken back, so a reply that
+				// rewrote it gets no tail
+				if tail, ok := strings.CutPrefix(chunk.Response.Text, streamed.String()); ok && tail != "" {
+					streamed.WriteString(tail)
+					if !send(StreamChunk{Delta: tail}) {
+						return
+					}
+				}
+			}
+			if !send(chunk) {
+				return
+			}
+		}
+	}()
+	return out
+}
+
+// stableLength returns how much of a frame's text can be streamed. Gemini
+// rewrites [n] citations and markdown links as it completes them, so one still
+// open at the end of the text is held back until a later frame closes it.
+// Scanning starts at from, the length already streamed.
+func stableLength(text string, from int) int {
+	for i := from; i < len(text); i++ {
+		if text[i] != '[' || len(text)-i > maxHeldBytes {
+			continue
+		}
+		end := bracketEnd(text[i:])
+		if end < 0 {
+			return i
+		}
+		i += end - 1
+	}
+	return len(text)
+}
+
+// bracketEnd returns the length of the [text] or [text](url) at the start of
+// s, or -1 while more text may still change it
+func bracketEnd(s string) int {
+	closing := strings.IndexByte(s, ']')
+	if closing < 0 || closing == len(s)-1 {
+		return -1 // unclosed, or a link target may follow
+	}
+	if s[closing+1] != '(' {
+		return closing + 1
+	}
+	paren := strings.IndexByte(s[closing+1:], ')')
+	if paren < 0 {
+		return -1
+	}
+	return closing + 1 + paren + 1
+}
+
+// watchStream forwards a stream, passing each chunk to observe (if set) first.
+// Once the stream ends it calls done with its outcome: nil after the final
+// Response, the error of an error chunk, or the error of ctx when the stream
+// was abandoned.
+func watchStream(ctx context.Context, chunks <-chan StreamChunk, observe func(StreamChunk), done func(error)) <-chan StreamChunk {
+	out := make(chan StreamChunk)
+	go func() {
+		defer close(out)
+		var outcome error
+		defer func() { done(outcome) }()
+		for chunk := range chunks {
+			if chunk.Err != nil {
+				outcome = chunk.Err
+			}
+			if observe != nil {
+				observe(chunk)
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				if chunk.Response == nil && chunk.Err == nil {
+					outcome = ctx.Err()
+				}
+				return
+			}
+		}
+	}()
+	return out
+}
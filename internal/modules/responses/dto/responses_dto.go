@@ -0,0 +1,166 @@
+package dto
+
+import "encoding/json"
+
+// CreateResponseRequest represents an OpenAI Responses API request
+type CreateResponseRequest struct {
+	Model              string            `json:"model"`
+	Input              json.RawMessage   `json:"input" swaggertype:"object"` // string or array of input items
+	Instructions       string            `json:"instructions,omitempty"`
+	PreviousResponseID string            `json:"previous_response_id,omitempty"`
+	Stream             bool              `json:"stream,omitempty"`
+	Store              *bool             `json:"store,omitempty"` // defaults to true; unstored responses cannot be chained
+	Tools              []Tool            `json:"tools,omitempty"`
+	ToolChoice         interface{}       `json:"tool_choice,omitempty"` // "auto", "none", "required" or {"type":"function","name":...}
+	ParallelToolCalls  *bool             `json:"parallel_tool_calls,omitempty"`
+	Temperature        float32           `json:"temperature,omitempty"`
+	MaxOutputTokens    int               `json:"max_output_tokens,omitempty"`
+	Metadata           map[string]string `json:"metadata,omitempty"`
+}
+
+// Tool represents a function the model may call
+type Tool struct {
+	Type        string      `json:"type"` // "function"
+	Name        string      `json:"name,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Parameters  interface{} `json:"parameters,omitempty"` // JSON schema
+	Strict      *bool       `json:"strict,omitempty"`
+}
+
+// InputItem is an element of the input array: a message, a function call
+// made in an earlier turn, or the output of that call
+type InputItem struct {
+	Type string `json:"type,omitempty"` // "message" (default), "function_call" or "function_call_output"
+
+	// message items
+	Role    string          `json:"role,omitempty"`                         // user, assistant, system or developer
+	Content json.RawMessage `json:"content,omitempty" swaggertype:"object"` // string or array of content parts
+
+	// function_call and function_call_output items
+	CallID    string          `json:"call_id,omitempty"`
+	Name      string          `json:"name,omitempty"`
+	Arguments string          `json:"arguments,omitempty"`
+	Output    json.RawMessage `json:"output,omitempty" swaggertype:"object"` // string or array of content parts
+}
+
+// InputContent is a part of an input message
+type InputContent struct {
+	Type     string `json:"type"` // input_text, output_text, input_image or input_file
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"image_url,omitempty"`
+	FileData string `json:"file_data,omitempty"`
+	FileID   string `json:"file_id,omitempty"`
+	Filename string `json:"filename,omitempty"`
+}
+
+// Response represents a Responses API response object
+type Response struct {
+	ID                 string            `json:"id"`
+	Object             string            `json:"object"` // "response"
+	CreatedAt          int64             `json:"created_at"`
+	Status             string            `json:"status"` // in_progress, completed or failed
+	Model              string            `json:"model"`
+	Output             []OutputItem      `json:"output"`
+	Instructions       *string           `json:"instructions"`
+	PreviousResponseID *string           `json:"previous_response_id"`
+	Tools              []Tool            `json:"tools"`
+	ToolChoice         interface{}       `json:"tool_choice"`
+	ParallelToolCalls  bool              `json:"parallel_tool_calls"`
+	Store              bool              `json:"store"`
+	Metadata           map[string]string `json:"metadata"`
+	Usage              *Usage            `json:"usage"`
+	Error              *ResponseError    `json:"error"`
+}
+
+// OutputItem is an element of the response output: an assistant message or a function call
+type OutputItem struct {
+	Type   string `json:"type"` // "message" or "function_call"
+	ID     string `json:"id"`
+	Status string `json:"status"` // in_progress or completed
+
+	// message items
+	Role    string          `json:"role,omitempty"`
+	Content []OutputContent `json:"content,omitempty"`
+
+	// function_call items
+	CallID    string `json:"call_id,omitempty"`
+	Name      string `json:"name,omitempty"`
+	Arguments string `json:"arguments,omitempty"`
+}
+
+// MarshalJSON emits only the fields of the item's type, so messages always
+// carry "content" and function calls always carry "arguments"
+func (o OutputItem) MarshalJSON() ([]byte, error) {
+	if o.Type == "function_call" {
+		return json.Marshal(struct {
+			Type      string `json:"type"`
+			ID        string `json:"id"`
+			Status    string `json:"status"`
+			CallID    string `json:"call_id"`
+			Name      string `json:"name"`
+			Arguments string `json:"arguments"`
+		}{o.Type, o.ID, o.Status, o.CallID, o.Name, o.Arguments})
+	}
+	content := o.Content
+	if content == nil {
+		content = []OutputContent{}
+	}
+	return json.Marshal(struct {
+		Type    string          `json:"type"`
+		ID      string          `json:"id"`
+		Status  string          `json:"status"`
+		Role    string          `json:"role"`
+		Content []OutputContent `json:"content"`
+	}{o.Type, o.ID, o.Status, o.Role, content})
+}
+
+// OutputContent is a part of an output message
+type OutputContent struct {
+	Type        string       `json:"type"` // "output_text"
+	Text        string       `json:"text"`
+	Annotations []Annotation `json:"annotations"`
+}
+
+// Annotation cites a web page used for the text
+type Annotation struct {
+	Type       string `json:"type"` // "url_citation"
+	URL        string `json:"url"`
+	Title      string `json:"title"`
+	StartIndex int    `json:"start_index"`
+	EndIndex   int    `json:"end_index"`
+}
+
+// Usage represents the token usage of a response
+type Usage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+	TotalTokens  int `json:"total_tokens"`
+}
+
+// ResponseError describes why a response failed
+type ResponseError struct {
+	Code    string `json:"code"`
+	Message string `json:"message"`
+}
+
+// DeletedResponse is returned when a stored response is deleted
+type DeletedResponse struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // "response.deleted"
+	Deleted bool   `json:"deleted"`
+}
+
+// StreamEvent represents a Responses API streaming event
+type StreamEvent struct {
+	Type           string         `json:"type"` // e.g. response.created, response.output_text.delta
+	SequenceNumber int            `json:"sequence_number"`
+	Response       *Response      `json:"response,omitempty"`      // response.created/in_progress/completed/failed
+	OutputIndex    *int           `json:"output_index,omitempty"`  // item and content events
+	ItemID         string         `json:"item_id,omitempty"`       // content and argument events
+	ContentIndex   *int           `json:"content_index,omitempty"` // content events
+	Item           *OutputItem    `json:"item,omitempty"`          // response.output_item.added/done
+	Part           *OutputContent `json:"part,omitempty"`          // response.content_part.added/done
+	Delta          string         `json:"delta,omitempty"`         // text and argument deltas
+	Text           *string        `json:"text,omitempty"`          // response.output_text.done
+	Arguments      *string        `json:"arguments,omitempty"`     // response.function_call_arguments.done
+}
@@ -0,0 +1,306 @@
+package responses
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gemini-web-to-api/internal/commons/models"
+	utils "gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/providers"
+	"gemini-web-to-api/internal/modules/responses/dto"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+type ResponsesController struct {
+	service *ResponsesService
+	log     *zap.Logger
+}
+
+func NewResponsesController(service *ResponsesService) *ResponsesController {
+	return &ResponsesController{
+		service: service,
+		log:     zap.NewNop(),
+	}
+}
+
+// SetLogger sets the logger for this handler
+func (h *ResponsesController) SetLogger(log *zap.Logger) {
+	h.log = log
+}
+
+// HandleCreateResponse accepts requests in OpenAI Responses format
+// @Summary Create Response (OpenAI)
+// @Description Generates a model response. Pass previous_response_id to continue the Gemini conversation of a stored response with only the new input. Set "stream": true to receive Responses API Server-Sent Events.
+// @Tags OpenAI
+// @Accept json
+// @Produce json
+// @Produce text/event-stream
+// @Param request body dto.CreateResponseRequest true "Create Response Request"
+// @Success 200 {object} dto.Response
+// @Failure 400 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /openai/v1/responses [post]
+func (h *ResponsesController) HandleCreateResponse(c fiber.Ctx) error {
+	var req dto.CreateResponseRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorToResponse(fmt.Errorf("invalid request body: %w", err), "invalid_request_error"))
+	}
+
+	if req.Stream {
+		return h.handleCreateResponseStream(c, req)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+	defer cancel()
+
+	response, err := h.service.CreateResponse(ctx, req)
+	if err != nil {
+		return h.respondError(c, err, req.Model)
+	}
+	return c.JSON(response)
+}
+
+// handleCreateResponseStream streams Responses API events over SSE: the response
+// is announced, every output item is opened, filled with deltas and closed, and
+// response.completed carries the final response
+func (h *ResponsesController) handleCreateResponseStream(c fiber.Ctx, req dto.CreateResponseRequest) error {
+	// The stream outlives this handler, so its context is cancelled by the body writer
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+
+	stream, err := h.service.CreateResponseStream(ctx, req)
+	if err != nil {
+		cancel()
+		return h.respondError(c, err, req.Model)
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("Transfer-Encoding", "chunked")
+
+	c.RequestCtx().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		sequence := 0
+		send := func(event dto.StreamEvent) error {
+			event.SequenceNumber = sequence
+			sequence++
+			return utils.SendSSEChunk(w, h.log, event.Type, event)
+		}
+
+		response := stream.Response
+		if send(dto.StreamEvent{Type: "response.created", Response: &response}) != nil ||
+			send(dto.StreamEvent{Type: "response.in_progress", Response: &response}) != nil {
+			return
+		}
+
+		// Text goes to an open message item; a function call closes it, so text
+		// after a call starts a new message
+		var output []dto.OutputItem
+		var text strings.Builder
+		message, contentIndex := -1, 0
+		openMessage := func() error {
+			item := dto.OutputItem{Type: "message", ID: newID("msg_"), Status: "in_progress", Role: "assistant"}
+			output = append(output, item)
+			message = len(output) - 1
+			part := NewOutputText("", nil)
+			if err := send(dto.StreamEvent{Type: "response.output_item.added", OutputIndex: &message, Item: &item}); err != nil {
+				return err
+			}
+			return send(dto.StreamEvent{Type: "response.content_part.added", ItemID: item.ID, OutputIndex: &message, ContentIndex: &contentIndex, Part: &part})
+		}
+		closeMessage := func(sources []providers.Source) error {
+			index, item := message, &output[message]
+			message = -1
+			content := text.String()
+			text.Reset()
+			part := NewOutputText(content, sources)
+			item.Status, item.Content = "completed", []dto.OutputContent{part}
+
+			if err := send(dto.StreamEvent{Type: "response.output_text.done", ItemID: item.ID, OutputIndex: &index, ContentIndex: &contentIndex, Text: &content}); err != nil {
+				return err
+			}
+			if err := send(dto.StreamEvent{Type: "response.content_part.done", ItemID: item.ID, OutputIndex: &index, ContentIndex: &contentIndex, Part: &part}); err != nil {
+				return err
+			}
+			return send(dto.StreamEvent{Type: "response.output_item.done", OutputIndex: &index, Item: item})
+		}
+
+		// With tools, function call blocks are held back and sent as function_call items
+		var parser *utils.ToolCallParser
+		if stream.Tools {
+			parser = &utils.ToolCallParser{}
+		}
+		functionCalls := 0
+		emit := func(delta string, calls []utils.ToolCall) error {
+			if delta != "" {
+				if message < 0 {
+					if err := openMessage(); err != nil {
+						return err
+					}
+				}
+				text.WriteString(delta)
+				if err := send(dto.StreamEvent{Type: "response.output_text.delta", ItemID: output[message].ID, OutputIndex: &message, ContentIndex: &contentIndex, Delta: delta}); err != nil {
+					return err
+				}
+			}
+			for _, call := range calls {
+				if !stream.ParallelToolCalls && functionCalls > 0 {
+					break
+				}
+				functionCalls++
+				if message >= 0 {
+					if err := closeMessage(nil); err != nil {
+						return err
+					}
+				}
+
+				item := NewFunctionCallItem(call)
+				index := len(output)
+				output = append(output, item)
+				added := item
+				added.Status, added.Arguments = "in_progress", ""
+				if err := send(dto.StreamEvent{Type: "response.output_item.added", OutputIndex: &index, Item: &added}); err != nil {
+					return err
+				}
+				if err := send(dto.StreamEvent{Type: "response.function_call_arguments.delta", ItemID: item.ID, OutputIndex: &index, Delta: item.Arguments}); err != nil {
+					return err
+				}
+				if err := send(dto.StreamEvent{Type: "response.function_call_arguments.done", ItemID: item.ID, OutputIndex: &index, Arguments: &item.Arguments}); err != nil {
+					return err
+				}
+				if err := send(dto.StreamEvent{Type: "response.output_item.done", OutputIndex: &index, Item: &item}); err != nil {
+					return err
+				}
+			}
+			return nil
+		}
+
+		var completion *providers.Response
+		for chunk := range providers.CompleteDeltas(ctx, stream.Chunks) {
+			if chunk.Err != nil {
+				h.log.Error("GenerateContent streaming failed", zap.Error(chunk.Err), zap.String("model", req.Model))
+				failed := response
+				failed.Status = "failed"
+				failed.Output = append([]dto.OutputItem{}, output...)
+				code := providers.SurfaceErrorInfo(utils.SurfaceOpenAI, chunk.Err).Code
+				if code == "" {
+					code = "server_error"
+				}
+				failed.Error = &dto.ResponseError{Code: code, Message: chunk.Err.Error()}
+				_ = send(dto.StreamEvent{Type: "response.failed", Response: &failed})
+				return
+			}
+			if chunk.Response != nil {
+				completion = chunk.Response
+				continue
+			}
+
+			delta, calls := chunk.Delta, []utils.ToolCall(nil)
+			if parser != nil {
+				delta, calls = parser.Feed(chunk.Delta)
+			}
+			if err := emit(delta, calls); err != nil {
+				h.log.Info("Stream cancelled by client")
+				return
+			}
+		}
+		if completion == nil {
+			h.log.Warn("Stream ended before completion", zap.Error(ctx.Err()), zap.String("model", req.Model))
+			return
+		}
+		if parser != nil {
+			if err := emit(parser.Flush()); err != nil {
+				return
+			}
+		}
+
+		// Like the non-streaming response, a reply without any output is an empty message
+		if len(output) == 0 {
+			if err := openMessage(); err != nil {
+				return
+			}
+		}
+		if message >= 0 {
+			if err := closeMessage(completion.Sources); err != nil {
+				return
+			}
+		}
+
+		_ = send(dto.StreamEvent{Type: "response.completed", Response: stream.Complete(output, completion.Text)})
+	})
+
+	return nil
+}
+
+// HandleGetResponse returns a stored response
+// @Summary Get Response (OpenAI)
+// @Description Retrieves a stored response by ID
+// @Tags OpenAI
+// @Produce json
+// @Param response_id path string true "Response ID"
+// @Success 200 {object} dto.Response
+// @Failure 404 {object} map[string]interface{}
+// @Router /openai/v1/responses/{response_id} [get]
+func (h *ResponsesController) HandleGetResponse(c fiber.Ctx) error {
+	response, err := h.service.GetResponse(c.Params("response_id"))
+	if err != nil {
+		return h.respondError(c, err, "")
+	}
+	return c.JSON(response)
+}
+
+// HandleDeleteResponse deletes a stored response
+// @Summary Delete Response (OpenAI)
+// @Description Deletes a stored response; it can no longer be used as previous_response_id
+// @Tags OpenAI
+// @Produce json
+// @Param response_id path string true "Response ID"
+// @Success 200 {object} dto.DeletedResponse
+// @Failure 404 {object} map[string]interface{}
+// @Router /openai/v1/responses/{response_id} [delete]
+func (h *ResponsesController) HandleDeleteResponse(c fiber.Ctx) error {
+	id := c.Params("response_id")
+	if err := h.service.DeleteResponse(id); err != nil {
+		return h.respondError(c, err, "")
+	}
+	return c.JSON(dto.DeletedResponse{ID: id, Object: "response.deleted", Deleted: true})
+}
+
+// respondError writes a service error as an OpenAI error body with a matching status
+func (h *ResponsesController) respondError(c fiber.Ctx, err error, model string) error {
+	switch {
+	case errors.Is(err, providers.ErrModelNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(models.ErrorResponse{
+			Error: models.Error{
+				Message: fmt.Sprintf("The model `%s` does not exist or you do not have access to it.", model),
+				Type:    "invalid_request_error",
+				Code:    "model_not_found",
+			},
+		})
+	case errors.Is(err, ErrResponseNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorToResponse(err, "invalid_request_error"))
+	}
+
+	info := providers.SurfaceErrorInfo(utils.SurfaceOpenAI, err)
+	if info.Status == fiber.StatusInternalServerError {
+		h.log.Error("GenerateContent failed", zap.Error(err), zap.String("model", model))
+	} else {
+		h.log.Warn("GenerateContent failed upstream", zap.Error(err), zap.String("model", model))
+	}
+	return c.Status(info.Status).JSON(utils.SurfaceErrorBody(utils.SurfaceOpenAI, info.Status, info.Type, info.Code, err.Error()))
+}
+
+// Register registers the Responses API routes onto the provided group
+func (c *ResponsesController) Register(group fiber.Router) {
+	group.Post("/responses", c.HandleCreateResponse)
+	group.Get("/responses/:response_id", c.HandleGetResponse)
+	group.Delete("/responses/:response_id", c.HandleDeleteResponse)
+}
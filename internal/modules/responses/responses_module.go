@@ -0,0 +1,18 @@
+package responses
+
+import (
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(NewResponsesService),
+	fx.Provide(NewResponsesController),
+	fx.Invoke(RegisterRoutes),
+)
+
+func RegisterRoutes(app *fiber.App, c *ResponsesController) {
+	// Served next to the other OpenAI routes
+	c.Register(app.Group("/openai/v1"))
+	c.Register(app.Group("/v1"))
+}
@@ -0,0 +1,535 @@
+package responses
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/commons/models"
+	"gemini-web-to-api/internal/commons/tracing"
+	"gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/providers"
+	"gemini-web-to-api/internal/modules/responses/dto"
+	"gemini-web-to-api/internal/modules/sessions"
+
+	"github.com/google/uuid"
+	"go.uber.org/zap"
+)
+
+// ErrResponseNotFound is returned for unknown, expired or deleted response IDs
+var ErrResponseNotFound = errors.New("response not found")
+
+// ResponsesService implements the OpenAI Responses API on top of chat sessions.
+// Every stored response keeps the Gemini conversation it was generated in, so
+// previous_response_id continues that conversation with only the new input.
+type ResponsesService struct {
+	client    *providers.AccountPool
+	responses sessions.SessionStore
+	log       *zap.Logger
+}
+
+// storedResponse is kept as the data of the response's session
+type storedResponse struct {
+	Response     dto.Response `json:"response"`
+	Instructions string       `json:"instructions,omitempty"` // instructions the conversation was last given
+	ToolHeader   string       `json:"tool_header,omitempty"`  // tool instructions the conversation was last given
+}
+
+// Stored responses follow the limits of the conversation cache
+func NewResponsesService(cfg *configs.Config, client *providers.AccountPool, log *zap.Logger) (*ResponsesService, error) {
+	responses, err := sessions.Open(cfg.Session, "responses",
+		cfg.Conversation.MaxEntries,
+		time.Duration(cfg.Conversation.TTL)*time.Second,
+	)
+	if err != nil {
+		return nil, err
+	}
+	return &ResponsesService{client: client, responses: responses, log: log}, nil
+}
+
+// ResponseStream is a streaming response that has been accepted upstream
+type ResponseStream struct {
+	Response dto.Response // the in_progress response announced before any output
+	Chunks   <-chan providers.StreamChunk
+
+	// Tools is set when the reply must be parsed for emulated function calls
+	Tools             bool
+	ParallelToolCalls bool
+
+	turn *responseTurn
+}
+
+// Complete finalizes the streamed response from its output items and stores it
+func (s *ResponseStream) Complete(output []dto.OutputItem, text string) *dto.Response {
+	return s.turn.complete(output, text)
+}
+
+// responseTurn is a validated request bound to the chat session answering it
+type responseTurn struct {
+	service           *ResponsesService
+	session           providers.ChatSession
+	prompt            string
+	opts              []providers.GenerateOption
+	tools             bool
+	parallelToolCalls bool
+	store             bool
+	instructions      string
+	toolHeader        string
+	response          dto.Response
+}
+
+func (s *ResponsesService) CreateResponse(ctx context.Context, req dto.CreateResponseRequest) (*dto.Response, error) {
+	ctx, span := tracing.Start(ctx, "ResponsesService.CreateResponse",
+		tracing.AttrSurface.String(utils.SurfaceOpenAI),
+		tracing.AttrModel.String(req.Model),
+	)
+	defer span.End()
+
+	turn, err := s.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+
+	response, err := turn.session.SendMessage(ctx, turn.prompt, turn.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	text, calls := response.Text, []utils.ToolCall(nil)
+	if turn.tools {
+		text, calls = utils.ExtractToolCalls(response.Text)
+		if !turn.parallelToolCalls && len(calls) > 1 {
+			calls = calls[:1]
+		}
+	}
+
+	output := []dto.OutputItem{}
+	if text != "" || len(calls) == 0 {
+		output = append(output, dto.OutputItem{
+			Type:    "message",
+			ID:      newID("msg_"),
+			Status:  "completed",
+			Role:    "assistant",
+			Content: []dto.OutputContent{NewOutputText(text, response.Sources)},
+		})
+	}
+	for _, call := range calls {
+		output = append(output, NewFunctionCallItem(call))
+	}
+	return turn.complete(output, response.Text), nil
+}
+
+// CreateResponseStream starts a streaming response. Errors returned here happen
+// before any event is sent; later failures arrive on the chunk channel.
+func (s *ResponsesService) CreateResponseStream(ctx context.Context, req dto.CreateResponseRequest) (*ResponseStream, error) {
+	ctx, span := tracing.Start(ctx, "ResponsesService.CreateResponseStream",
+		tracing.AttrSurface.String(utils.SurfaceOpenAI),
+		tracing.AttrModel.String(req.Model),
+	)
+	defer span.End()
+
+	turn, err := s.prepare(req)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := turn.session.SendMessageStream(ctx, turn.prompt, turn.opts...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &ResponseStream{
+		Response:          turn.response,
+		Chunks:            chunks,
+		Tools:             turn.tools,
+		ParallelToolCalls: turn.parallelToolCalls,
+		turn:              turn,
+	}, nil
+}
+
+// GetResponse returns a stored response
+func (s *ResponsesService) GetResponse(id string) (*dto.Response, error) {
+	_, stored, err := s.load(id)
+	if err != nil {
+		return nil, err
+	}
+	return &stored.Response, nil
+}
+
+// DeleteResponse removes a stored response; it can no longer be chained
+func (s *ResponsesService) DeleteResponse(id string) error {
+	err := s.responses.Delete(id)
+	if errors.Is(err, sessions.ErrSessionNotFound) {
+		return fmt.Errorf("%w: %s", ErrResponseNotFound, id)
+	}
+	return err
+}
+
+// load reads a stored response and its session
+func (s *ResponsesService) load(id string) (*sessions.Session, *storedResponse, error) {
+	session, err := s.responses.Get(id)
+	if errors.Is(err, sessions.ErrSessionNotFound) {
+		return nil, nil, fmt.Errorf("%w: %s", ErrResponseNotFound, id)
+	}
+	if err != nil {
+		return nil, nil, err
+	}
+	var stored storedResponse
+	if err := json.Unmarshal(session.Data, &stored); err != nil {
+		return nil, nil, fmt.Errorf("corrupt stored response %s: %w", id, err)
+	}
+	return session, &stored, nil
+}
+
+// NewOutputText builds an output_text part citing the sources of the reply.
+// Gemini does not say which text a source supports, so citations span all of it.
+func NewOutputText(text string, sources []providers.Source) dto.OutputContent {
+	annotations := []dto.Annotation{}
+	for _, source := range sources {
+		annotations = append(annotations, dto.Annotation{
+			Type:       "url_citation",
+			URL:        source.URL,
+			Title:      source.Title,
+			StartIndex: 0,
+			EndIndex:   len(text),
+		})
+	}
+	return dto.OutputContent{Type: "output_text", Text: text, Annotations: annotations}
+}
+
+// NewFunctionCallItem converts a parsed tool call to a function_call output item
+func NewFunctionCallItem(call utils.ToolCall) dto.OutputItem {
+	return dto.OutputItem{
+		Type:      "function_call",
+		ID:        newID("fc_"),
+		Status:    "completed",
+		CallID:    utils.NewToolCallID("call_"),
+		Name:      call.Name,
+		Arguments: string(call.Arguments),
+	}
+}
+
+// complete fills in the output and usage of the response and stores it
+func (t *responseTurn) complete(output []dto.OutputItem, text string) *dto.Response {
+	response := t.response
+	response.Status = "completed"
+	response.Output = output
+	inputTokens, outputTokens := utils.EstimateTokens(t.prompt), utils.EstimateTokens(text)
+	response.Usage = &dto.Usage{
+		InputTokens:  inputTokens,
+		OutputTokens: outputTokens,
+		TotalTokens:  inputTokens + outputTokens,
+	}
+
+	if t.store {
+		if err := t.save(response); err != nil {
+			t.service.log.Warn("Failed to store response", zap.String("id", response.ID), zap.Error(err))
+		}
+	}
+	return &response
+}
+
+// save stores the response with the conversation it was generated in
+func (t *responseTurn) save(response dto.Response) error {
+	data, err := json.Marshal(storedResponse{
+		Response:     response,
+		Instructions: t.instructions,
+		ToolHeader:   t.toolHeader,
+	})
+	if err != nil {
+		return err
+	}
+	session := sessions.NewSession(response.ID, t.session)
+	session.Data = data
+	return t.service.responses.Put(session)
+}
+
+// prepare validates the request and builds the prompt. A chained request only
+// sends its own input to the previous response's conversation, together with
+// instructions and tools the conversation has not been given yet.
+func (s *ResponsesService) prepare(req dto.CreateResponseRequest) (*responseTurn, error) {
+	if _, err := s.client.ResolveModel(req.Model); err != nil {
+		return nil, err
+	}
+
+	messages, err := parseInput(req.Input)
+	if err != nil {
+		return nil, err
+	}
+	if len(messages) == 0 {
+		return nil, utils.InvalidRequestf("input is required")
+	}
+
+	tools, choice, err := parseTools(req)
+	if err != nil {
+		return nil, err
+	}
+	toolHeader := utils.BuildPromptFromMessages(nil, "", utils.WithTools(tools, choice))
+
+	var previousSession *sessions.Session
+	var previous *storedResponse
+	if req.PreviousResponseID != "" {
+		if previousSession, previous, err = s.load(req.PreviousResponseID); err != nil {
+			return nil, err
+		}
+	}
+
+	var prompt string
+	chatOpts := []providers.ChatOption{providers.WithChatModel(req.Model)}
+	if previous == nil {
+		prompt = utils.BuildPromptFromMessages(messages, req.Instructions, utils.WithTools(tools, choice))
+	} else {
+		// Function call outputs are labelled with the names of the calls the
+		// previous response made
+		calls := previousCalls(previous.Response)
+		lines := utils.RenderMessages(append(calls, messages...))[len(calls):]
+
+		var parts []string
+		if req.Instructions != "" && req.Instructions != previous.Instructions {
+			parts = append(parts, "System: "+req.Instructions)
+		}
+		if toolHeader != "" && toolHeader != previous.ToolHeader {
+			parts = append(parts, toolHeader)
+		}
+		prompt = strings.Join(append(parts, lines...), "\n")
+		// The session carries on with the previous history, on the requested model
+		chatOpts = append(previousSession.ChatOptions(), chatOpts...)
+	}
+	if strings.TrimSpace(prompt) == "" {
+		return nil, utils.InvalidRequestf("no valid content in input")
+	}
+
+	attachments, err := utils.ExtractAttachments(messages)
+	if err != nil {
+		return nil, err
+	}
+	opts := []providers.GenerateOption{}
+	if len(attachments) > 0 {
+		opts = append(opts, providers.WithAttachments(attachments))
+	}
+
+	parallel := req.ParallelToolCalls == nil || *req.ParallelToolCalls
+	store := req.Store == nil || *req.Store
+	responseTools := req.Tools
+	if responseTools == nil {
+		responseTools = []dto.Tool{}
+	}
+	toolChoice := req.ToolChoice
+	if toolChoice == nil {
+		toolChoice = utils.ToolChoiceAuto
+	}
+	response := dto.Response{
+		ID:                newID("resp_"),
+		Object:            "response",
+		CreatedAt:         time.Now().Unix(),
+		Status:            "in_progress",
+		Model:             req.Model,
+		Output:            []dto.OutputItem{},
+		Tools:             responseTools,
+		ToolChoice:        toolChoice,
+		ParallelToolCalls: parallel,
+		Store:             store,
+		Metadata:          req.Metadata,
+	}
+	if req.Instructions != "" {
+		response.Instructions = &req.Instructions
+	}
+	if req.PreviousResponseID != "" {
+		response.PreviousResponseID = &req.PreviousResponseID
+	}
+	if response.Metadata == nil {
+		response.Metadata = map[string]string{}
+	}
+
+	instructions := req.Instructions
+	if instructions == "" && previous != nil {
+		instructions = previous.Instructions
+	}
+	return &responseTurn{
+		service:           s,
+		session:           s.client.StartChat(chatOpts...),
+		prompt:            prompt,
+		opts:              opts,
+		tools:             utils.ToolsActive(tools, choice),
+		parallelToolCalls: parallel,
+		store:             store,
+		instructions:      instructions,
+		toolHeader:        toolHeader,
+		response:          response,
+	}, nil
+}
+
+// previousCalls returns the function calls of a response as assistant messages
+func previousCalls(response dto.Response) []models.Message {
+	var messages []models.Message
+	for _, item := range response.Output {
+		if item.Type != "function_call" {
+			continue
+		}
+		messages = append(messages, models.Message{
+			Role: "assistant",
+			ToolCalls: []models.ToolCall{{
+				ID:       item.CallID,
+				Type:     "function",
+				Function: models.FunctionCall{Name: item.Name, Arguments: item.Arguments},
+			}},
+		})
+	}
+	return messages
+}
+
+// parseInput converts the input string or items to chat messages. Content parts
+// become Chat Completions parts, so attachments are decoded the same way.
+func parseInput(raw json.RawMessage) ([]models.Message, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return nil, nil
+	}
+	if raw[0] == '"' {
+		var text string
+		if err := json.Unmarshal(raw, &text); err != nil {
+			return nil, utils.InvalidRequestf("invalid input: %w", err)
+		}
+		return []models.Message{{Role: "user", Content: text}}, nil
+	}
+
+	var items []dto.InputItem
+	if err := json.Unmarshal(raw, &items); err != nil {
+		return nil, utils.InvalidRequestf("invalid input: %w", err)
+	}
+
+	var messages []models.Message
+	for i, item := range items {
+		switch item.Type {
+		case "", "message":
+			role := item.Role
+			switch role {
+			case "":
+				role = "user"
+			case "developer":
+				role = "system"
+			}
+			content, err := parseInputContent(item.Content)
+			if err != nil {
+				return nil, fmt.Errorf("input[%d]: %w", i, err)
+			}
+			messages = append(messages, models.Message{Role: role, Content: content})
+		case "function_call":
+			messages = append(messages, models.Message{
+				Role: "assistant",
+				ToolCalls: []models.ToolCall{{
+					ID:       item.CallID,
+					Type:     "function",
+					Function: models.FunctionCall{Name: item.Name, Arguments: item.Arguments},
+				}},
+			})
+		case "function_call_output":
+			output, err := parseInputContent(item.Output)
+			if err != nil {
+				return nil, fmt.Errorf("input[%d]: %w", i, err)
+			}
+			messages = append(messages, models.Message{
+				Role:       "tool",
+				ToolCallID: item.CallID,
+				Content:    utils.GetMessageText(output),
+			})
+		default:
+			return nil, utils.InvalidRequestf("input[%d]: unsupported item type %s", i, item.Type)
+		}
+	}
+	return messages, nil
+}
+
+// parseInputContent converts message content (a string or content parts)
+func parseInputContent(raw json.RawMessage) (interface{}, error) {
+	raw = bytes.TrimSpace(raw)
+	if len(raw) == 0 || string(raw) == "null" {
+		return "", nil
+	}
+	if raw[0] == '"' {
+		var text string
+		err := json.Unmarshal(raw, &text)
+		return text, err
+	}
+
+	var parts []dto.InputContent
+	if err := json.Unmarshal(raw, &parts); err != nil {
+		return nil, utils.InvalidRequestf("invalid content: %w", err)
+	}
+	blocks := []interface{}{}
+	for _, part := range parts {
+		switch part.Type {
+		case "input_text", "output_text", "text":
+			blocks = append(blocks, map[string]interface{}{"type": "text", "text": part.Text})
+		case "input_image":
+			if part.ImageURL == "" {
+				return nil, utils.InvalidRequestf("file_id references are not supported, send image_url")
+			}
+			blocks = append(blocks, map[string]interface{}{
+				"type":      "image_url",
+				"image_url": map[string]interface{}{"url": part.ImageURL},
+			})
+		case "input_file":
+			blocks = append(blocks, map[string]interface{}{
+				"type": "file",
+				"file": map[string]interface{}{"file_data": part.FileData, "filename": part.Filename},
+			})
+		default:
+			return nil, utils.InvalidRequestf("unsupported content type %s", part.Type)
+		}
+	}
+	return blocks, nil
+}
+
+// parseTools converts the request's function tools and tool_choice. Web search
+// tools are accepted and ignored, since Gemini searches the web by itself.
+func parseTools(req dto.CreateResponseRequest) ([]utils.ToolDefinition, utils.ToolChoice, error) {
+	var tools []utils.ToolDefinition
+	for _, tool := range req.Tools {
+		switch tool.Type {
+		case "function":
+			if tool.Name == "" {
+				return nil, utils.ToolChoice{}, utils.InvalidRequestf("tool function name is required")
+			}
+			tools = append(tools, utils.ToolDefinition{
+				Name:        tool.Name,
+				Description: tool.Description,
+				Parameters:  tool.Parameters,
+			})
+		case "web_search", "web_search_preview":
+		default:
+			return nil, utils.ToolChoice{}, utils.InvalidRequestf("unsupported tool type: %s", tool.Type)
+		}
+	}
+
+	choice := utils.ToolChoice{Mode: utils.ToolChoiceAuto}
+	switch v := req.ToolChoice.(type) {
+	case nil:
+	case string:
+		switch v {
+		case utils.ToolChoiceAuto, utils.ToolChoiceNone, utils.ToolChoiceRequired:
+			choice.Mode = v
+		default:
+			return nil, utils.ToolChoice{}, utils.InvalidRequestf("invalid tool_choice: %s", v)
+		}
+	case map[string]interface{}:
+		name, _ := v["name"].(string)
+		if v["type"] != "function" || name == "" {
+			return nil, utils.ToolChoice{}, utils.InvalidRequestf("tool_choice must name a function")
+		}
+		choice = utils.ToolChoice{Mode: utils.ToolChoiceRequired, Name: name}
+	default:
+		return nil, utils.ToolChoice{}, utils.InvalidRequestf("invalid tool_choice")
+	}
+	return tools, choice, nil
+}
+
+// newID returns a random identifier with an OpenAI style prefix
+func newID(prefix string) string {
+	return prefix + strings.ReplaceAll(uuid.New().String(), "-", "")
+}
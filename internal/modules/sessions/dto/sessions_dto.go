@@ -0,0 +1,83 @@
+package dto
+
+import "time"
+
+// CreateSessionRequest creates a chat session
+type CreateSessionRequest struct {
+	Model string `json:"model,omitempty"` // defaults to the account's default model
+}
+
+// SendMessageRequest posts a message to a session
+type SendMessageRequest struct {
+	Message string `json:"message"`
+	Files   []File `json:"files,omitempty"`
+	Stream  bool   `json:"stream,omitempty"`
+}
+
+// File is a base64 encoded attachment
+type File struct {
+	MimeType string `json:"mime_type"`
+	Data     string `json:"data"`
+}
+
+// Session describes a chat session and the Gemini conversation behind it
+type Session struct {
+	ID             string    `json:"id"`
+	Object         string    `json:"object"` // "session"
+	Model          string    `json:"model"`
+	ConversationID string    `json:"conversation_id,omitempty"`
+	ResponseID     string    `json:"response_id,omitempty"`
+	ChoiceID       string    `json:"choice_id,omitempty"`
+	Account        string    `json:"account,omitempty"`
+	MessageCount   int       `json:"message_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	UpdatedAt      time.Time `json:"updated_at"`
+}
+
+// Message is a message of a session's history
+type Message struct {
+	Role    string  `json:"role"` // "user" or "model"
+	Content string  `json:"content"`
+	Images  []Image `json:"images,omitempty"`
+}
+
+// Image is an image of a model reply
+type Image struct {
+	URL       string `json:"url"`
+	Title     string `json:"title,omitempty"`
+	AltText   string `json:"alt_text,omitempty"`
+	Generated bool   `json:"generated,omitempty"`
+}
+
+// Source is a web page cited by a reply
+type Source struct {
+	URL   string `json:"url"`
+	Title string `json:"title,omitempty"`
+}
+
+// SendMessageResponse is the reply to a posted message
+type SendMessageResponse struct {
+	SessionID string   `json:"session_id"`
+	Message   Message  `json:"message"`
+	Sources   []Source `json:"sources,omitempty"`
+}
+
+// HistoryResponse lists the messages of a session
+type HistoryResponse struct {
+	SessionID string    `json:"session_id"`
+	Messages  []Message `json:"messages"`
+}
+
+// DeltaEvent is the data of a "delta" stream event
+type DeltaEvent struct {
+	Text string `json:"text"`
+}
+
+// DeletedSession is returned when a session is deleted
+type DeletedSession struct {
+	ID      string `json:"id"`
+	Object  string `json:"object"` // "session.deleted"
+	Deleted bool   `json:"deleted"`
+	// ConversationDeleted reports whether the conversation was also removed from the Gemini history
+	ConversationDeleted bool `json:"conversation_deleted"`
+}
@@ -0,0 +1,82 @@
+package sessions
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/modules/providers"
+)
+
+// ErrSessionNotFound is returned for unknown, expired or deleted session IDs
+var ErrSessionNotFound = errors.New("session not found")
+
+// Session is the persisted state of a chat session: enough to restore it with
+// StartChat on any proxy process sharing the store
+type Session struct {
+	ID        string                    `json:"id"`
+	Metadata  providers.SessionMetadata `json:"metadata"`
+	History   []providers.Message       `json:"history,omitempty"`
+	Data      json.RawMessage           `json:"data,omitempty"` // state owned by the service that stored the session
+	CreatedAt time.Time                 `json:"created_at"`
+	UpdatedAt time.Time                 `json:"updated_at"`
+}
+
+// SessionStore keeps sessions by ID. Sessions expire once they have not been
+// used for the store's TTL, and the least recently used ones are evicted
+// beyond its size limit.
+type SessionStore interface {
+	// Get returns the session stored under id and marks it as used
+	Get(id string) (*Session, error)
+
+	// Put creates or replaces a session, stamping CreatedAt and UpdatedAt
+	Put(session *Session) error
+
+	// Delete removes a session
+	Delete(id string) error
+}
+
+// NewSession captures the state of a chat session
+func NewSession(id string, chat providers.ChatSession) *Session {
+	return &Session{
+		ID:       id,
+		Metadata: *chat.GetMetadata(),
+		History:  chat.GetHistory(),
+	}
+}
+
+// ChatOptions restores the session with StartChat
+func (s *Session) ChatOptions() []providers.ChatOption {
+	metadata := s.Metadata
+	return []providers.ChatOption{
+		providers.WithChatModel(metadata.Model),
+		providers.WithChatMetadata(&metadata),
+		providers.WithChatHistory(s.History),
+	}
+}
+
+// Open opens the store called name in the configured backend. Every name is a
+// separate set of sessions with its own limits; the file backend keeps it in
+// a subdirectory of SESSION_DIR.
+func Open(cfg configs.SessionConfig, name string, maxEntries int, ttl time.Duration) (SessionStore, error) {
+	switch cfg.Store {
+	case configs.SessionStoreMemory:
+		return NewMemoryStore(maxEntries, ttl), nil
+	case configs.SessionStoreFile:
+		return NewFileStore(filepath.Join(cfg.Dir, name), maxEntries, ttl)
+	default:
+		return nil, fmt.Errorf("unknown session store: %s", cfg.Store)
+	}
+}
+
+// touch stamps a session that is about to be stored
+func touch(session *Session) {
+	now := time.Now()
+	if session.CreatedAt.IsZero() {
+		session.CreatedAt = now
+	}
+	session.UpdatedAt = now
+}
@@ -0,0 +1,204 @@
+package sessions
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// filePruneInterval is how often expired session files are removed
+const filePruneInterval = time.Minute
+
+// FileStore keeps one JSON file per session in a directory. Sessions survive
+// restarts, and processes sharing the directory (e.g. on a shared volume) see
+// each other's sessions. A file's modification time is its last use.
+type FileStore struct {
+	dir        string
+	maxEntries int           // zero or less disables the size limit
+	ttl        time.Duration // zero disables expiry
+
+	mu       sync.Mutex // serializes pruning within this process; protects: count, prunedAt
+	count    int        // sessions in the directory, as of the last prune plus the ones created since
+	prunedAt time.Time
+}
+
+// NewFileStore creates a store in dir holding at most maxEntries sessions
+func NewFileStore(dir string, maxEntries int, ttl time.Duration) (*FileStore, error) {
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create session directory: %w", err)
+	}
+	s := &FileStore{dir: dir, maxEntries: maxEntries, ttl: ttl}
+	if err := s.prune(); err != nil {
+		return nil, fmt.Errorf("failed to read session directory: %w", err)
+	}
+	return s, nil
+}
+
+func (s *FileStore) Get(id string) (*Session, error) {
+	path := s.path(id)
+	info, err := os.Stat(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	if s.expired(info.ModTime(), time.Now()) {
+		_ = os.Remove(path)
+		return nil, ErrSessionNotFound
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		// Deleted by another process in the meantime
+		return nil, ErrSessionNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	var session Session
+	if err := json.Unmarshal(data, &session); err != nil {
+		return nil, fmt.Errorf("corrupt session file %s: %w", path, err)
+	}
+	if session.ID != id {
+		return nil, ErrSessionNotFound
+	}
+
+	now := time.Now()
+	_ = os.Chtimes(path, now, now)
+	return &session, nil
+}
+
+// Put writes the session to a temporary file and renames it into place, so
+// readers never see a partially written session
+func (s *FileStore) Put(session *Session) error {
+	touch(session)
+	data, err := json.Marshal(session)
+	if err != nil {
+		return err
+	}
+
+	path := s.path(session.ID)
+	_, statErr := os.Stat(path)
+	created := errors.Is(statErr, fs.ErrNotExist)
+
+	tmp, err := os.CreateTemp(s.dir, ".session-*.tmp")
+	if err != nil {
+		return err
+	}
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		os.Remove(tmp.Name())
+		return err
+	}
+
+	if created {
+		return s.created()
+	}
+	return nil
+}
+
+func (s *FileStore) Delete(id string) error {
+	err := os.Remove(s.path(id))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrSessionNotFound
+	}
+	if err == nil {
+		s.mu.Lock()
+		s.count = max(s.count-1, 0)
+		s.mu.Unlock()
+	}
+	return err
+}
+
+// created counts a new session file and prunes the directory when the count
+// goes over the limit, or once per filePruneInterval to remove expired sessions.
+// The count misses sessions created by other processes until the next prune.
+func (s *FileStore) created() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	s.count++
+	if (s.maxEntries > 0 && s.count > s.maxEntries) || time.Since(s.prunedAt) >= filePruneInterval {
+		return s.pruneLocked()
+	}
+	return nil
+}
+
+// prune removes expired sessions and the least recently used ones beyond the limit
+func (s *FileStore) prune() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.pruneLocked()
+}
+
+func (s *FileStore) pruneLocked() error {
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return err
+	}
+
+	type sessionFile struct {
+		path     string
+		lastUsed time.Time
+	}
+	now := time.Now()
+	var files []sessionFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || strings.HasPrefix(name, ".") || !strings.HasSuffix(name, ".json") {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue // removed by another process
+		}
+		path := filepath.Join(s.dir, name)
+		if s.expired(info.ModTime(), now) {
+			_ = os.Remove(path)
+			continue
+		}
+		files = append(files, sessionFile{path: path, lastUsed: info.ModTime()})
+	}
+
+	s.prunedAt = now
+	s.count = len(files)
+	if s.maxEntries <= 0 || len(files) <= s.maxEntries {
+		return nil
+	}
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].lastUsed.After(files[j].lastUsed)
+	})
+	for _, file := range files[s.maxEntries:] {
+		_ = os.Remove(file.path)
+	}
+	s.count = s.maxEntries
+	return nil
+}
+
+func (s *FileStore) expired(lastUsed, now time.Time) bool {
+	return s.ttl > 0 && now.Sub(lastUsed) > s.ttl
+}
+
+// path maps a session ID to its file; IDs are hashed so any ID is a safe file name
+func (s *FileStore) path(id string) string {
+	sum := sha256.Sum256([]byte(id))
+	return filepath.Join(s.dir, hex.EncodeToString(sum[:])+".json")
+}
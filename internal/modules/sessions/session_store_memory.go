@@ -0,0 +1,38 @@
+package sessions
+
+import (
+	"time"
+
+	"gemini-web-to-api/internal/commons/utils"
+)
+
+// MemoryStore keeps sessions in process memory; they are lost on restart
+type MemoryStore struct {
+	sessions *utils.LRU[Session]
+}
+
+// NewMemoryStore creates a store holding at most maxEntries sessions
+func NewMemoryStore(maxEntries int, ttl time.Duration) *MemoryStore {
+	return &MemoryStore{sessions: utils.NewLRU[Session](maxEntries, ttl)}
+}
+
+func (s *MemoryStore) Get(id string) (*Session, error) {
+	session, ok := s.sessions.Get(id)
+	if !ok {
+		return nil, ErrSessionNotFound
+	}
+	return &session, nil
+}
+
+func (s *MemoryStore) Put(session *Session) error {
+	touch(session)
+	s.sessions.Add(session.ID, *session)
+	return nil
+}
+
+func (s *MemoryStore) Delete(id string) error {
+	if !s.sessions.Remove(id) {
+		return ErrSessionNotFound
+	}
+	return nil
+}
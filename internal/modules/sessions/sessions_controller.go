@@ -0,0 +1,279 @@
+package sessions
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	utils "gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/auth"
+	"gemini-web-to-api/internal/modules/providers"
+	"gemini-web-to-api/internal/modules/sessions/dto"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/zap"
+)
+
+type SessionsController struct {
+	service *SessionsService
+	auth    *auth.AuthService
+	log     *zap.Logger
+}
+
+func NewSessionsController(service *SessionsService, auth *auth.AuthService) *SessionsController {
+	return &SessionsController{
+		service: service,
+		auth:    auth,
+		log:     zap.NewNop(),
+	}
+}
+
+// SetLogger sets the logger for this handler
+func (h *SessionsController) SetLogger(log *zap.Logger) {
+	h.log = log
+}
+
+// HandleCreateSession creates a chat session
+// @Summary Create Session
+// @Description Creates a chat session for a model. Messages posted to it continue one Gemini conversation, so the history is never resent.
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Param request body dto.CreateSessionRequest false "Create Session Request"
+// @Success 200 {object} dto.Session
+// @Failure 404 {object} map[string]interface{}
+// @Router /sessions [post]
+func (h *SessionsController) HandleCreateSession(c fiber.Ctx) error {
+	var req dto.CreateSessionRequest
+	if len(c.Body()) > 0 {
+		if err := c.Bind().Body(&req); err != nil {
+			return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorToResponse(fmt.Errorf("invalid request body: %w", err), "invalid_request_error"))
+		}
+	}
+
+	session, err := h.service.CreateSession(req.Model)
+	if err != nil {
+		return h.respondError(c, err)
+	}
+	return c.JSON(NewSessionResponse(session))
+}
+
+// HandleGetSession returns the metadata of a session
+// @Summary Get Session
+// @Description Returns the model, Gemini conversation IDs and account of a session
+// @Tags Sessions
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} dto.Session
+// @Failure 404 {object} map[string]interface{}
+// @Router /sessions/{session_id} [get]
+func (h *SessionsController) HandleGetSession(c fiber.Ctx) error {
+	session, err := h.service.GetSession(c.Params("session_id"))
+	if err != nil {
+		return h.respondError(c, err)
+	}
+	return c.JSON(NewSessionResponse(session))
+}
+
+// HandleGetHistory returns the messages of a session
+// @Summary Get Session History
+// @Description Returns the messages exchanged in a session
+// @Tags Sessions
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} dto.HistoryResponse
+// @Failure 404 {object} map[string]interface{}
+// @Router /sessions/{session_id}/history [get]
+func (h *SessionsController) HandleGetHistory(c fiber.Ctx) error {
+	session, err := h.service.GetSession(c.Params("session_id"))
+	if err != nil {
+		return h.respondError(c, err)
+	}
+	return c.JSON(dto.HistoryResponse{SessionID: session.ID, Messages: NewMessages(session.History)})
+}
+
+// HandleSendMessage posts a message to a session
+// @Summary Send Session Message
+// @Description Sends a message to the session's conversation. Set "stream": true to receive "delta" Server-Sent Events followed by a "message" event with the complete reply.
+// @Tags Sessions
+// @Accept json
+// @Produce json
+// @Produce text/event-stream
+// @Param session_id path string true "Session ID"
+// @Param request body dto.SendMessageRequest true "Send Message Request"
+// @Success 200 {object} dto.SendMessageResponse
+// @Failure 400 {object} map[string]interface{}
+// @Failure 403 {object} map[string]interface{}
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Failure 500 {object} map[string]interface{}
+// @Router /sessions/{session_id}/messages [post]
+func (h *SessionsController) HandleSendMessage(c fiber.Ctx) error {
+	id := c.Params("session_id")
+	var req dto.SendMessageRequest
+	if err := c.Bind().Body(&req); err != nil {
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorToResponse(fmt.Errorf("invalid request body: %w", err), "invalid_request_error"))
+	}
+	if err := h.checkModel(c, id); err != nil {
+		return h.respondError(c, err)
+	}
+
+	if req.Stream {
+		return h.handleSendMessageStream(c, id, req)
+	}
+
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+	defer cancel()
+
+	response, err := h.service.SendMessage(ctx, id, req.Message, req.Files)
+	if err != nil {
+		return h.respondError(c, err)
+	}
+	return c.JSON(dto.SendMessageResponse{
+		SessionID: id,
+		Message:   NewMessage("model", response.Text, response.Images),
+		Sources:   NewSources(response.Sources),
+	})
+}
+
+// handleSendMessageStream streams the reply as "delta" events and ends with a
+// "message" event, or an "error" event if the reply fails
+func (h *SessionsController) handleSendMessageStream(c fiber.Ctx, id string, req dto.SendMessageRequest) error {
+	// The stream outlives this handler, so its context is cancelled by the body writer
+	ctx, cancel := context.WithTimeout(c.Context(), 5*time.Minute)
+
+	stream, err := h.service.SendMessageStream(ctx, id, req.Message, req.Files)
+	if err != nil {
+		cancel()
+		return h.respondError(c, err)
+	}
+
+	c.Set("Content-Type", "text/event-stream")
+	c.Set("Cache-Control", "no-cache")
+	c.Set("Connection", "keep-alive")
+	c.Set("Transfer-Encoding", "chunked")
+
+	c.RequestCtx().SetBodyStreamWriter(func(w *bufio.Writer) {
+		defer cancel()
+
+		for chunk := range providers.CompleteDeltas(ctx, stream.Chunks) {
+			if chunk.Err != nil {
+				h.log.Error("Session message streaming failed", zap.Error(chunk.Err), zap.String("session_id", id))
+				_ = utils.SendSSEChunk(w, h.log, "error", utils.ErrorToResponse(chunk.Err, providers.SurfaceErrorInfo(utils.SurfaceOpenAI, chunk.Err).Type))
+				return
+			}
+			if chunk.Response != nil {
+				_ = utils.SendSSEChunk(w, h.log, "message", dto.SendMessageResponse{
+					SessionID: id,
+					Message:   NewMessage("model", chunk.Response.Text, chunk.Response.Images),
+					Sources:   NewSources(chunk.Response.Sources),
+				})
+				return
+			}
+			if chunk.Delta == "" {
+				continue
+			}
+			if err := utils.SendSSEChunk(w, h.log, "delta", dto.DeltaEvent{Text: chunk.Delta}); err != nil {
+				h.log.Info("Stream cancelled by client")
+				return
+			}
+		}
+		h.log.Warn("Stream ended before completion", zap.Error(ctx.Err()), zap.String("session_id", id))
+	})
+
+	return nil
+}
+
+// HandleClearSession clears the history of a session
+// @Summary Clear Session
+// @Description Forgets the session's history; the next message starts a new Gemini conversation
+// @Tags Sessions
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} dto.Session
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /sessions/{session_id}/clear [post]
+func (h *SessionsController) HandleClearSession(c fiber.Ctx) error {
+	session, err := h.service.ClearSession(c.Params("session_id"))
+	if err != nil {
+		return h.respondError(c, err)
+	}
+	return c.JSON(NewSessionResponse(session))
+}
+
+// HandleDeleteSession deletes a session
+// @Summary Delete Session
+// @Description Deletes a session and removes its conversation from the Gemini history
+// @Tags Sessions
+// @Produce json
+// @Param session_id path string true "Session ID"
+// @Success 200 {object} dto.DeletedSession
+// @Failure 404 {object} map[string]interface{}
+// @Failure 409 {object} map[string]interface{}
+// @Router /sessions/{session_id} [delete]
+func (h *SessionsController) HandleDeleteSession(c fiber.Ctx) error {
+	id := c.Params("session_id")
+
+	ctx, cancel := context.WithTimeout(c.Context(), time.Minute)
+	defer cancel()
+
+	conversationDeleted, err := h.service.DeleteSession(ctx, id)
+	if err != nil {
+		return h.respondError(c, err)
+	}
+	return c.JSON(dto.DeletedSession{
+		ID:                  id,
+		Object:              "session.deleted",
+		Deleted:             true,
+		ConversationDeleted: conversationDeleted,
+	})
+}
+
+// checkModel rejects a message to a session whose model the request's API key
+// may not use
+func (h *SessionsController) checkModel(c fiber.Ctx, id string) error {
+	key := auth.KeyFromContext(c)
+	if key == nil {
+		return nil
+	}
+	session, err := h.service.GetSession(id)
+	if err != nil {
+		return err
+	}
+	return h.auth.CheckModel(key, session.Metadata.Model)
+}
+
+// respondError writes a service error with a matching status
+func (h *SessionsController) respondError(c fiber.Ctx, err error) error {
+	switch {
+	case errors.Is(err, ErrSessionNotFound), errors.Is(err, providers.ErrModelNotFound):
+		return c.Status(fiber.StatusNotFound).JSON(utils.ErrorToResponse(err, "invalid_request_error"))
+	case errors.Is(err, auth.ErrModelNotAllowed):
+		return c.Status(fiber.StatusForbidden).JSON(utils.ErrorToResponse(err, "invalid_request_error"))
+	case errors.Is(err, ErrSessionBusy):
+		return c.Status(fiber.StatusConflict).JSON(utils.ErrorToResponse(err, "invalid_request_error"))
+	case errors.Is(err, ErrInvalidMessage):
+		return c.Status(fiber.StatusBadRequest).JSON(utils.ErrorToResponse(err, "invalid_request_error"))
+	}
+
+	info := providers.SurfaceErrorInfo(utils.SurfaceOpenAI, err)
+	if info.Status == fiber.StatusInternalServerError {
+		h.log.Error("Session request failed", zap.Error(err))
+	} else {
+		h.log.Warn("Session request failed upstream", zap.Error(err))
+	}
+	return c.Status(info.Status).JSON(utils.SurfaceErrorBody(utils.SurfaceOpenAI, info.Status, info.Type, info.Code, err.Error()))
+}
+
+// Register registers the session routes onto the provided group
+func (c *SessionsController) Register(group fiber.Router) {
+	group.Post("/", c.HandleCreateSession)
+	group.Get("/:session_id", c.HandleGetSession)
+	group.Delete("/:session_id", c.HandleDeleteSession)
+	group.Get("/:session_id/history", c.HandleGetHistory)
+	group.Post("/:session_id/messages", c.HandleSendMessage)
+	group.Post("/:session_id/clear", c.HandleClearSession)
+}
@@ -0,0 +1,26 @@
+package sessions
+
+import (
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+
+	"github.com/gofiber/fiber/v3"
+	"go.uber.org/fx"
+)
+
+var Module = fx.Options(
+	fx.Provide(NewSessionStore),
+	fx.Provide(NewSessionsService),
+	fx.Provide(NewSessionsController),
+	fx.Invoke(RegisterRoutes),
+)
+
+func RegisterRoutes(app *fiber.App, c *SessionsController) {
+	c.Register(app.Group("/sessions"))
+}
+
+// NewSessionStore opens the store of chat sessions
+func NewSessionStore(cfg *configs.Config) (SessionStore, error) {
+	return Open(cfg.Session, "sessions", cfg.Session.MaxEntries, time.Duration(cfg.Session.TTL)*time.Second)
+}
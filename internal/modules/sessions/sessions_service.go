@@ -0,0 +1,284 @@
+package sessions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+
+	"gemini-web-to-api/internal/commons/tracing"
+	"gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/providers"
+	"gemini-web-to-api/internal/modules/sessions/dto"
+
+	"github.com/google/uuid"
+	"go.opentelemetry.io/otel/attribute"
+	"go.uber.org/zap"
+)
+
+var (
+	// ErrSessionBusy is returned when a message is posted while the previous one is still being answered
+	ErrSessionBusy = errors.New("session is busy answering another message")
+	// ErrInvalidMessage is returned for a message without content or with undecodable files
+	ErrInvalidMessage = errors.New("invalid message")
+)
+
+// SessionsService exposes chat sessions over HTTP. Sessions are kept in the
+// session store between requests and restored with StartChat for every message.
+type SessionsService struct {
+	client *providers.AccountPool
+	store  SessionStore
+	log    *zap.Logger
+
+	mu   sync.Mutex
+	busy map[string]bool // sessions with a message in flight
+}
+
+func NewSessionsService(client *providers.AccountPool, store SessionStore, log *zap.Logger) *SessionsService {
+	return &SessionsService{
+		client: client,
+		store:  store,
+		log:    log,
+		busy:   make(map[string]bool),
+	}
+}
+
+// MessageStream is a streaming reply; the session is saved before the final chunk is delivered
+type MessageStream struct {
+	Chunks <-chan providers.StreamChunk
+}
+
+// CreateSession stores a new, empty session for model
+func (s *SessionsService) CreateSession(model string) (*Session, error) {
+	if _, err := s.client.ResolveModel(model); err != nil {
+		return nil, err
+	}
+	session := &Session{
+		ID:       "sess_" + strings.ReplaceAll(uuid.New().String(), "-", ""),
+		Metadata: providers.SessionMetadata{Model: model},
+		History:  []providers.Message{},
+	}
+	if err := s.store.Put(session); err != nil {
+		return nil, err
+	}
+	return session, nil
+}
+
+// GetSession returns a stored session
+func (s *SessionsService) GetSession(id string) (*Session, error) {
+	return s.store.Get(id)
+}
+
+// SendMessage posts a message to the session's conversation and saves the reply
+func (s *SessionsService) SendMessage(ctx context.Context, id, message string, files []dto.File) (*providers.Response, error) {
+	ctx, span := tracing.Start(ctx, "SessionsService.SendMessage", attribute.String("session.id", id))
+	defer span.End()
+
+	session, chat, opts, err := s.begin(id, message, files)
+	if err != nil {
+		return nil, err
+	}
+	defer s.release(id)
+
+	response, err := chat.SendMessage(ctx, message, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.save(session, chat); err != nil {
+		return nil, err
+	}
+	return response, nil
+}
+
+// SendMessageStream posts a message and streams the reply. Errors returned here
+// happen before the stream starts; later failures arrive on the chunk channel.
+func (s *SessionsService) SendMessageStream(ctx context.Context, id, message string, files []dto.File) (*MessageStream, error) {
+	ctx, span := tracing.Start(ctx, "SessionsService.SendMessageStream", attribute.String("session.id", id))
+	defer span.End()
+
+	session, chat, opts, err := s.begin(id, message, files)
+	if err != nil {
+		return nil, err
+	}
+
+	chunks, err := chat.SendMessageStream(ctx, message, opts...)
+	if err != nil {
+		s.release(id)
+		return nil, err
+	}
+
+	out := make(chan providers.StreamChunk)
+	go func() {
+		defer close(out)
+		defer s.release(id)
+		for chunk := range chunks {
+			if chunk.Response != nil {
+				if err := s.save(session, chat); err != nil {
+					chunk = providers.StreamChunk{Err: err}
+				}
+			}
+			select {
+			case out <- chunk:
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return &MessageStream{Chunks: out}, nil
+}
+
+// ClearSession forgets the session's history; the next message starts a new conversation
+func (s *SessionsService) ClearSession(id string) (*Session, error) {
+	if err := s.acquire(id); err != nil {
+		return nil, err
+	}
+	defer s.release(id)
+
+	session, err := s.store.Get(id)
+	if err != nil {
+		return nil, err
+	}
+	chat := s.client.StartChat(session.ChatOptions()...)
+	chat.Clear()
+	if err := s.save(session, chat); err != nil {
+		return nil, err
+	}
+	return s.store.Get(id)
+}
+
+// DeleteSession deletes the session and its conversation from the Gemini
+// history. The session is deleted even if the conversation cannot be; the
+// result reports whether it was.
+func (s *SessionsService) DeleteSession(ctx context.Context, id string) (bool, error) {
+	ctx, span := tracing.Start(ctx, "SessionsService.DeleteSession", attribute.String("session.id", id))
+	defer span.End()
+
+	if err := s.acquire(id); err != nil {
+		return false, err
+	}
+	defer s.release(id)
+
+	session, err := s.store.Get(id)
+	if err != nil {
+		return false, err
+	}
+	if err := s.store.Delete(id); err != nil {
+		return false, err
+	}
+
+	if session.Metadata.ConversationID == "" {
+		return false, nil
+	}
+	if err := s.client.DeleteConversation(ctx, &session.Metadata); err != nil {
+		s.log.Warn("Failed to delete Gemini conversation",
+			zap.String("session_id", id),
+			zap.String("conversation_id", session.Metadata.ConversationID),
+			zap.Error(err),
+		)
+		return false, nil
+	}
+	return true, nil
+}
+
+// NewMessages converts a session history to response messages
+func NewMessages(history []providers.Message) []dto.Message {
+	messages := make([]dto.Message, 0, len(history))
+	for _, msg := range history {
+		messages = append(messages, NewMessage(msg.Role, msg.Content, msg.Images))
+	}
+	return messages
+}
+
+// NewMessage converts a history message or a reply to a response message
+func NewMessage(role, content string, images []providers.Image) dto.Message {
+	message := dto.Message{Role: role, Content: content}
+	for _, img := range images {
+		message.Images = append(message.Images, dto.Image{
+			URL:       img.URL,
+			Title:     img.Title,
+			AltText:   img.AltText,
+			Generated: img.Generated,
+		})
+	}
+	return message
+}
+
+// NewSources converts the sources of a reply
+func NewSources(sources []providers.Source) []dto.Source {
+	var result []dto.Source
+	for _, source := range sources {
+		result = append(result, dto.Source{URL: source.URL, Title: source.Title})
+	}
+	return result
+}
+
+// NewSessionResponse describes a stored session
+func NewSessionResponse(session *Session) dto.Session {
+	return dto.Session{
+		ID:             session.ID,
+		Object:         "session",
+		Model:          session.Metadata.Model,
+		ConversationID: session.Metadata.ConversationID,
+		ResponseID:     session.Metadata.ResponseID,
+		ChoiceID:       session.Metadata.ChoiceID,
+		Account:        session.Metadata.AccountName(),
+		MessageCount:   len(session.History),
+		CreatedAt:      session.CreatedAt,
+		UpdatedAt:      session.UpdatedAt,
+	}
+}
+
+// begin validates a message and restores its session, which stays busy until released
+func (s *SessionsService) begin(id, message string, files []dto.File) (*Session, providers.ChatSession, []providers.GenerateOption, error) {
+	var opts []providers.GenerateOption
+	if len(files) > 0 {
+		attachments := make([]utils.Attachment, 0, len(files))
+		for i, file := range files {
+			attachment, err := utils.NewAttachment(file.MimeType, file.Data, i)
+			if err != nil {
+				return nil, nil, nil, fmt.Errorf("%w: %v", ErrInvalidMessage, err)
+			}
+			attachments = append(attachments, attachment)
+		}
+		opts = append(opts, providers.WithAttachments(attachments))
+	}
+	if strings.TrimSpace(message) == "" && len(files) == 0 {
+		return nil, nil, nil, fmt.Errorf("%w: message or files are required", ErrInvalidMessage)
+	}
+
+	if err := s.acquire(id); err != nil {
+		return nil, nil, nil, err
+	}
+	session, err := s.store.Get(id)
+	if err != nil {
+		s.release(id)
+		return nil, nil, nil, err
+	}
+	return session, s.client.StartChat(session.ChatOptions()...), opts, nil
+}
+
+// save stores the state of chat as the session
+func (s *SessionsService) save(session *Session, chat providers.ChatSession) error {
+	updated := NewSession(session.ID, chat)
+	updated.CreatedAt = session.CreatedAt
+	return s.store.Put(updated)
+}
+
+// acquire marks a session busy. Messages to one session are answered one at a
+// time, since each continues the conversation from the previous reply.
+func (s *SessionsService) acquire(id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.busy[id] {
+		return ErrSessionBusy
+	}
+	s.busy[id] = true
+	return nil
+}
+
+func (s *SessionsService) release(id string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.busy, id)
+}
@@ -0,0 +1,73 @@
+package telemetry
+
+import (
+	"errors"
+	"strconv"
+	"time"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/commons/metrics"
+	utils "gemini-web-to-api/internal/commons/utils"
+	"gemini-web-to-api/internal/modules/providers"
+
+	"github.com/gofiber/fiber/v3"
+	"github.com/gofiber/fiber/v3/middleware/adaptor"
+)
+
+// MetricsPath is where the Prometheus metrics are served
+const MetricsPath = "/metrics"
+
+// unknownModel labels requests for models missing from the registry, so
+// arbitrary client input does not create new series
+const unknownModel = "unknown"
+
+// NewMetricsMiddleware returns a handler counting requests by surface, route,
+// model and status, and timing them
+func NewMetricsMiddleware(pool *providers.AccountPool) fiber.Handler {
+	return func(c fiber.Ctx) error {
+		if c.Path() == MetricsPath {
+			return c.Next()
+		}
+
+		start := time.Now()
+		model := modelLabel(pool, utils.RequestModel(c))
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			// The error handler writes the status after the middleware returns
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+		}
+
+		surface := utils.DetectSurface(c.Path())
+		route := c.Route().Path
+		metrics.Requests.WithLabelValues(surface, route, model, strconv.Itoa(status)).Inc()
+		metrics.RequestDuration.WithLabelValues(surface, route).Observe(time.Since(start).Seconds())
+		return err
+	}
+}
+
+// modelLabel returns the registry ID of the requested model
+func modelLabel(pool *providers.AccountPool, model string) string {
+	if model == "" {
+		return ""
+	}
+	info, err := pool.ResolveModel(model)
+	if err != nil {
+		return unknownModel
+	}
+	return info.ID
+}
+
+// RegisterMetrics serves /metrics and installs the request metrics middleware
+func RegisterMetrics(app *fiber.App, cfg *configs.Config, pool *providers.AccountPool) {
+	if !cfg.Metrics.Enabled {
+		return
+	}
+	app.Use(NewMetricsMiddleware(pool))
+	app.Get(MetricsPath, adaptor.HTTPHandler(metrics.Handler()))
+}
@@ -0,0 +1,8 @@
+package telemetry
+
+import "go.uber.org/fx"
+
+var Module = fx.Options(
+	fx.Invoke(RegisterTracing),
+	fx.Invoke(RegisterMetrics),
+)
@@ -0,0 +1,105 @@
+package telemetry
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"gemini-web-to-api/internal/commons/configs"
+	"gemini-web-to-api/internal/commons/tracing"
+	utils "gemini-web-to-api/internal/commons/utils"
+
+	"github.com/gofiber/fiber/v3"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"go.uber.org/fx"
+	"go.uber.org/zap"
+)
+
+// NewTracingMiddleware returns a handler that continues the caller's trace
+// from its traceparent header and wraps the request in a server span. The
+// span context is stored in the fiber context for the handlers.
+func NewTracingMiddleware() fiber.Handler {
+	tracer := otel.Tracer("gemini-web-to-api/http")
+	return func(c fiber.Ctx) error {
+		ctx := otel.GetTextMapPropagator().Extract(c.Context(), headerCarrier{c})
+		ctx, span := tracer.Start(ctx, c.Method(),
+			trace.WithSpanKind(trace.SpanKindServer),
+			trace.WithAttributes(
+				attribute.String("http.request.method", c.Method()),
+				attribute.String("url.path", c.Path()),
+				tracing.AttrSurface.String(utils.DetectSurface(c.Path())),
+			),
+		)
+		defer span.End()
+		if model := utils.RequestModel(c); model != "" {
+			span.SetAttributes(tracing.AttrModel.String(model))
+		}
+		c.SetContext(ctx)
+
+		err := c.Next()
+
+		status := c.Response().StatusCode()
+		if err != nil {
+			status = fiber.StatusInternalServerError
+			var fiberErr *fiber.Error
+			if errors.As(err, &fiberErr) {
+				status = fiberErr.Code
+			}
+			span.RecordError(err)
+		}
+		route := c.Route().Path
+		span.SetName(c.Method() + " " + route)
+		span.SetAttributes(
+			attribute.String("http.route", route),
+			attribute.Int("http.response.status_code", status),
+		)
+		if status >= fiber.StatusInternalServerError {
+			span.SetStatus(codes.Error, fmt.Sprintf("HTTP %d", status))
+		}
+		return err
+	}
+}
+
+// headerCarrier reads trace context from the request headers
+type headerCarrier struct {
+	c fiber.Ctx
+}
+
+func (h headerCarrier) Get(key string) string {
+	return h.c.Get(key)
+}
+
+// Set is a no-op: the carrier is only used to extract incoming context
+func (h headerCarrier) Set(string, string) {}
+
+func (h headerCarrier) Keys() []string {
+	keys := make([]string, 0, len(h.c.GetReqHeaders()))
+	for key := range h.c.GetReqHeaders() {
+		keys = append(keys, key)
+	}
+	return keys
+}
+
+// RegisterTracing installs the tracer provider and the tracing middleware, and
+// flushes pending spans on shutdown
+func RegisterTracing(lc fx.Lifecycle, app *fiber.App, cfg *configs.Config, log *zap.Logger) error {
+	provider, err := tracing.NewProvider(context.Background(), cfg.Tracing)
+	if err != nil {
+		return err
+	}
+	if provider == nil {
+		return nil
+	}
+
+	log.Info("Tracing enabled", zap.String("exporter", cfg.Tracing.Exporter))
+	app.Use(NewTracingMiddleware())
+	lc.Append(fx.Hook{
+		OnStop: func(ctx context.Context) error {
+			return provider.Shutdown(ctx)
+		},
+	})
+	return nil
+}
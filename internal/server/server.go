@@ -22,7 +22,7 @@ func NewGeminiWebToAPI(log *zap.Logger) *fiber.App {
 
 	app.Use(cors.New(cors.Config{
 		AllowOrigins:     []string{"*"},
-		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "x-api-key", "anthropic-version"},
+		AllowHeaders:     []string{"Origin", "Content-Type", "Accept", "Authorization", "X-Requested-With", "x-api-key", "x-goog-api-key", "anthropic-version"},
 		AllowMethods:     []string{"GET", "POST", "PUT", "DELETE", "OPTIONS", "PATCH"},
 		AllowCredentials: false,
 	}))
@@ -32,26 +32,11 @@ func NewGeminiWebToAPI(log *zap.Logger) *fiber.App {
 	// Swagger UI — gofiber/contrib/v3/swaggo (Fiber v3 compatible)
 	app.Get("/swagger/*", swaggo.HandlerDefault)
 
-	// Health check endpoint — used by Docker/K8s/cloud platforms
-	app.Get("/health", HealthCheck)
+	// Health checks (/health, /ready) are registered by the health module
 
 	return app
 }
 
-// HealthCheck godoc
-// @Summary      Health check
-// @Description  Returns the health status of the service. Used by Docker/K8s/cloud platforms to monitor the service.
-// @Tags         System
-// @Produce      json
-// @Success      200  {object}  object{status=string,service=string}  "Service is healthy"
-// @Router       /health [get]
-func HealthCheck(c fiber.Ctx) error {
-	return c.Status(fiber.StatusOK).JSON(fiber.Map{
-		"status":  "ok",
-		"service": "gemini-web-to-api",
-	})
-}
-
 // Register404Handler registers the 404 handler for unmatched routes
 // This must be called AFTER all other routes are registered
 func Register404Handler(app *fiber.App) {
@@ -0,0 +1,379 @@
+// Package fakegemini is a scriptable stand-in for the Gemini web app backend.
+// It serves the endpoints the proxy talks to (the app page with its SNlM0e
+// token, cookie rotation, StreamGenerate, uploads and batchexecute) with the
+// same wire format as Google, so the proxy can run end-to-end without a
+// Google account.
+package fakegemini
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Paths served by the fake, matching the production endpoint paths
+const (
+	PathHome          = "/"
+	PathInit          = "/app"
+	PathGenerate      = "/_/BardChatUi/data/assistant.lamda.BardFrontendService/StreamGenerate"
+	PathRotateCookies = "/RotateCookies"
+	PathBatchExec     = "/_/BardChatUi/data/batchexecute"
+	PathUpload        = "/upload"
+	PathImages        = "/images/"
+)
+
+// Cookies read and set by the fake
+const (
+	CookiePSID   = "__Secure-1PSID"
+	CookiePSIDTS = "__Secure-1PSIDTS"
+)
+
+// modelHeader carries the upstream model of a StreamGenerate request
+const modelHeader = "x-goog-ext-525001261-jspb"
+
+// Reply is a scripted answer to one StreamGenerate request
+type Reply struct {
+	Text    string   // text of the first candidate
+	Drafts  []string // texts of additional candidates
+	Sources []Source
+	Images  []Image
+
+	Status    int           // answer with this HTTP status instead of a reply
+	ErrorCode int           // answer 200 with this Gemini error code instead of a reply, e.g. 1037 (usage limit)
+	Delay     time.Duration // wait before each frame
+	Frames    int           // frames the text is streamed in; 0 streams one word per frame
+	Steps     []string      // texts of the frames instead of Text and Frames; a step that does not extend the previous one rewrites earlier text
+	Abort     bool          // drop the connection after the first frame
+}
+
+// Source is a cited web page
+type Source struct {
+	URL   string
+	Title string
+}
+
+// Image is an image attached to a reply. Generated images are listed with the
+// generated content, others as web images.
+type Image struct {
+	URL       string
+	Title     string
+	AltText   string
+	Generated bool
+}
+
+// Conversation identifies a turn of a conversation, as in the [cid, rid, rcid]
+// element of StreamGenerate requests
+type Conversation struct {
+	ID         string
+	ResponseID string
+	ChoiceID   string
+}
+
+// Request is a StreamGenerate request received by the fake
+type Request struct {
+	Prompt       string
+	Files        []string     // upload references attached to the message
+	Conversation Conversation // zero for a new conversation
+	Model        string       // upstream model hash, empty for the default model
+}
+
+// Responder answers requests that have no scripted reply queued
+type Responder func(Request) Reply
+
+// Echo replies with the prompt, which is enough for demos and smoke tests
+func Echo(req Request) Reply {
+	return Reply{Text: "Echo: " + req.Prompt}
+}
+
+// Server is the fake Gemini backend. The zero value is not usable; create it
+// with NewServer.
+type Server struct {
+	// Token is the SNlM0e session token served on the app page and expected
+	// as the "at" parameter of StreamGenerate and batchexecute
+	Token string
+	// PSID, when set, is the only __Secure-1PSID cookie accepted; requests
+	// with another value get a login page or 401
+	PSID string
+	// Responder answers requests when no reply is queued
+	Responder Responder
+	// FrameDelay is the default wait before each frame
+	FrameDelay time.Duration
+
+	mu            sync.Mutex
+	queue         []Reply
+	requests      []Request
+	conversations map[string]bool
+	uploads       map[string][]byte
+	rotations     int
+}
+
+// NewServer returns a fake that echoes prompts and accepts any cookies
+func NewServer() *Server {
+	return &Server{
+		Token:         "fake-" + randomID(),
+		Responder:     Echo,
+		conversations: make(map[string]bool),
+		uploads:       make(map[string][]byte),
+	}
+}
+
+// Enqueue scripts the replies to the next StreamGenerate requests, in order
+func (s *Server) Enqueue(replies ...Reply) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.queue = append(s.queue, replies...)
+}
+
+// Requests returns the StreamGenerate requests received so far
+func (s *Server) Requests() []Request {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return append([]Request(nil), s.requests...)
+}
+
+// Upload returns the content of an uploaded file by its reference
+func (s *Server) Upload(ref string) ([]byte, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	data, ok := s.uploads[ref]
+	return data, ok
+}
+
+// HasConversation reports whether a conversation was started and not deleted
+func (s *Server) HasConversation(id string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.conversations[id]
+}
+
+// Rotations returns how many times the cookies were rotated
+func (s *Server) Rotations() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.rotations
+}
+
+// ServeHTTP routes a request to the matching Gemini endpoint
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch {
+	case r.URL.Path == PathHome:
+		s.handleHome(w, r)
+	case r.URL.Path == PathInit:
+		s.handleInit(w, r)
+	case r.URL.Path == PathRotateCookies && r.Method == http.MethodPost:
+		s.handleRotateCookies(w, r)
+	case r.URL.Path == PathGenerate && r.Method == http.MethodPost:
+		s.handleGenerate(w, r)
+	case r.URL.Path == PathBatchExec && r.Method == http.MethodPost:
+		s.handleBatchExec(w, r)
+	case r.URL.Path == PathUpload && r.Method == http.MethodPost:
+		s.handleUpload(w, r)
+	case strings.HasPrefix(r.URL.Path, PathImages):
+		s.handleImage(w, r)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// handleHome stands in for both www.google.com and gemini.google.com, which
+// hand out the NID cookie before the app page is loaded
+func (s *Server) handleHome(w http.ResponseWriter, r *http.Request) {
+	http.SetCookie(w, &http.Cookie{Name: "NID", Value: randomID(), Path: "/"})
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	fmt.Fprint(w, "<!doctype html><title>Gemini</title>")
+}
+
+// handleInit serves the app page, with the session token for signed-in
+// cookies and a sign-in page otherwise
+func (s *Server) handleInit(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	if !s.signedIn(r) {
+		fmt.Fprint(w, "<!doctype html><title>Sign in - Google Accounts</title>")
+		return
+	}
+	fmt.Fprintf(w, `<!doctype html><title>Gemini</title><script>window.WIZ_global_data = {"SNlM0e":"%s","qKIAYe":"feeds/mcudyrk2a4khkz"};</script>`, s.Token)
+}
+
+// handleRotateCookies issues a new __Secure-1PSIDTS cookie
+func (s *Server) handleRotateCookies(w http.ResponseWriter, r *http.Request) {
+	if !s.hasPSID(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	s.mu.Lock()
+	s.rotations++
+	s.mu.Unlock()
+
+	http.SetCookie(w, &http.Cookie{Name: CookiePSIDTS, Value: "sidts-" + randomID(), Path: "/", Secure: true, HttpOnly: true})
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, `)]}'`+"\n\n"+`[["identity.hfcr",600]]`)
+}
+
+// handleGenerate answers a StreamGenerate request with the next scripted reply
+func (s *Server) handleGenerate(w http.ResponseWriter, r *http.Request) {
+	if !s.hasPSID(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.Form.Get("at") != s.Token {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	req, err := parseGenerateRequest(r.PostForm.Get("f.req"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	req.Model = parseModelHeader(r.Header.Get(modelHeader))
+
+	reply := s.next(req)
+	if reply.Status != 0 && reply.Status != http.StatusOK {
+		w.WriteHeader(reply.Status)
+		return
+	}
+	if reply.Delay == 0 {
+		reply.Delay = s.FrameDelay
+	}
+
+	turn := s.startTurn(req.Conversation)
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	flusher, _ := w.(http.Flusher)
+	io.WriteString(w, Guard+"\n")
+	for i, frame := range Frames(turn, reply) {
+		if reply.Delay > 0 {
+			select {
+			case <-time.After(reply.Delay):
+			case <-r.Context().Done():
+				return
+			}
+		}
+		WriteFrame(w, frame)
+		if flusher != nil {
+			flusher.Flush()
+		}
+		// The first frame only acknowledges the request; abort once text was sent
+		if reply.Abort && i > 0 {
+			panic(http.ErrAbortHandler)
+		}
+	}
+}
+
+// handleBatchExec answers batchexecute RPCs; deleting a conversation is the
+// only one with an effect
+func (s *Server) handleBatchExec(w http.ResponseWriter, r *http.Request) {
+	if !s.hasPSID(r) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if err := r.ParseForm(); err != nil || r.Form.Get("at") != s.Token {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+
+	rpcID := r.URL.Query().Get("rpcids")
+	if rpcID == rpcDeleteConversation {
+		if cid, ok := parseRPCPayload(r.PostForm.Get("f.req")); ok {
+			s.mu.Lock()
+			delete(s.conversations, cid)
+			s.mu.Unlock()
+		}
+	}
+
+	body, _ := json.Marshal([]any{[]any{"wrb.fr", rpcID, "[]", nil, nil, nil, "generic"}})
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	io.WriteString(w, Guard+"\n\n")
+	WriteFrame(w, string(body))
+}
+
+// handleUpload stores an attachment and returns its reference
+func (s *Server) handleUpload(w http.ResponseWriter, r *http.Request) {
+	file, _, err := r.FormFile("file")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+	data, err := io.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	ref := "/contrib_service/ttl_1d/" + randomID()
+	s.mu.Lock()
+	s.uploads[ref] = data
+	s.mu.Unlock()
+	fmt.Fprint(w, ref)
+}
+
+// handleImage serves a placeholder PNG for any image URL under /images/, so
+// scripted image replies can be downloaded
+func (s *Server) handleImage(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "image/png")
+	w.Write(placeholderPNG)
+}
+
+// next returns the scripted reply for req and records the request
+func (s *Server) next(req Request) Reply {
+	s.mu.Lock()
+	s.requests = append(s.requests, req)
+	if len(s.queue) > 0 {
+		reply := s.queue[0]
+		s.queue = s.queue[1:]
+		s.mu.Unlock()
+		return reply
+	}
+	responder := s.Responder
+	s.mu.Unlock()
+
+	if responder == nil {
+		return Echo(req)
+	}
+	return responder(req)
+}
+
+// startTurn returns the IDs of a new turn, continuing previous when it names
+// a conversation
+func (s *Server) startTurn(previous Conversation) Conversation {
+	turn := Conversation{
+		ID:         previous.ID,
+		ResponseID: "r_" + randomID(),
+		ChoiceID:   "rc_" + randomID(),
+	}
+	if turn.ID == "" {
+		turn.ID = "c_" + randomID()
+	}
+	s.mu.Lock()
+	s.conversations[turn.ID] = true
+	s.mu.Unlock()
+	return turn
+}
+
+// signedIn reports whether the request carries the cookies of a signed-in account
+func (s *Server) signedIn(r *http.Request) bool {
+	if !s.hasPSID(r) {
+		return false
+	}
+	ts, err := r.Cookie(CookiePSIDTS)
+	return err == nil && ts.Value != ""
+}
+
+func (s *Server) hasPSID(r *http.Request) bool {
+	psid, err := r.Cookie(CookiePSID)
+	if err != nil || psid.Value == "" {
+		return false
+	}
+	return s.PSID == "" || psid.Value == s.PSID
+}
+
+func randomID() string {
+	b := make([]byte, 8)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
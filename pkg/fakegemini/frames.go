@@ -0,0 +1,233 @@
+package fakegemini
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode/utf16"
+)
+
+// Guard is the anti-XSSI prefix of every Gemini response body
+const Guard = ")]}'"
+
+// rpcDeleteConversation is the batchexecute RPC deleting a conversation
+const rpcDeleteConversation = "GzXR5e"
+
+// placeholderPNG is a 1x1 transparent PNG served for every image
+var placeholderPNG, _ = base64.StdEncoding.DecodeString(
+	"iVBORw0KGgoAAAANSUhEUgAAAAEAAAABCAYAAAAfFcSJAAAADUlEQVR42mNkYPhfDwAChwGA60e6kgAAAABJRU5ErkJggg==")
+
+// Frames encodes reply as the JSON frames StreamGenerate flushes for turn: an
+// acknowledgement without candidates, one frame per step of the text carrying
+// everything generated so far, and a trailing metadata frame. Sources and
+// images are only attached to the last text frame, as Gemini does. Replies with
+// an ErrorCode are a single error frame instead.
+func Frames(turn Conversation, reply Reply) []string {
+	if reply.ErrorCode != 0 {
+		return []string{errorFrame(reply.ErrorCode), metadataFrame}
+	}
+
+	steps := reply.Steps
+	if len(steps) == 0 {
+		steps = textSteps(reply.Text, reply.Frames)
+	}
+
+	frames := []string{wrapPayload([]any{nil, []any{turn.ID, turn.ResponseID}})}
+	for i, text := range steps {
+		final := i == len(steps)-1
+		candidates := []any{candidate(turn.ChoiceID, text, reply, final)}
+		for j, draft := range reply.Drafts {
+			candidates = append(candidates, candidate(fmt.Sprintf("%s_%d", turn.ChoiceID, j+1), draft, Reply{}, final))
+		}
+		frames = append(frames, wrapPayload([]any{
+			nil,
+			[]any{turn.ID, turn.ResponseID},
+			nil,
+			nil,
+			candidates,
+		}))
+	}
+	frames = append(frames, metadataFrame)
+	return frames
+}
+
+// metadataFrame trails every StreamGenerate response
+const metadataFrame = `[["di",42],["af.httprm",41,"-4432153706738958541",1]]`
+
+// errorFrame is how Gemini reports a failure with a 200 status: no payload,
+// and the error code nested in item[5]
+func errorFrame(code int) string {
+	frame, _ := json.Marshal([]any{[]any{"wrb.fr", nil, nil, nil, nil, []any{
+		3, nil, []any{[]any{"type.googleapis.com/assistant.boq.bard.application.BardErrorInfo", []any{code}}},
+	}}})
+	return string(frame)
+}
+
+// WriteFrame writes one "<length>\n<json>\n" pair of a response body. Like
+// Gemini, the length counts UTF-16 code units.
+func WriteFrame(w io.Writer, frame string) error {
+	_, err := fmt.Fprintf(w, "%d\n%s\n", len(utf16.Encode([]rune(frame)))+1, frame)
+	return err
+}
+
+// Body encodes a complete, unstreamed StreamGenerate response body
+func Body(turn Conversation, reply Reply) string {
+	var b strings.Builder
+	b.WriteString(Guard + "\n")
+	for _, frame := range Frames(turn, reply) {
+		WriteFrame(&b, frame)
+	}
+	return b.String()
+}
+
+// wrapPayload nests a payload as the JSON string Gemini puts at item[2]
+func wrapPayload(payload []any) string {
+	inner, _ := json.Marshal(payload)
+	frame, _ := json.Marshal([]any{[]any{"wrb.fr", nil, string(inner)}})
+	return string(frame)
+}
+
+// candidate encodes one candidate; see parseFrame in the providers package
+// for the layout
+func candidate(id, text string, reply Reply, final bool) []any {
+	c := make([]any, 23)
+	c[0] = id
+	c[1] = []any{text}
+	if !final {
+		return c
+	}
+
+	if len(reply.Sources) > 0 {
+		var sources []any
+		for _, source := range reply.Sources {
+			sources = append(sources, []any{source.URL, source.Title})
+		}
+		c[2] = sources
+	}
+
+	var web, generated []any
+	for i, img := range reply.Images {
+		if img.Generated {
+			generated = append(generated, []any{
+				[]any{nil, nil, nil, []any{nil, nil, nil, img.URL, nil, nil, nil, []any{1024, 1024}}},
+				nil,
+				nil,
+				[]any{nil, nil, nil, nil, nil, []any{img.AltText}, i + 1},
+			})
+			continue
+		}
+		web = append(web, []any{
+			[]any{[]any{img.URL}, nil, nil, nil, img.AltText},
+			nil, nil, nil, nil, nil, nil,
+			[]any{img.Title},
+		})
+	}
+	if len(web) > 0 || len(generated) > 0 {
+		content := make([]any, 8)
+		content[1] = web
+		if len(generated) > 0 {
+			content[7] = []any{generated}
+		}
+		c[12] = content
+	}
+	return c
+}
+
+// textSteps splits text into the cumulative texts of n frames, or of one frame
+// per word when n is zero
+func textSteps(text string, n int) []string {
+	words := strings.SplitAfter(text, " ")
+	if n <= 0 || n > len(words) {
+		n = len(words)
+	}
+
+	steps := make([]string, 0, n)
+	for i := 1; i <= n; i++ {
+		end := i * len(words) / n
+		steps = append(steps, strings.Join(words[:end], ""))
+	}
+	return steps
+}
+
+// parseGenerateRequest decodes the f.req form value of StreamGenerate:
+// [null, "[message, null, [cid, rid, rcid] | null]"] with message being
+// [prompt] or [prompt, 0, null, [[[ref], name]...]]
+func parseGenerateRequest(freq string) (Request, error) {
+	var outer []any
+	if err := json.Unmarshal([]byte(freq), &outer); err != nil || len(outer) < 2 {
+		return Request{}, errors.New("malformed f.req")
+	}
+	innerJSON, ok := outer[1].(string)
+	if !ok {
+		return Request{}, errors.New("malformed f.req")
+	}
+	var inner []any
+	if err := json.Unmarshal([]byte(innerJSON), &inner); err != nil || len(inner) < 1 {
+		return Request{}, errors.New("malformed f.req message")
+	}
+
+	var req Request
+	message, _ := inner[0].([]any)
+	if len(message) == 0 {
+		return Request{}, errors.New("missing message")
+	}
+	req.Prompt, _ = message[0].(string)
+	if len(message) > 3 {
+		files, _ := message[3].([]any)
+		for _, file := range files {
+			if ref, ok := stringAt(file, 0, 0); ok {
+				req.Files = append(req.Files, ref)
+			}
+		}
+	}
+
+	if len(inner) > 2 {
+		req.Conversation.ID, _ = stringAt(inner[2], 0)
+		req.Conversation.ResponseID, _ = stringAt(inner[2], 1)
+		req.Conversation.ChoiceID, _ = stringAt(inner[2], 2)
+	}
+	return req, nil
+}
+
+// parseModelHeader returns the upstream model hash of the model header
+func parseModelHeader(header string) string {
+	var value []any
+	if err := json.Unmarshal([]byte(header), &value); err != nil {
+		return ""
+	}
+	model, _ := stringAt(value, 4)
+	return model
+}
+
+// parseRPCPayload returns the first payload element of a single-RPC
+// batchexecute f.req: [[[rpcID, "[value, ...]", null, "generic"]]]
+func parseRPCPayload(freq string) (string, bool) {
+	var request []any
+	if err := json.Unmarshal([]byte(freq), &request); err != nil {
+		return "", false
+	}
+	payloadJSON, ok := stringAt(request, 0, 0, 1)
+	if !ok {
+		return "", false
+	}
+	var payload []any
+	if err := json.Unmarshal([]byte(payloadJSON), &payload); err != nil {
+		return "", false
+	}
+	return stringAt(payload, 0)
+}
+
+func stringAt(v any, path ...int) (string, bool) {
+	for _, i := range path {
+		arr, ok := v.([]any)
+		if !ok || i >= len(arr) {
+			return "", false
+		}
+		v = arr[i]
+	}
+	s, ok := v.(string)
+	return s, ok
+}
@@ -0,0 +1,102 @@
+package fakegemini
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Transport returns a RoundTripper that serves every request with s in
+// memory, whatever its host, so no listener is needed. Responses stream like
+// over the network: the body is readable as soon as the handler flushes.
+func (s *Server) Transport() http.RoundTripper {
+	return transport{handler: s}
+}
+
+type transport struct {
+	handler http.Handler
+}
+
+func (t transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	// The handler reads the body on its own goroutine; give it a copy of the
+	// request as http.Handler callers do
+	req = req.Clone(req.Context())
+	if req.Body == nil {
+		req.Body = http.NoBody
+	}
+	req.RequestURI = req.URL.RequestURI()
+	if req.Host == "" {
+		req.Host = req.URL.Host
+	}
+
+	pr, pw := io.Pipe()
+	w := &pipeWriter{
+		header: make(http.Header),
+		body:   pw,
+		ready:  make(chan struct{}),
+	}
+	go func() {
+		defer func() {
+			if p := recover(); p != nil && p != http.ErrAbortHandler {
+				pw.CloseWithError(errors.New("fakegemini: handler panicked"))
+			} else if p != nil {
+				pw.CloseWithError(io.ErrUnexpectedEOF)
+			} else {
+				pw.Close()
+			}
+			w.WriteHeader(http.StatusOK)
+		}()
+		t.handler.ServeHTTP(w, req)
+	}()
+
+	select {
+	case <-w.ready:
+	case <-req.Context().Done():
+		pr.CloseWithError(req.Context().Err())
+		return nil, req.Context().Err()
+	}
+	return &http.Response{
+		Status:        fmt.Sprintf("%d %s", w.status, http.StatusText(w.status)),
+		StatusCode:    w.status,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        w.sent,
+		Body:          pr,
+		ContentLength: -1,
+		Request:       req,
+	}, nil
+}
+
+// pipeWriter is a ResponseWriter feeding the body of an in-memory response
+type pipeWriter struct {
+	header http.Header
+	body   *io.PipeWriter
+
+	once   sync.Once
+	ready  chan struct{}
+	status int
+	sent   http.Header
+}
+
+func (w *pipeWriter) Header() http.Header {
+	return w.header
+}
+
+func (w *pipeWriter) WriteHeader(status int) {
+	w.once.Do(func() {
+		w.status = status
+		w.sent = w.header.Clone()
+		close(w.ready)
+	})
+}
+
+func (w *pipeWriter) Write(p []byte) (int, error) {
+	w.WriteHeader(http.StatusOK)
+	return w.body.Write(p)
+}
+
+// Flush is a no-op: writes reach the reader as soon as they are made
+func (w *pipeWriter) Flush() {}